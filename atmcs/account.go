@@ -0,0 +1,274 @@
+package atmcs
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dragonzurfer/trader/atmcs/trade"
+	"github.com/dragonzurfer/trader/executor"
+)
+
+const (
+	defaultOrderTimeout      = 30 * time.Second
+	defaultOrderPollInterval = time.Second
+)
+
+// AccountTrade builds the same spread as PaperTrade and sends the legs to
+// the broker hedge first. If a leg cannot be filled the legs that did fill
+// are unwound, unless KeepPartialLegs is set in which case the partial
+// spread is kept open and reported.
+func (obj *ATMcs) AccountTrade(tradeType executor.TradeType) {
+	entryPositions := obj.makeEntryPositions(tradeType)
+	if entryPositions == nil {
+		obj.addError(errors.New("AccountTrade(): failed to make entry positions"))
+		return
+	}
+	entryPositions, err := obj.checkRisk(entryPositions)
+	if err != nil {
+		obj.addError(fmt.Errorf("AccountTrade(): %w", err))
+		return
+	}
+
+	placedPositions, err := obj.enterLegs(entryPositions)
+	if err != nil {
+		obj.addError(fmt.Errorf("AccountTrade(): %w", err))
+	}
+	var filledQuantity int64
+	for _, position := range placedPositions {
+		filledQuantity += position.Quantity
+	}
+	if filledQuantity == 0 {
+		return
+	}
+
+	obj.Trade.InTrade = true
+	obj.Trade.EntryPositions = placedPositions
+	obj.Trade.RiskReason = obj.RiskReason
+	obj.Trade.ExitPositions = nil
+	obj.Trade.TimeOfEntry = obj.GetCurrentTime()
+	obj.Trade.IsMinTrailHit = false
+	obj.Trade.IsStopLossHit = false
+	obj.Trade.InitialStopLossPrice = obj.Trade.StopLossPrice
+	obj.Trade.BestPrice = 0
+	obj.Trade.IsTrailActive = false
+	obj.setExitReason("", "")
+	if err == nil {
+		return
+	}
+	if obj.Settings.KeepPartialLegs {
+		obj.addError(errors.New("AccountTrade(): spread is partially entered"))
+		return
+	}
+	obj.addError(errors.New("AccountTrade(): unwinding filled legs"))
+	obj.setExitReason(executor.ExitError, "partial entry")
+	obj.ExitAccount()
+	if obj.Trade.InTrade {
+		obj.setExitReason("", "")
+		obj.addError(errors.New("AccountTrade(): failed to unwind filled legs, spread is partially entered"))
+	}
+}
+
+// ExitAccount sends the reverse of the filled quantity of every entry leg
+// that has not been exited yet, buying back before selling. Sell exits are
+// held back while any buy-back is incomplete so the hedge stays in place.
+// Exit positions line up with entry positions, and the trade is only
+// closed once every leg is flat.
+func (obj *ATMcs) ExitAccount() {
+	if !obj.Trade.InTrade {
+		return
+	}
+	if err := obj.ReconcileOrders(); err != nil {
+		obj.addError(fmt.Errorf("ExitAccount(): %w", err))
+	}
+	obj.alignExitPositions()
+
+	isBuyBackPending := false
+	for _, i := range hedgeFirstOrder(obj.Trade.ExitPositions) {
+		entryPosition := obj.Trade.EntryPositions[i]
+		exitPosition := &obj.Trade.ExitPositions[i]
+		remaining := entryPosition.Quantity - exitPosition.Quantity
+		if remaining <= 0 {
+			continue
+		}
+		if exitPosition.TradeType != executor.Buy && isBuyBackPending {
+			continue
+		}
+		if !exitPosition.HasOpenOrders() {
+			obj.exitLeg(entryPosition, exitPosition, remaining)
+		}
+		if exitPosition.TradeType == executor.Buy && entryPosition.Quantity > exitPosition.Quantity {
+			isBuyBackPending = true
+		}
+	}
+	if obj.isFlat() {
+		obj.closeTrade(obj.Trade.ExitPositions)
+	}
+}
+
+func (obj *ATMcs) exitLeg(entryPosition trade.OptionPosition, exitPosition *trade.OptionPosition, quantity int64) {
+	position, err := obj.MakePositionExit(entryPosition)
+	if err != nil {
+		obj.addError(fmt.Errorf("ExitAccount(): %w", err))
+		return
+	}
+	position.Quantity = quantity
+	position, err = obj.placePosition(position, obj.orderTimeout())
+	for _, order := range position.Orders {
+		exitPosition.AddOrder(order)
+	}
+	if err != nil {
+		obj.addError(fmt.Errorf("ExitAccount(): %w", err))
+	}
+}
+
+func (obj *ATMcs) isFlat() bool {
+	for i, entryPosition := range obj.Trade.EntryPositions {
+		if entryPosition.Quantity > obj.Trade.ExitPositions[i].Quantity {
+			return false
+		}
+	}
+	return true
+}
+
+// ReconcileOrders polls the broker for every order that is still open on
+// the trade, e.g. after the trade was loaded back from JSON.
+func (obj *ATMcs) ReconcileOrders() error {
+	var errs []string
+	positions := [][]trade.OptionPosition{obj.Trade.EntryPositions, obj.Trade.ExitPositions}
+	for _, legs := range positions {
+		for i := range legs {
+			for _, order := range legs[i].Orders {
+				if !order.IsOpen() {
+					continue
+				}
+				update, err := obj.Broker.GetOrderStatus(order.OrderID)
+				if err == nil {
+					err = legs[i].ApplyOrderUpdate(update)
+				}
+				if err != nil {
+					errs = append(errs, err.Error())
+				}
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to reconcile orders: %v", errs)
+	}
+	return nil
+}
+
+func (obj *ATMcs) alignExitPositions() {
+	if len(obj.Trade.ExitPositions) == len(obj.Trade.EntryPositions) {
+		return
+	}
+	exitPositions := make([]trade.OptionPosition, len(obj.Trade.EntryPositions))
+	for i, entryPosition := range obj.Trade.EntryPositions {
+		exitPositions[i] = trade.OptionPosition{
+			Option:    entryPosition.Option,
+			TradeType: reverseTradeType(entryPosition.TradeType),
+		}
+	}
+	obj.Trade.ExitPositions = exitPositions
+}
+
+// placePosition places an order for position and tracks it until it is
+// terminal or timeout elapses, cancelling whatever is left. The
+// returned position carries the order, its average fill price and the
+// filled quantity.
+func (obj *ATMcs) placePosition(position trade.OptionPosition, timeout time.Duration) (trade.OptionPosition, error) {
+	request := obj.makeOrderRequest(position)
+	position.Orders = nil
+	position.Quantity = 0
+
+	orderID, err := obj.Broker.PlaceOrder(request)
+	if err != nil {
+		order := trade.NewOrder(orderID, request.Quantity, obj.GetCurrentTime())
+		order.Status = executor.OrderRejected
+		order.Message = err.Error()
+		position.AddOrder(order)
+		return position, fmt.Errorf("failed to place order for %v %v %v: %w", position.Strike, position.Type, position.TradeType, err)
+	}
+	position.AddOrder(trade.NewOrder(orderID, request.Quantity, obj.GetCurrentTime()))
+
+	if err := obj.trackOrder(&position, orderID, timeout); err != nil {
+		return position, err
+	}
+	order, _ := position.LastOrder()
+	if order.Status != executor.OrderFilled {
+		return position, fmt.Errorf("order %v for %v %v %v ended %v with %v/%v filled: %v",
+			orderID, position.Strike, position.Type, position.TradeType,
+			order.Status, order.FilledQuantity, order.Quantity, order.Message)
+	}
+	return position, nil
+}
+
+func (obj *ATMcs) makeOrderRequest(position trade.OptionPosition) executor.OrderRequest {
+	orderType := obj.Settings.OrderType
+	if orderType == "" {
+		orderType = executor.LimitOrder
+	}
+	request := executor.OrderRequest{
+		Strike:     position.Strike,
+		Expiry:     position.Expiry,
+		OptionType: position.Type,
+		TradeType:  position.TradeType,
+		OrderType:  orderType,
+		Quantity:   position.Quantity,
+	}
+	if orderType == executor.LimitOrder {
+		request.LimitPrice = position.Price
+	}
+	return request
+}
+
+func (obj *ATMcs) orderTimeout() time.Duration {
+	if obj.Settings.OrderTimeout.Duration > 0 {
+		return obj.Settings.OrderTimeout.Duration
+	}
+	return defaultOrderTimeout
+}
+
+// trackOrder polls the broker and applies every update to position until
+// the order is terminal. An order still open at the timeout is cancelled.
+func (obj *ATMcs) trackOrder(position *trade.OptionPosition, orderID string, timeout time.Duration) error {
+	interval := obj.Settings.OrderPollInterval.Duration
+	if interval <= 0 {
+		interval = defaultOrderPollInterval
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		if err := obj.pollOrder(position, orderID); err != nil {
+			return err
+		}
+		order, _ := position.LastOrder()
+		if !order.IsOpen() {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(interval)
+	}
+
+	if err := obj.Broker.CancelOrder(orderID); err != nil {
+		obj.addError(fmt.Errorf("failed to cancel order %v: %w", orderID, err))
+	}
+	// fills may have arrived between the last poll and the cancel
+	return obj.pollOrder(position, orderID)
+}
+
+func (obj *ATMcs) pollOrder(position *trade.OptionPosition, orderID string) error {
+	update, err := obj.Broker.GetOrderStatus(orderID)
+	if err != nil {
+		return fmt.Errorf("failed to get status of order %v: %w", orderID, err)
+	}
+	if update.OrderID == "" {
+		update.OrderID = orderID
+	}
+	if update.UpdatedAt.IsZero() {
+		update.UpdatedAt = obj.GetCurrentTime()
+	}
+	return position.ApplyOrderUpdate(update)
+}
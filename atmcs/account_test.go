@@ -0,0 +1,154 @@
+package atmcs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dragonzurfer/trader/executor"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccountTradeAndExit(t *testing.T) {
+	now := istTime(t, "2023-05-16T10:20:00+05:30")
+	atm := newTestATMcs(t, nil, func() time.Time { return now })
+	broker := newFakeBroker(18310, testExpiries(atm.ISTLocation))
+	atm.SetBroker(broker)
+
+	atm.AccountTrade(executor.Buy)
+
+	assert.True(t, atm.InTrade())
+	assert.False(t, atm.IsError(), "unexpected errors %v", atm.ReadErrors())
+	assert.Len(t, broker.placed, 2)
+	assert.Len(t, atm.Trade.EntryPositions, 2)
+	for _, position := range atm.Trade.EntryPositions {
+		order, ok := position.LastOrder()
+		assert.True(t, ok)
+		assert.Equal(t, executor.OrderFilled, order.Status)
+		assert.Len(t, order.Fills, 1)
+		request := broker.orders[order.OrderID]
+		assert.Equal(t, request.Quantity, position.Quantity)
+		assert.Equal(t, request.LimitPrice, position.Price)
+		assert.Equal(t, executor.PutOption, position.Type)
+	}
+
+	atm.ExitAccount()
+
+	assert.False(t, atm.InTrade())
+	assert.Len(t, broker.placed, 4)
+	assert.Len(t, atm.Trade.ExitPositions, 2)
+	for i, position := range atm.Trade.ExitPositions {
+		assert.Equal(t, reverseTradeType(atm.Trade.EntryPositions[i].TradeType), position.TradeType)
+		assert.Equal(t, atm.Trade.EntryPositions[i].Quantity, position.Quantity)
+	}
+}
+
+func TestAccountTradeRejectedHedge(t *testing.T) {
+	now := istTime(t, "2023-05-16T10:20:00+05:30")
+	atm := newTestATMcs(t, nil, func() time.Time { return now })
+	broker := newFakeBroker(18310, testExpiries(atm.ISTLocation))
+	broker.rejectTypes[executor.Buy] = true
+	atm.SetBroker(broker)
+
+	atm.AccountTrade(executor.Sell)
+
+	// the hedge is bought first, so the option is never written
+	assert.False(t, atm.InTrade())
+	assert.True(t, atm.IsError())
+	assert.Len(t, broker.placed, 1)
+	assert.Equal(t, executor.Buy, broker.placed[0].TradeType)
+}
+
+func TestAccountTradeUnwindsPartialSpread(t *testing.T) {
+	now := istTime(t, "2023-05-16T10:20:00+05:30")
+	atm := newTestATMcs(t, nil, func() time.Time { return now })
+	broker := newFakeBroker(18310, testExpiries(atm.ISTLocation))
+	broker.fillLimits[executor.Sell] = 60
+	atm.SetBroker(broker)
+
+	atm.AccountTrade(executor.Buy)
+
+	assert.False(t, atm.InTrade())
+	assert.True(t, atm.IsError())
+	assert.Len(t, atm.Trade.EntryPositions, 2)
+	assert.Equal(t, int64(60), atm.Trade.EntryPositions[0].Quantity)
+	assert.Equal(t, int64(50), atm.Trade.EntryPositions[1].Quantity)
+	// hedge, partially filled write, buy back of the write, sell of the hedge
+	assert.Len(t, broker.placed, 4)
+	assert.Equal(t, executor.Buy, broker.placed[0].TradeType)
+	assert.Equal(t, executor.Sell, broker.placed[1].TradeType)
+	assert.Equal(t, executor.Buy, broker.placed[2].TradeType)
+	assert.Equal(t, int64(60), broker.placed[2].Quantity)
+	assert.Equal(t, executor.Sell, broker.placed[3].TradeType)
+	assert.Equal(t, int64(50), atm.Trade.ExitPositions[1].Quantity)
+}
+
+func TestAccountTradeKeepPartialLegs(t *testing.T) {
+	now := istTime(t, "2023-05-16T10:20:00+05:30")
+	atm := newTestATMcs(t, map[string]interface{}{"keep_partial_legs": true}, func() time.Time { return now })
+	broker := newFakeBroker(18310, testExpiries(atm.ISTLocation))
+	broker.fillLimits[executor.Sell] = 40
+	atm.SetBroker(broker)
+
+	atm.AccountTrade(executor.Buy)
+
+	assert.True(t, atm.InTrade())
+	assert.True(t, atm.Trade.IsPartiallyEntered())
+	assert.Len(t, broker.cancelled, 1)
+	sellLeg := atm.Trade.EntryPositions[0]
+	order, _ := sellLeg.LastOrder()
+	assert.Equal(t, executor.OrderCancelled, order.Status)
+	assert.Equal(t, int64(40), sellLeg.Quantity)
+}
+
+func TestExitAccountHoldsHedgeUntilBoughtBack(t *testing.T) {
+	now := istTime(t, "2023-05-16T10:20:00+05:30")
+	atm := newTestATMcs(t, nil, func() time.Time { return now })
+	broker := newFakeBroker(18310, testExpiries(atm.ISTLocation))
+	atm.SetBroker(broker)
+	atm.AccountTrade(executor.Buy)
+	assert.True(t, atm.InTrade())
+
+	broker.rejectTypes[executor.Buy] = true
+	atm.ExitAccount()
+
+	// buy back was rejected so the hedge must not be sold
+	assert.True(t, atm.InTrade())
+	assert.Len(t, broker.placed, 3)
+	assert.Equal(t, executor.Buy, broker.placed[2].TradeType)
+
+	broker.rejectTypes[executor.Buy] = false
+	atm.ExitAccount()
+	assert.False(t, atm.InTrade())
+	assert.Len(t, broker.placed, 5)
+}
+
+func TestExitOnTickExitsAccountTrade(t *testing.T) {
+	now := istTime(t, "2023-05-16T10:20:00+05:30")
+	atm := newTestATMcs(t, nil, func() time.Time { return now })
+	broker := newFakeBroker(18310, testExpiries(atm.ISTLocation))
+	atm.SetBroker(broker)
+	atm.AccountTrade(executor.Buy)
+	assert.True(t, atm.InTrade())
+	assert.True(t, atm.Trade.IsLive())
+	atm.Trade.TradeType = executor.Buy
+	atm.Trade.EntryPrice = 18310
+	atm.Trade.StopLossPrice = 18200
+	atm.Trade.TargetPrice = 18600
+
+	atm.ExitOnTick(18150)
+
+	assert.False(t, atm.InTrade())
+	assert.Equal(t, executor.ExitStopLoss, atm.Trade.ExitReason)
+	assert.Len(t, broker.placed, 4, "the exit is sent to the broker")
+	for i, position := range atm.Trade.ExitPositions {
+		assert.Equal(t, atm.Trade.EntryPositions[i].Quantity, position.Quantity)
+		_, ok := position.LastOrder()
+		assert.True(t, ok)
+	}
+	select {
+	case event := <-atm.GetEventChan():
+		assert.Equal(t, executor.ExitStopLoss, event.Reason)
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for the stop loss event")
+	}
+}
@@ -9,11 +9,15 @@ import (
 	cpr "github.com/dragonzurfer/strategy/CPR"
 	"github.com/dragonzurfer/trader/atmcs/trade"
 	"github.com/dragonzurfer/trader/executor"
+	"github.com/dragonzurfer/trader/executor/calendar"
+	"github.com/dragonzurfer/trader/executor/candles"
 )
 
 type ATMcs struct {
-	ISTLocation    *time.Location `json:"-"`
+	ISTLocation *time.Location `json:"-"`
+	// SignalCPR is the last CPR signal when the CPR provider is in use.
 	SignalCPR      cpr.Signal
+	Signal         executor.Signal
 	EntrySatisfied bool
 	ExitSatisfied  bool
 	Broker         executor.BrokerLike
@@ -24,27 +28,83 @@ type ATMcs struct {
 	SettingsFilesPath string
 	GetCurrentTime    func() time.Time `json:"-"`
 	Holidays
-	StopLossHitChan chan bool `json:"-"`
-	TargetHitChan   chan bool `json:"-"`
-	TrailChan       chan bool `json:"-"`
+	EventChan chan executor.Event `json:"-"`
+	Errors    []string            `json:"-"`
+	// SignalProvider overrides Settings.SignalSettings when set.
+	SignalProvider executor.SignalProviderLike `json:"-"`
+	// StrikeSelector overrides Settings.StrikeSelection when set.
+	StrikeSelector StrikeSelectorLike `json:"-"`
+	// Candles caches the candles fetched from Broker. It is created on
+	// first use and dropped by SetBroker.
+	Candles *candles.Store `json:"-"`
+	// TickCandles builds candles from ticks when
+	// Settings.SignalSettings.TickCandles is set.
+	TickCandles *candles.Builder `json:"-"`
+	// Calendar is the exchange calendar, see Settings.CalendarFilePath.
+	Calendar *calendar.Calendar `json:"-"`
+	// Portfolio is shared by the traders whose risk limits count each
+	// other's trades, see RiskLimits.
+	Portfolio *executor.Portfolio `json:"-"`
+	// RiskChecks run after the checks of Settings.Risk on every entry.
+	RiskChecks []RiskCheckLike `json:"-"`
+	// RiskReason is why the last entry was vetoed or resized.
+	RiskReason string `json:"-"`
+
+	lastPremiumCheck time.Time
 }
 
 type DurationWrapper struct {
 	time.Duration
 }
 type Settings struct {
-	HolidayDatesFilePath string          `json:"holidays_file_path"`
-	MinTrailPercent      float64         `json:"min_trail_percent"`
-	MinTargetPercent     float64         `json:"min_target_percent"`
-	MinStopLossPercent   float64         `json:"min_sl_percent"`
-	TradeFilePath        string          `json:"tradeFilePath"`
-	Quantity             int64           `json:"quantity"`
-	StrikeDiff           float64         `json:"strikeDiff"`
-	MinDaysToExpiry      int64           `json:"minDaysToExpiry"`
-	Symbol               string          `json:"symbol"`
-	TickSize             float64         `json:"tick_size"`
-	SleepDuration        DurationWrapper `json:"sleep_duration"`
-	IsLoadFromJSON       bool            `json:"IsLoadFromJSON"`
+	HolidayDatesFilePath string             `json:"holidays_file_path"`
+	MinTrailPercent      float64            `json:"min_trail_percent"`
+	MinTargetPercent     float64            `json:"min_target_percent"`
+	MinStopLossPercent   float64            `json:"min_sl_percent"`
+	TradeFilePath        string             `json:"tradeFilePath"`
+	Quantity             int64              `json:"quantity"`
+	StrikeDiff           float64            `json:"strikeDiff"`
+	MinDaysToExpiry      int64              `json:"minDaysToExpiry"`
+	Symbol               string             `json:"symbol"`
+	TickSize             float64            `json:"tick_size"`
+	SleepDuration        DurationWrapper    `json:"sleep_duration"`
+	IsLoadFromJSON       bool               `json:"IsLoadFromJSON"`
+	OrderType            executor.OrderType `json:"order_type"`
+	OrderTimeout         DurationWrapper    `json:"order_timeout"`
+	OrderPollInterval    DurationWrapper    `json:"order_poll_interval"`
+	LegTimeout           DurationWrapper    `json:"leg_timeout"`
+	KeepPartialLegs      bool               `json:"keep_partial_legs"`
+	StrikeSelection      StrikeSelection    `json:"strike_selection"`
+	SellExpiry           ExpiryPolicy       `json:"sell_expiry"`
+	HedgeExpiry          ExpiryPolicy       `json:"hedge_expiry"`
+	// Legs is the option structure entered on a signal. The calendar
+	// spread is used when empty.
+	Legs           []LegTemplate  `json:"legs"`
+	SignalSettings SignalSettings `json:"signal"`
+	// CandleTimeFrames are fetched from the broker as they are, any other
+	// time frame is aggregated from them. Defaults to 5min and 1Day.
+	CandleTimeFrames []executor.TimeFrame `json:"candle_timeframes"`
+	PremiumExit      PremiumExit          `json:"premium_exit"`
+	Trail            TrailSettings        `json:"trail"`
+	TimeExit         TimeExit             `json:"time_exit"`
+
+	// CalendarFilePath is an exchange calendar file, see package calendar,
+	// and Exchange the calendar in it to trade by, NSE_FO by default.
+	// Without a file the NSE calendar is used with the holidays file.
+	CalendarFilePath string        `json:"calendar_file_path"`
+	Exchange         string        `json:"exchange"`
+	TradingWindow    TradingWindow `json:"trading_window"`
+	// TradingDaysToExpiry counts the days to expiry of minDaysToExpiry and
+	// the expiry policies in trading days instead of calendar days.
+	TradingDaysToExpiry bool        `json:"trading_days_to_expiry"`
+	ExpiryCheck         ExpiryCheck `json:"expiry_check"`
+
+	Pricing PricingSettings `json:"pricing"`
+	// LotSize is the quantity of one lot of Symbol's options, 1 when unset.
+	LotSize int64      `json:"lot_size"`
+	Risk    RiskLimits `json:"risk"`
+	// Sizing decides the quantity entered, Quantity by default.
+	Sizing Sizing `json:"sizing"`
 }
 
 func (d *DurationWrapper) UnmarshalJSON(data []byte) error {
@@ -84,6 +144,30 @@ func (obj *ATMcs) loadSettingsFromFile() error {
 
 func (obj *ATMcs) SetBroker(broker executor.BrokerLike) {
 	obj.Broker = broker
+	obj.Candles = nil
+}
+
+func (obj *ATMcs) candleStore() *candles.Store {
+	if obj.Candles == nil {
+		obj.Candles = candles.NewStore(obj.Broker, obj.ISTLocation, obj.GetCurrentTime)
+		obj.Candles.Session = obj.candleSession()
+		obj.Candles.Direct = []executor.TimeFrame{executor.Minute5, executor.Day}
+		if len(obj.Settings.CandleTimeFrames) > 0 {
+			obj.Candles.Direct = obj.Settings.CandleTimeFrames
+		}
+	}
+	return obj.Candles
+}
+
+func (obj *ATMcs) tickCandleBuilder() *candles.Builder {
+	if obj.TickCandles == nil {
+		timeFrames := []executor.TimeFrame{executor.Minute5}
+		if tf := obj.Settings.Trail.timeFrame(); tf != executor.Minute5 {
+			timeFrames = append(timeFrames, tf)
+		}
+		obj.TickCandles = candles.NewBuilder(obj.candleSession(), timeFrames...)
+	}
+	return obj.TickCandles
 }
 
 func (obj *ATMcs) SetTradeFilePath(filepath string) {
@@ -94,27 +178,45 @@ func (obj *ATMcs) SetSettingsFilesPath(filepath string) {
 	obj.SettingsFilesPath = filepath
 }
 
+// InTradingWindow reports whether now is inside the trading window of one
+// of the sessions of the exchange day.
 func (obj *ATMcs) InTradingWindow() bool {
 	now := obj.GetCurrentTime()
-	start := time.Date(now.Year(), now.Month(), now.Day(), 9, 14, 0, 0, obj.ISTLocation)
-	end := time.Date(now.Year(), now.Month(), now.Day(), 15, 16, 0, 0, obj.ISTLocation)
-	// fmt.Printf("%v\n%v\n%v\n", start, end, now)
-	if now.After(start) && now.Before(end) {
-		return true
+	exchange := obj.ExchangeCalendar()
+	for _, session := range obj.TradingSessions(now) {
+		start, end := obj.Settings.TradingWindow.bounds(session)
+		if now.After(exchange.At(now, start)) && now.Before(exchange.At(now, end)) {
+			return true
+		}
 	}
 	return false
 }
 
 func (obj *ATMcs) IsError() bool {
-	return false
+	return len(obj.Errors) > 0
 }
+
+// ReadErrors returns the errors collected since the last call and clears them.
 func (obj *ATMcs) ReadErrors() []string {
-	return []string{}
+	errs := obj.Errors
+	obj.Errors = nil
+	return errs
+}
+
+func (obj *ATMcs) addError(err error) {
+	log.Println(err.Error())
+	obj.Errors = append(obj.Errors, err.Error())
 }
 func (obj *ATMcs) GetSleepDuration() time.Duration {
 	return obj.SleepDuration.Duration
 }
 
+// Now is the time the strategy runs on, so the runner books trades by the
+// replay or backtest clock rather than the wall clock.
+func (obj *ATMcs) Now() time.Time {
+	return obj.GetCurrentTime()
+}
+
 func (obj *ATMcs) LoadLocation() error {
 	istLocation, err := time.LoadLocation("Asia/Kolkata")
 	obj.ISTLocation = istLocation
@@ -138,24 +240,20 @@ func (obj *ATMcs) LoadHolidays() error {
 	return nil
 }
 
-func (obj *ATMcs) AccountTrade(executor.TradeType) {
-
-}
-
 func (obj *ATMcs) GetTradeType() executor.TradeType {
 	return obj.Trade.TradeType
 }
 
-func (obj *ATMcs) GetStopLossHitChan() <-chan bool {
-	return obj.StopLossHitChan
+func (obj *ATMcs) GetEventChan() <-chan executor.Event {
+	return obj.EventChan
 }
 
-func (obj *ATMcs) GetTargetHitChan() <-chan bool {
-	return obj.TargetHitChan
-}
-
-func (obj *ATMcs) GetTrailChan() <-chan bool {
-	return obj.TrailChan
+// notify sends an event without holding up the tick on its receiver.
+func (obj *ATMcs) notify(reason executor.ExitReason, detail string) {
+	event := executor.Event{Reason: reason, Detail: detail, Time: obj.GetCurrentTime()}
+	go func() {
+		obj.EventChan <- event
+	}()
 }
 
 func New(settingsFilePath string, currentTimeFunc func() time.Time) *ATMcs {
@@ -174,6 +272,14 @@ func New(settingsFilePath string, currentTimeFunc func() time.Time) *ATMcs {
 		log.Println("error loading ist location:", err.Error())
 		return nil
 	}
+	if err := obj.loadCalendar(); err != nil {
+		log.Println("error loading exchange calendar:", err.Error())
+		return nil
+	}
+	if err := obj.Settings.TradingWindow.validate(obj.Calendar); err != nil {
+		log.Println("error loading trading window:", err.Error())
+		return nil
+	}
 	if obj.Settings.TickSize <= 0 {
 		log.Println("error loading tick size, cannot be <= 0")
 		return nil
@@ -182,6 +288,64 @@ func New(settingsFilePath string, currentTimeFunc func() time.Time) *ATMcs {
 		log.Println("min target cananot be lesser than min trail in settings")
 		return nil
 	}
+	if _, err := obj.signalProvider(); err != nil {
+		log.Println("error loading signal provider:", err.Error())
+		return nil
+	}
+	if _, err := obj.strikeSelector(); err != nil {
+		log.Println("error loading strike selection:", err.Error())
+		return nil
+	}
+	if err := obj.Settings.SellExpiry.validate("sell"); err != nil {
+		log.Println("error loading sell expiry:", err.Error())
+		return nil
+	}
+	if err := obj.Settings.HedgeExpiry.validate("hedge"); err != nil {
+		log.Println("error loading hedge expiry:", err.Error())
+		return nil
+	}
+	if err := validateLegs(obj.Settings); err != nil {
+		log.Println("error loading legs:", err.Error())
+		return nil
+	}
+	if err := obj.Settings.TimeExit.validate(); err != nil {
+		log.Println("error loading time exit:", err.Error())
+		return nil
+	}
+	if err := obj.Settings.Trail.validate(); err != nil {
+		log.Println("error loading trail:", err.Error())
+		return nil
+	}
+	if err := obj.Settings.ExpiryCheck.validate(); err != nil {
+		log.Println("error loading expiry check:", err.Error())
+		return nil
+	}
+	if err := obj.Settings.PremiumExit.validate(); err != nil {
+		log.Println("error loading premium exit:", err.Error())
+		return nil
+	}
+	if err := obj.Settings.Pricing.validate(); err != nil {
+		log.Println("error loading pricing:", err.Error())
+		return nil
+	}
+	if obj.Settings.LotSize < 0 {
+		log.Println("error loading lot size, cannot be < 0")
+		return nil
+	}
+	if err := obj.Settings.Risk.validate(); err != nil {
+		log.Println("error loading risk limits:", err.Error())
+		return nil
+	}
+	if err := obj.Settings.Sizing.validate(obj.legTemplates()); err != nil {
+		log.Println("error loading sizing:", err.Error())
+		return nil
+	}
+	for _, tf := range obj.Settings.CandleTimeFrames {
+		if tf.Duration() == 0 {
+			log.Println("error loading candle time frames: cannot cache", tf)
+			return nil
+		}
+	}
 	obj.SetTradeFilePath(obj.Settings.TradeFilePath)
 	if obj.Settings.IsLoadFromJSON {
 		if err := obj.LoadFromJSON(); err != nil {
@@ -190,9 +354,7 @@ func New(settingsFilePath string, currentTimeFunc func() time.Time) *ATMcs {
 		}
 	}
 
-	obj.StopLossHitChan = make(chan bool)
-	obj.TargetHitChan = make(chan bool)
-	obj.TrailChan = make(chan bool)
+	obj.EventChan = make(chan executor.Event)
 	obj.GetCurrentTime = currentTimeFunc
 	obj.EntrySatisfied = false
 	obj.ExitSatisfied = false
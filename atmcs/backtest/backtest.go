@@ -0,0 +1,199 @@
+// Package backtest replays a replay directory of recorded candles and
+// option depth through an unchanged ATMcs, driving its clock, without any
+// network access.
+package backtest
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dragonzurfer/trader/atmcs"
+	"github.com/dragonzurfer/trader/atmcs/trade"
+	"github.com/dragonzurfer/trader/executor"
+	"github.com/dragonzurfer/trader/executor/replay"
+)
+
+type Config struct {
+	SettingsFilePath string
+	// DataDir is laid out as described in package replay.
+	DataDir string
+	// From and To are the first and last dates replayed, inclusive.
+	From time.Time
+	To   time.Time
+	// CarryOvernight keeps trades open across days instead of squaring
+	// them off when the trading window closes.
+	CarryOvernight bool
+}
+
+type TradeResult struct {
+	Trade trade.Trade
+	PnL   float64
+}
+
+type Result struct {
+	Trades []TradeResult
+	PnL    float64
+}
+
+type Backtest struct {
+	Config
+	ATMcs  *atmcs.ATMcs
+	broker *tickBroker
+	clock  *replay.Clock
+	result Result
+}
+
+func New(config Config) (*Backtest, error) {
+	if config.To.Before(config.From) {
+		return nil, fmt.Errorf("backtest end %v is before start %v", config.To, config.From)
+	}
+	bt := &Backtest{Config: config, clock: replay.NewClock(config.From)}
+	bt.ATMcs = atmcs.New(config.SettingsFilePath, bt.clock.Now)
+	if bt.ATMcs == nil {
+		return nil, errors.New("failed to create ATMcs from " + config.SettingsFilePath)
+	}
+	bt.ATMcs.Trade = trade.Trade{}
+	bt.broker = &tickBroker{ReplayBroker: replay.NewReplayBroker(config.DataDir, bt.clock.Now)}
+	bt.ATMcs.SetBroker(bt.broker)
+	if _, err := bt.broker.RecordedCandles(bt.ATMcs.Symbol, executor.Minute5); err != nil {
+		return nil, fmt.Errorf("failed to load %v candles: %w", executor.Minute5, err)
+	}
+	return bt, nil
+}
+
+// Run replays every market day between From and To and returns the
+// closed trades with their P&L.
+func Run(config Config) (Result, error) {
+	bt, err := New(config)
+	if err != nil {
+		return Result{}, err
+	}
+	return bt.Run(), nil
+}
+
+func (bt *Backtest) Run() Result {
+	done := make(chan bool)
+	defer close(done)
+	go bt.drainChannels(done)
+
+	loc := bt.ATMcs.ISTLocation
+	from := time.Date(bt.From.Year(), bt.From.Month(), bt.From.Day(), 0, 0, 0, 0, loc)
+	to := time.Date(bt.To.Year(), bt.To.Month(), bt.To.Day(), 0, 0, 0, 0, loc)
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		if bt.ATMcs.IsTradingDay(day) {
+			bt.runDay(day)
+		}
+	}
+	return bt.result
+}
+
+func (bt *Backtest) runDay(day time.Time) {
+	atm := bt.ATMcs
+	step := atm.GetSleepDuration()
+	if step <= 0 {
+		step = executor.Minute5.Duration()
+	}
+	exchange := atm.ExchangeCalendar()
+	for _, session := range atm.TradingSessions(day) {
+		bt.runSession(exchange.At(day, session.Open), exchange.At(day, session.Close), step)
+	}
+
+	if atm.InTrade() && !bt.CarryOvernight {
+		atm.SetExitReason(executor.ExitTime, "end of day")
+		atm.ExitPaper()
+		if atm.InTrade() {
+			atm.SetExitReason("", "")
+			return
+		}
+		bt.recordExit()
+	}
+}
+
+// runSession steps the clock from open until the trading window of the
+// session closes.
+func (bt *Backtest) runSession(open, sessionClose time.Time, step time.Duration) {
+	atm := bt.ATMcs
+	bt.clock.Set(open)
+	inWindow := false
+	for {
+		previous := bt.clock.Now()
+		now := bt.clock.Advance(step)
+		if !atm.InTradingWindow() {
+			if inWindow || !now.Before(sessionClose) {
+				return
+			}
+			continue
+		}
+		inWindow = true
+		if atm.InTrade() {
+			bt.replayTicks(previous, now)
+		}
+		if atm.InTrade() && atm.IsTimeExitSatisfied() {
+			atm.ExitPaper()
+			if !atm.InTrade() {
+				bt.recordExit()
+			}
+		}
+		if !atm.InTrade() && atm.IsEntrySatisfied() {
+			atm.PaperTrade(atm.GetTradeType())
+		}
+	}
+}
+
+// replayTicks feeds the completed 5 minute candles of (from, to] to
+// ExitOnTick as open, high/low and close ticks, advancing the clock through
+// each candle.
+func (bt *Backtest) replayTicks(from, to time.Time) {
+	defer func() { bt.broker.tick = 0 }()
+	defer bt.clock.Set(to)
+	tf := executor.Minute5
+	candles, _ := bt.broker.RecordedCandles(bt.ATMcs.Symbol, tf)
+	for _, candle := range candles {
+		closeTime := candle.Time.Add(tf.Duration())
+		if !closeTime.After(from) {
+			continue
+		}
+		if closeTime.After(to) {
+			break
+		}
+		prices := tickPath(candle)
+		for i, price := range prices {
+			bt.clock.Set(candle.Time.Add(tf.Duration() * time.Duration(i+1) / time.Duration(len(prices))))
+			bt.broker.tick = price
+			bt.ATMcs.ExitOnTick(price)
+			if !bt.ATMcs.InTrade() {
+				bt.recordExit()
+				return
+			}
+		}
+	}
+}
+
+// tickPath orders a candle's prices the way they most likely traded: a
+// green candle dips to its low before the high, a red one the reverse.
+func tickPath(candle replay.Candle) []float64 {
+	if candle.Close >= candle.Open {
+		return []float64{candle.Open, candle.Low, candle.High, candle.Close}
+	}
+	return []float64{candle.Open, candle.High, candle.Low, candle.Close}
+}
+
+func (bt *Backtest) recordExit() {
+	closed := bt.ATMcs.Trade
+	pnl := closed.RealizedPnL()
+	bt.result.Trades = append(bt.result.Trades, TradeResult{Trade: closed, PnL: pnl})
+	bt.result.PnL += pnl
+}
+
+// drainChannels consumes the events ExitOnTick sends from its
+// own goroutines; the backtest observes exits through InTrade instead.
+func (bt *Backtest) drainChannels(done chan bool) {
+	for {
+		select {
+		case <-bt.ATMcs.GetEventChan():
+		case <-done:
+			return
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package backtest
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dragonzurfer/trader/atmcs/trade"
+	"github.com/dragonzurfer/trader/executor"
+	"github.com/stretchr/testify/assert"
+)
+
+func testConfig(t *testing.T) Config {
+	loc, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Fatalf("Error loading location: %v", err)
+	}
+	return Config{
+		SettingsFilePath: filepath.Join("testdata", "settings.json"),
+		DataDir:          filepath.Join("testdata", "nifty"),
+		From:             time.Date(2023, 5, 15, 0, 0, 0, 0, loc),
+		To:               time.Date(2023, 5, 16, 0, 0, 0, 0, loc),
+	}
+}
+
+func TestRunTargetHit(t *testing.T) {
+	result, err := Run(testConfig(t))
+	if err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+
+	assert.Len(t, result.Trades, 1)
+	closed := result.Trades[0].Trade
+	assert.Equal(t, "2023-05-16 09:50", closed.TimeOfEntry.Format("2006-01-02 15:04"))
+	assert.Len(t, closed.EntryPositions, 2)
+	assert.Equal(t, 18400.0, closed.EntryPositions[0].Strike)
+	assert.Equal(t, executor.PutOption, closed.EntryPositions[0].Type)
+	assert.Equal(t, 120.0, closed.EntryPositions[0].Price)
+	assert.Equal(t, 252.0, closed.EntryPositions[1].Price)
+	assert.Equal(t, 91.0, closed.ExitPositions[0].Price)
+	assert.Equal(t, 230.0, closed.ExitPositions[1].Price)
+	assert.True(t, closed.TimeOfExit.After(closed.TimeOfEntry))
+	// (120-91)*100 + (230-252)*50
+	assert.InDelta(t, 1800.0, result.PnL, 1e-9)
+	assert.InDelta(t, result.PnL, result.Trades[0].PnL, 1e-9)
+}
+
+func TestRunIsDeterministic(t *testing.T) {
+	first, err := Run(testConfig(t))
+	if err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+	second, err := Run(testConfig(t))
+	if err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+	assert.Equal(t, first.PnL, second.PnL)
+	assert.Equal(t, len(first.Trades), len(second.Trades))
+	assert.True(t, first.Trades[0].Trade.TimeOfExit.Equal(second.Trades[0].Trade.TimeOfExit))
+}
+
+func TestGetCandlesOnlyReturnsCompleted(t *testing.T) {
+	config := testConfig(t)
+	bt, err := New(config)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	loc := bt.ATMcs.ISTLocation
+	now := time.Date(2023, 5, 16, 9, 27, 0, 0, loc)
+	bt.clock.Set(now)
+	from := time.Date(2023, 5, 16, 9, 15, 0, 0, loc)
+
+	candles, err := bt.broker.GetCandles(bt.ATMcs.Symbol, from, now, executor.Minute5)
+	assert.Nil(t, err)
+	assert.Len(t, candles, 2)
+
+	daily, err := bt.broker.GetCandles(bt.ATMcs.Symbol, from, now, executor.Day)
+	assert.Nil(t, err)
+	assert.Len(t, daily, 0)
+}
+
+// openUnpricedTrade puts bt in a trade on an expiry without recorded
+// depth, so it cannot be exited.
+func openUnpricedTrade(bt *Backtest) {
+	expiry := time.Date(2023, 7, 27, 0, 0, 0, 0, bt.ATMcs.ISTLocation)
+	bt.ATMcs.Trade.InTrade = true
+	bt.ATMcs.Trade.EntryPositions = []trade.OptionPosition{{
+		Option:    trade.Option{Strike: 18400, Expiry: expiry, Type: executor.PutOption},
+		TradeType: executor.Sell,
+		Price:     120,
+		Quantity:  100,
+	}}
+}
+
+func TestRunDayKeepsTradeThatCannotExit(t *testing.T) {
+	bt, err := New(testConfig(t))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	openUnpricedTrade(bt)
+
+	bt.runDay(time.Date(2023, 5, 16, 0, 0, 0, 0, bt.ATMcs.ISTLocation))
+
+	assert.True(t, bt.ATMcs.InTrade())
+	assert.Len(t, bt.result.Trades, 0)
+	assert.Empty(t, bt.ATMcs.Trade.ExitReason)
+	assert.Empty(t, bt.ATMcs.Trade.ExitDetail)
+}
+
+func TestRunSessionKeepsTradeThatCannotSquareOff(t *testing.T) {
+	bt, err := New(testConfig(t))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	bt.ATMcs.Settings.TimeExit.SquareOff = "09:30"
+	openUnpricedTrade(bt)
+	loc := bt.ATMcs.ISTLocation
+
+	bt.runSession(time.Date(2023, 5, 16, 9, 15, 0, 0, loc), time.Date(2023, 5, 16, 15, 30, 0, 0, loc), 5*time.Minute)
+
+	assert.True(t, bt.ATMcs.InTrade())
+	assert.Len(t, bt.result.Trades, 0)
+}
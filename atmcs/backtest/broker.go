@@ -0,0 +1,19 @@
+package backtest
+
+import (
+	"github.com/dragonzurfer/trader/executor/replay"
+)
+
+// tickBroker is a ReplayBroker whose LTP can be overridden while candles
+// are being replayed as ticks.
+type tickBroker struct {
+	*replay.ReplayBroker
+	tick float64
+}
+
+func (b *tickBroker) GetLTP(symbol string) (float64, error) {
+	if b.tick > 0 {
+		return b.tick, nil
+	}
+	return b.ReplayBroker.GetLTP(symbol)
+}
@@ -0,0 +1,133 @@
+package atmcs
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dragonzurfer/trader/executor/calendar"
+	"github.com/dragonzurfer/trader/executor/candles"
+)
+
+const defaultExchange = "NSE_FO"
+
+// TradingWindow is when entries are looked for and trades held, as offsets
+// from the open and close of every session of the exchange day. It runs
+// from a minute before the open to 14 minutes before the close by default,
+// 9:14 to 15:16 on NSE.
+type TradingWindow struct {
+	OpenOffset  *DurationWrapper `json:"open_offset"`
+	CloseOffset *DurationWrapper `json:"close_offset"`
+}
+
+func (w TradingWindow) bounds(session calendar.Session) (time.Duration, time.Duration) {
+	start := session.Open - time.Minute
+	if w.OpenOffset != nil {
+		start = session.Open + w.OpenOffset.Duration
+	}
+	end := session.Close - 14*time.Minute
+	if w.CloseOffset != nil {
+		end = session.Close + w.CloseOffset.Duration
+	}
+	return start, end
+}
+
+func (w TradingWindow) validate(exchange *calendar.Calendar) error {
+	for _, session := range mergeSessions(exchange.Sessions) {
+		if start, end := w.bounds(session); start >= end {
+			return fmt.Errorf("trading window of session %q is empty", session.Name)
+		}
+	}
+	return nil
+}
+
+// mergeSessions joins sessions that open as the previous one closes, such
+// as MCX's day and evening sessions, so the trading window only closes
+// where trading breaks.
+func mergeSessions(sessions []calendar.Session) []calendar.Session {
+	var merged []calendar.Session
+	for _, session := range sessions {
+		if n := len(merged); n > 0 && session.Open <= merged[n-1].Close {
+			if session.Close > merged[n-1].Close {
+				merged[n-1].Close = session.Close
+			}
+			merged[n-1].Name += "+" + session.Name
+			continue
+		}
+		merged = append(merged, session)
+	}
+	return merged
+}
+
+// TradingSessions returns the sessions of the exchange day of t the
+// trading window applies to, with back to back sessions joined.
+func (obj *ATMcs) TradingSessions(t time.Time) []calendar.Session {
+	return mergeSessions(obj.ExchangeCalendar().SessionsOn(t))
+}
+
+// loadCalendar sets Calendar to the Exchange calendar of
+// Settings.CalendarFilePath, or to the NSE calendar with the holidays file
+// when there is no calendar file.
+func (obj *ATMcs) loadCalendar() error {
+	if obj.Settings.CalendarFilePath == "" {
+		nse := calendar.NSE(obj.ISTLocation)
+		if err := nse.AddHolidays(obj.Holidays.HolidayDates...); err != nil {
+			return err
+		}
+		obj.Calendar = nse
+		return nil
+	}
+	calendars, err := calendar.Load(obj.Settings.CalendarFilePath)
+	if err != nil {
+		return err
+	}
+	exchange := obj.Settings.Exchange
+	if exchange == "" {
+		exchange = defaultExchange
+	}
+	exchangeCalendar, ok := calendars[exchange]
+	if !ok {
+		return fmt.Errorf("exchange %q is not in %v", exchange, obj.Settings.CalendarFilePath)
+	}
+	obj.Calendar = exchangeCalendar
+	return nil
+}
+
+// ExchangeCalendar returns Calendar, loading it on first use.
+func (obj *ATMcs) ExchangeCalendar() *calendar.Calendar {
+	if obj.Calendar == nil {
+		if err := obj.loadCalendar(); err != nil {
+			obj.addError(fmt.Errorf("ExchangeCalendar(): %w", err))
+			obj.Calendar = calendar.NSE(obj.ISTLocation)
+		}
+	}
+	return obj.Calendar
+}
+
+// IsTradingDay reports whether the exchange has a session on the day of
+// date.
+func (obj *ATMcs) IsTradingDay(date time.Time) bool {
+	return obj.ExchangeCalendar().IsTradingDay(date)
+}
+
+// candleSession spans the sessions of a regular exchange day.
+func (obj *ATMcs) candleSession() candles.Session {
+	exchange := obj.ExchangeCalendar()
+	sessions := exchange.Sessions
+	return candles.Session{
+		Location: exchange.Location,
+		Open:     sessions[0].Open,
+		Close:    sessions[len(sessions)-1].Close,
+	}
+}
+
+// sessionBounds returns the open of the first session and the close of the
+// last one on the day of t, or of a regular day when the exchange is
+// closed on it.
+func (obj *ATMcs) sessionBounds(t time.Time) (time.Time, time.Time) {
+	exchange := obj.ExchangeCalendar()
+	sessions := exchange.SessionsOn(t)
+	if len(sessions) == 0 {
+		sessions = exchange.Sessions
+	}
+	return exchange.At(t, sessions[0].Open), exchange.At(t, sessions[len(sessions)-1].Close)
+}
@@ -0,0 +1,111 @@
+package atmcs
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultTradingWindow(t *testing.T) {
+	now := istTime(t, "2023-05-16T09:14:00+05:30")
+	atm := newTestATMcs(t, nil, func() time.Time { return now })
+
+	assert.False(t, atm.InTradingWindow())
+	now = istTime(t, "2023-05-16T09:14:30+05:30")
+	assert.True(t, atm.InTradingWindow())
+	now = istTime(t, "2023-05-16T15:15:59+05:30")
+	assert.True(t, atm.InTradingWindow())
+	now = istTime(t, "2023-05-16T15:16:00+05:30")
+	assert.False(t, atm.InTradingWindow())
+
+	// holidays come from the holidays file
+	now = istTime(t, "2023-06-28T10:00:00+05:30")
+	assert.False(t, atm.InTradingWindow())
+	assert.Equal(t, 27, atm.GetPreviousNonWeekendNonHolidayDate(istTime(t, "2023-06-29T10:00:00+05:30")).Day())
+}
+
+func writeCalendarFile(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "calendar.json")
+	config := `{
+		"exchanges": {
+			"NSE_FO": {
+				"sessions": [{"name": "regular", "open": "09:15", "close": "15:30"}],
+				"holidays": ["2023-05-17"],
+				"special_days": [
+					{"date": "2023-11-12", "sessions": [{"name": "muhurat", "open": "18:15", "close": "19:15"}]},
+					{"date": "2023-05-19", "sessions": [{"name": "half day", "open": "09:15", "close": "12:30"}]}
+				]
+			},
+			"MCX": {
+				"sessions": [
+					{"name": "day", "open": "09:00", "close": "17:00"},
+					{"name": "evening", "open": "17:00", "close": "23:30"}
+				]
+			}
+		}
+	}`
+	if err := ioutil.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCalendarFileTradingWindow(t *testing.T) {
+	path := writeCalendarFile(t)
+	now := istTime(t, "2023-11-12T18:30:00+05:30")
+	atm := newTestATMcs(t, map[string]interface{}{
+		"calendar_file_path": path,
+		"trading_window":     map[string]interface{}{"open_offset": "0s", "close_offset": "-5m"},
+	}, func() time.Time { return now })
+
+	// the Sunday Muhurat session
+	assert.True(t, atm.InTradingWindow())
+	now = istTime(t, "2023-11-12T19:10:00+05:30")
+	assert.False(t, atm.InTradingWindow())
+
+	now = istTime(t, "2023-05-19T12:20:00+05:30")
+	assert.True(t, atm.InTradingWindow())
+	now = istTime(t, "2023-05-19T12:26:00+05:30")
+	assert.False(t, atm.InTradingWindow(), "the half day closes at 12:30")
+
+	assert.False(t, atm.IsTradingDay(istTime(t, "2023-05-17T10:00:00+05:30")))
+	assert.Equal(t, 16, atm.GetPreviousNonWeekendNonHolidayDate(istTime(t, "2023-05-18T10:00:00+05:30")).Day())
+}
+
+func TestEveningSessionTradingWindow(t *testing.T) {
+	now := istTime(t, "2023-05-16T21:00:00+05:30")
+	atm := newTestATMcs(t, map[string]interface{}{
+		"calendar_file_path": writeCalendarFile(t),
+		"exchange":           "MCX",
+	}, func() time.Time { return now })
+
+	assert.True(t, atm.InTradingWindow())
+	now = istTime(t, "2023-05-16T23:20:00+05:30")
+	assert.False(t, atm.InTradingWindow())
+	// the day session runs straight into the evening one
+	now = istTime(t, "2023-05-16T16:50:00+05:30")
+	assert.True(t, atm.InTradingWindow())
+	assert.Len(t, atm.TradingSessions(now), 1)
+	assert.Equal(t, 9*time.Hour, atm.candleSession().Open)
+	assert.Equal(t, 23*time.Hour+30*time.Minute, atm.candleSession().Close)
+}
+
+func TestInvalidCalendarSettings(t *testing.T) {
+	atm := &ATMcs{}
+	atm.Settings.CalendarFilePath = writeCalendarFile(t)
+	atm.Settings.Exchange = "BSE"
+	assert.NotNil(t, atm.loadCalendar())
+
+	atm.Settings.CalendarFilePath = filepath.Join(t.TempDir(), "missing.json")
+	assert.NotNil(t, atm.loadCalendar())
+
+	atm.Settings.CalendarFilePath = ""
+	assert.Nil(t, atm.loadCalendar())
+	window := TradingWindow{OpenOffset: &DurationWrapper{7 * time.Hour}}
+	assert.NotNil(t, window.validate(atm.Calendar))
+	assert.Nil(t, TradingWindow{}.validate(atm.Calendar))
+}
@@ -1,6 +1,8 @@
 package atmcs
 
 import (
+	"math"
+
 	"github.com/dragonzurfer/revclose"
 	"github.com/dragonzurfer/trader/executor"
 )
@@ -46,3 +48,56 @@ func NewCandleAdapter(candles []executor.CandleLike) *CandleAdapter {
 		candles: candles,
 	}
 }
+
+// AggregateCandle is a run of candles taken as one: the first open, the
+// highest high, the lowest low and the last close.
+type AggregateCandle struct {
+	candles []executor.CandleLike
+}
+
+// NewAggregateCandle returns nil when there are no candles.
+func NewAggregateCandle(candles []executor.CandleLike) executor.CandleLike {
+	if len(candles) == 0 {
+		return nil
+	}
+	if len(candles) == 1 {
+		return candles[0]
+	}
+	return &AggregateCandle{candles: candles}
+}
+
+func (ac *AggregateCandle) GetOpen() float64 {
+	return ac.candles[0].GetOpen()
+}
+
+func (ac *AggregateCandle) GetHigh() float64 {
+	high := ac.candles[0].GetHigh()
+	for _, candle := range ac.candles[1:] {
+		high = math.Max(high, candle.GetHigh())
+	}
+	return high
+}
+
+func (ac *AggregateCandle) GetLow() float64 {
+	low := ac.candles[0].GetLow()
+	for _, candle := range ac.candles[1:] {
+		low = math.Min(low, candle.GetLow())
+	}
+	return low
+}
+
+func (ac *AggregateCandle) GetClose() float64 {
+	return ac.candles[len(ac.candles)-1].GetClose()
+}
+
+func (ac *AggregateCandle) GetVolume() float64 {
+	volume := 0.0
+	for _, candle := range ac.candles {
+		volume += candle.GetVolume()
+	}
+	return volume
+}
+
+func (ac *AggregateCandle) GetOI() float64 {
+	return ac.candles[len(ac.candles)-1].GetOI()
+}
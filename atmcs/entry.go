@@ -14,11 +14,26 @@ import (
 )
 
 func (obj *ATMcs) PaperTrade(tradeType executor.TradeType) {
+	entryPositions := obj.makeEntryPositions(tradeType)
+	if entryPositions == nil {
+		obj.addError(errors.New("PaperTrade(): failed to make entry positions"))
+		return
+	}
+	entryPositions, err := obj.checkRisk(entryPositions)
+	if err != nil {
+		obj.addError(fmt.Errorf("PaperTrade(): %w", err))
+		return
+	}
 	obj.Trade.InTrade = true
-	obj.Trade.EntryPositions = obj.makeEntryPositions(tradeType)
+	obj.Trade.EntryPositions = entryPositions
+	obj.Trade.RiskReason = obj.RiskReason
 	obj.Trade.TimeOfEntry = obj.GetCurrentTime()
 	obj.Trade.IsMinTrailHit = false
 	obj.Trade.IsStopLossHit = false
+	obj.Trade.InitialStopLossPrice = obj.Trade.StopLossPrice
+	obj.Trade.BestPrice = 0
+	obj.Trade.IsTrailActive = false
+	obj.setExitReason("", "")
 }
 
 func (obj *ATMcs) makeEntryPositions(tradeType executor.TradeType) []trade.OptionPosition {
@@ -29,51 +44,64 @@ func (obj *ATMcs) makeEntryPositions(tradeType executor.TradeType) []trade.Optio
 		return nil
 	}
 
-	strike := GetNearest100ITMStrike(ltp, tradeType)
-
 	expiries, err := obj.Broker.GetOptionExpiries(obj.Symbol)
 	if err != nil {
 		log.Println(err.Error())
 		return nil
 	}
+	if err := obj.CheckExpiries(expiries); err != nil {
+		obj.addError(err)
+		if obj.Settings.ExpiryCheck.Strict {
+			return nil
+		}
+	}
 
-	sellExpiry, err := GetExpiry(obj.GetCurrentTime(), obj.MinDaysToExpiry, strike, expiries)
+	// The strike is picked after the expiries since delta and premium
+	// targeting need them.
+	sellExpiry, err := obj.SelectSellExpiry(expiries)
 	if err != nil {
 		log.Println(err.Error())
 		return nil
 	}
 
-	buyExpiry, err := GetMonthlyExpiryCalendarSpread(obj.GetCurrentTime(), sellExpiry.ExpiryDate, expiries)
+	selector, err := obj.strikeSelector()
 	if err != nil {
 		log.Println(err.Error())
 		return nil
 	}
-
-	symbol := obj.Symbol
-
-	quantity := obj.Quantity
-	var sellPosition, buyPosition trade.OptionPosition
-	var entryPositions []trade.OptionPosition
-	if tradeType == executor.Buy {
-		sellPosition = obj.MakeEntryPosition(symbol, strike, sellExpiry, executor.PutOption, executor.Sell, quantity)
-		buyPosition = obj.MakeEntryPosition(symbol, strike, buyExpiry, executor.PutOption, executor.Buy, quantity/2)
-	} else {
-		sellPosition = obj.MakeEntryPosition(symbol, strike, sellExpiry, executor.CallOption, executor.Sell, quantity)
-		buyPosition = obj.MakeEntryPosition(symbol, strike, buyExpiry, executor.CallOption, executor.Buy, quantity/2)
-	}
-	bids, err := GetBids(obj.Broker, sellPosition)
+	strike, err := selector.SelectStrike(StrikeQuery{
+		LTP:        ltp,
+		Expiry:     sellExpiry.ExpiryDate,
+		OptionType: signalOptionType(tradeType),
+		Time:       obj.GetCurrentTime(),
+		Broker:     obj.Broker,
+	})
 	if err != nil {
 		log.Println(err.Error())
 		return nil
 	}
-	asks, err := GetAsks(obj.Broker, buyPosition)
+
+	quantity, err := obj.entryQuantity(ltp)
 	if err != nil {
-		log.Println(err.Error())
+		obj.addError(err)
 		return nil
 	}
-	sellPosition.Price, obj.Trade.DepthQuantityEntrySell = obj.GetAvgMarketDepth(bids)
-	buyPosition.Price, obj.Trade.DepthQuantityEntryBuy = obj.GetAvgMarketDepth(asks)
-	entryPositions = append(entryPositions, sellPosition, buyPosition)
+
+	var entryPositions []trade.OptionPosition
+	obj.Trade.DepthQuantityEntrySell, obj.Trade.DepthQuantityEntryBuy = 0, 0
+	for _, leg := range obj.legTemplates() {
+		position, depthQuantity, err := obj.makeLegPosition(leg, tradeType, strike, quantity, sellExpiry, expiries)
+		if err != nil {
+			log.Println(err.Error())
+			return nil
+		}
+		if leg.Side == executor.Sell {
+			obj.Trade.DepthQuantityEntrySell += depthQuantity
+		} else {
+			obj.Trade.DepthQuantityEntryBuy += depthQuantity
+		}
+		entryPositions = append(entryPositions, position)
+	}
 	return entryPositions
 }
 
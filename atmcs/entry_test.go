@@ -152,6 +152,22 @@ func (b *TestBroker) GetCandlesOption(float64, time.Time, executor.OptionType, t
 	return nil, nil
 }
 
+func (b *TestBroker) PlaceOrder(executor.OrderRequest) (string, error) {
+	return "", errors.New("order placement not supported")
+}
+
+func (b *TestBroker) ModifyOrder(string, executor.OrderRequest) error {
+	return errors.New("order placement not supported")
+}
+
+func (b *TestBroker) CancelOrder(string) error {
+	return errors.New("order placement not supported")
+}
+
+func (b *TestBroker) GetOrderStatus(string) (executor.OrderUpdate, error) {
+	return executor.OrderUpdate{}, errors.New("order placement not supported")
+}
+
 func TestPaperTrade(t *testing.T) {
 	log.SetFlags(log.Lshortfile)
 	testCases := []string{
@@ -50,7 +50,7 @@ type SceneATMcs struct {
 	SettingsFilesPath string
 	GetCurrentTime    func() time.Time `json:"-"`
 	Holidays
-	StopLossHitChan chan bool `json:"-"`
+	EventChan chan executor.Event `json:"-"`
 }
 
 type TickPrice struct {
@@ -65,18 +65,16 @@ type TimeState struct {
 }
 
 type TestScenario struct {
-	CurrentTime      time.Time            `json:"current_time"`
-	TickPrices       []TickPrice          `json:"tick_prices"`
-	TimeStates       []TimeState          `json:"time_states"`
-	Expiries         []executor.Expiry    `json:"option_chains"`
-	OptionDepths     map[time.Time]BidAsk `json:"option_depths"`
-	TimeObj          *time.Time
-	SlHitChannel     <-chan bool
-	TargetHitChannel <-chan bool
-	TrailChannel     <-chan bool
-	T                *testing.T
-	atm              executor.ExecutorLike
-	atmRef           *atmcs.ATMcs
+	CurrentTime  time.Time            `json:"current_time"`
+	TickPrices   []TickPrice          `json:"tick_prices"`
+	TimeStates   []TimeState          `json:"time_states"`
+	Expiries     []executor.Expiry    `json:"option_chains"`
+	OptionDepths map[time.Time]BidAsk `json:"option_depths"`
+	TimeObj      *time.Time
+	EventChannel <-chan executor.Event
+	T            *testing.T
+	atm          executor.ExecutorLike
+	atmRef       *atmcs.ATMcs
 }
 
 type TestScenarioBroker struct {
@@ -157,6 +155,22 @@ func (b *TestScenarioBroker) GetCandlesOption(float64, time.Time, executor.Optio
 	return nil, nil
 }
 
+func (b *TestScenarioBroker) PlaceOrder(executor.OrderRequest) (string, error) {
+	return "", errors.New("order placement not supported")
+}
+
+func (b *TestScenarioBroker) ModifyOrder(string, executor.OrderRequest) error {
+	return errors.New("order placement not supported")
+}
+
+func (b *TestScenarioBroker) CancelOrder(string) error {
+	return errors.New("order placement not supported")
+}
+
+func (b *TestScenarioBroker) GetOrderStatus(string) (executor.OrderUpdate, error) {
+	return executor.OrderUpdate{}, errors.New("order placement not supported")
+}
+
 func NewScenarioBroker(t *testing.T) TestScenarioBroker {
 	type Creds struct {
 		AccessToken string `json:"access_token"`
@@ -401,12 +415,7 @@ func TestFlow(t *testing.T) {
 				}
 				if inTrade {
 					// t.SkipNow()
-					slHitChannel := atm.GetStopLossHitChan()
-					targetHitChannel := atm.GetTargetHitChan()
-					trailChannel := atmRef.GetTrailChan()
-					testCase.TrailChannel = trailChannel
-					testCase.SlHitChannel = slHitChannel
-					testCase.TargetHitChannel = targetHitChannel
+					testCase.EventChannel = atm.GetEventChan()
 					fmt.Println("listening to channels")
 					testCase.listenToChannels()
 					err := atm.LogTrade()
@@ -478,42 +487,22 @@ func (scene *TestScenario) listenToChannels() {
 
 	for {
 		select {
-		case slHit, ok := <-scene.SlHitChannel:
-			if ok {
-				if slHit {
-					fmt.Println("Stop Loss hit!")
-					// scene.atm.ExitPaper()
-					return
-				} else {
-					fmt.Println("SL not hit")
-					return
-				}
-			} else {
-				scene.T.Fatal("sl Channel closed")
+		case event, ok := <-scene.EventChannel:
+			if !ok {
+				scene.T.Fatal("event Channel closed")
 				return
 			}
-		case targetHit, ok := <-scene.TargetHitChannel:
-			if ok {
-				if targetHit {
-					fmt.Println("Target hit!")
-					// scene.atm.ExitPaper()
-					return
-				} else {
-					fmt.Println("target not hit")
-					return
-				}
-			} else {
-				scene.T.Fatal("target Channel closed")
-
+			switch event.Reason {
+			case executor.ExitStopLoss:
+				fmt.Println("Stop Loss hit!")
 				return
-			}
-		case trail, ok := <-scene.TrailChannel:
-			if ok {
-				if trail {
-					fmt.Println("Trail to cost")
-				}
-			} else {
-				scene.T.Fatal("trail Channel closed")
+			case executor.ExitTarget:
+				fmt.Println("Target hit!")
+				return
+			case executor.TrailAdjusted:
+				fmt.Println("Trail to cost")
+			default:
+				fmt.Println("exited on", event)
 				return
 			}
 		case <-marketCloseChan:
@@ -11,6 +11,10 @@ import (
 )
 
 func (obj *ATMcs) IsEntrySatisfied() bool {
+	if detail, ok := obj.entryTimeExit(obj.GetCurrentTime()); ok {
+		log.Println("IsEntrySatisfied(): no entry while the", detail, "time exit is due")
+		return false
+	}
 
 	if err := obj.SetSignal(); err != nil {
 		log.Println("IsEntrySatisfied() failed:", err.Error())
@@ -21,34 +25,42 @@ func (obj *ATMcs) IsEntrySatisfied() bool {
 }
 
 func (obj *ATMcs) SetSignal() error {
-	minTargetPercent := obj.Settings.MinTargetPercent
-	minSLPercent := obj.Settings.MinStopLossPercent
-	currentDayCandles, previousDayCandle, err := obj.GetCandles()
+	provider, err := obj.signalProvider()
+	if err != nil {
+		return fmt.Errorf("error in SetSignal():%w", err)
+	}
+	currentDayCandles, previousDayCandles, err := obj.GetCandles()
+	if err != nil {
+		return fmt.Errorf("error in SetSignal():%w", err)
+	}
+	signal, err := provider.GetSignal(executor.SignalInput{
+		CurrentDayCandles: currentDayCandles,
+		PreviousDayCandle: NewAggregateCandle(previousDayCandles),
+		Time:              obj.GetCurrentTime(),
+	})
 	if err != nil {
 		return fmt.Errorf("error in SetSignal():%w", err)
 	}
-	obj.SignalCPR = cpr.GetCPRSignal(minSLPercent, minTargetPercent, previousDayCandle, currentDayCandles)
+	obj.Signal = signal
+	if cprSignal, ok := signal.Metadata["cpr"].(cpr.Signal); ok {
+		obj.SignalCPR = cprSignal
+	}
 	return nil
 }
 
 func (obj *ATMcs) SetEntryStates() {
-	if obj.SignalCPR.Signal == cpr.Neutral {
+	if obj.Signal.IsNeutral() {
 		return
 	}
-	obj.Trade.StopLossPrice = obj.SignalCPR.StopLossPrice
-	obj.Trade.EntryPrice = obj.SignalCPR.EntryPrice
-	obj.Trade.TargetPrice = obj.SignalCPR.TargetPrice
-	switch obj.SignalCPR.Signal {
-	case cpr.Buy:
-		obj.Trade.TradeType = executor.Buy
-	case cpr.Sell:
-		obj.Trade.TradeType = executor.Sell
-	}
+	obj.Trade.StopLossPrice = obj.Signal.StopLossPrice
+	obj.Trade.EntryPrice = obj.Signal.EntryPrice
+	obj.Trade.TargetPrice = obj.Signal.TargetPrice
+	obj.Trade.TradeType = obj.Signal.Direction
 	obj.ExitSatisfied = false
 	obj.EntrySatisfied = true
 }
 
-func (obj *ATMcs) GetCandles() (cpr.CPRCandles, cpr.CPRCandles, error) {
+func (obj *ATMcs) GetCandles() ([]executor.CandleLike, []executor.CandleLike, error) {
 	currentTime := obj.GetCurrentTime()
 	previousDate := obj.GetPreviousNonWeekendNonHolidayDate(currentTime)
 	currentDay5minCandles, err := obj.GetCurrentDayCandleData5minFyers(currentTime)
@@ -59,35 +71,22 @@ func (obj *ATMcs) GetCandles() (cpr.CPRCandles, cpr.CPRCandles, error) {
 	if err != nil {
 		return nil, nil, errors.New("error in GetCandles() getting previous day candle data: " + err.Error())
 	}
-	if currentDay5minCandles.GetCandlesLength() < 1 {
+	if len(currentDay5minCandles) < 1 {
 		return nil, nil, errors.New("zero candles returned on 5minute data API call to broker")
 	}
-	if previousDayCandles.GetCandlesLength() < 1 {
+	if len(previousDayCandles) < 1 {
 		return nil, nil, errors.New("zero candles returned on 1Day data API call to broker")
 	}
 	return currentDay5minCandles, previousDayCandles, nil
 }
 
-func (obj *ATMcs) GetPreviousDayCandleDataFyers(previousDate time.Time) (cpr.CPRCandles, error) {
-	from := time.Date(previousDate.Year(), previousDate.Month(), previousDate.Day(), 9, 15, 0, 0, obj.ISTLocation)
-	to := time.Date(previousDate.Year(), previousDate.Month(), previousDate.Day(), 15, 30, 0, 0, obj.ISTLocation)
-	candles, err := obj.Broker.GetCandles(obj.Symbol, from, to, executor.Day)
-	if err != nil {
-		return nil, err
-	}
-	cprCandles := NewCandleAdapter(candles)
-	return cprCandles, err
+func (obj *ATMcs) GetPreviousDayCandleDataFyers(previousDate time.Time) ([]executor.CandleLike, error) {
+	from, to := obj.sessionBounds(previousDate)
+	return obj.candleStore().GetCandles(obj.Symbol, from, to, executor.Day)
 }
 
-func (obj *ATMcs) GetCurrentDayCandleData5minFyers(currentTime time.Time) (cpr.CPRCandles, error) {
-	from := time.Date(currentTime.Year(), currentTime.Month(), currentTime.Day(), 9, 15, 0, 0, obj.ISTLocation)
-	to := currentTime
-	candles, err := obj.Broker.GetCandles(obj.Symbol, from, to, executor.Minute5)
-	if err != nil {
-		return nil, err
-	}
-	cprCandles := NewCandleAdapter(candles)
-	return cprCandles, nil
+func (obj *ATMcs) GetCurrentDayCandleData5minFyers(currentTime time.Time) ([]executor.CandleLike, error) {
+	return obj.GetCurrentDayCandles(currentTime, executor.Minute5)
 }
 
 type Holidays struct {
@@ -103,6 +102,8 @@ func isDateHoliday(date string, holidays *Holidays) bool {
 	return false
 }
 
+// WasMarketOpen only knows the NSE weekend and the given holidays, use
+// ATMcs.IsTradingDay to follow the configured exchange calendar.
 func WasMarketOpen(date time.Time, holidays *Holidays) bool {
 	loc, err := time.LoadLocation("Asia/Kolkata")
 	if err != nil {
@@ -125,10 +126,8 @@ func WasMarketOpen(date time.Time, holidays *Holidays) bool {
 	return true
 }
 
+// GetPreviousNonWeekendNonHolidayDate is currentDate moved back to the
+// last trading day on the exchange calendar before it.
 func (obj *ATMcs) GetPreviousNonWeekendNonHolidayDate(currentDate time.Time) time.Time {
-	previousDate := currentDate.AddDate(0, 0, -1)
-	for !WasMarketOpen(previousDate, &obj.Holidays) {
-		previousDate = previousDate.AddDate(0, 0, -1)
-	}
-	return previousDate
+	return obj.ExchangeCalendar().PreviousTradingDay(currentDate)
 }
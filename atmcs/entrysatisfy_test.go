@@ -125,6 +125,22 @@ func (b *RealBroker) GetCandlesOption(float64, time.Time, executor.OptionType, t
 	return nil, nil
 }
 
+func (b *RealBroker) PlaceOrder(executor.OrderRequest) (string, error) {
+	return "", errors.New("order placement not supported")
+}
+
+func (b *RealBroker) ModifyOrder(string, executor.OrderRequest) error {
+	return errors.New("order placement not supported")
+}
+
+func (b *RealBroker) CancelOrder(string) error {
+	return errors.New("order placement not supported")
+}
+
+func (b *RealBroker) GetOrderStatus(string) (executor.OrderUpdate, error) {
+	return executor.OrderUpdate{}, errors.New("order placement not supported")
+}
+
 func NewRealBroker(t *testing.T) RealBroker {
 	type Creds struct {
 		AccessToken string `json:"access_token"`
@@ -4,13 +4,12 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/dragonzurfer/trader/atmcs/trade"
 	"github.com/dragonzurfer/trader/executor"
 )
 
-func (obj *ATMcs) ExitAccount() {}
-
 func (obj *ATMcs) ExitPaper() {
 	if !obj.Trade.InTrade {
 		return
@@ -22,6 +21,10 @@ func (obj *ATMcs) ExitPaper() {
 		return
 	}
 
+	obj.closeTrade(exitPositions)
+}
+
+func (obj *ATMcs) closeTrade(exitPositions []trade.OptionPosition) {
 	// Clear the current trade
 	obj.Trade.ExitPositions = exitPositions
 	obj.Trade.InTrade = false
@@ -31,10 +34,28 @@ func (obj *ATMcs) ExitPaper() {
 	obj.Trade.TargetPrice = 0
 	obj.Trade.TradeType = executor.Nuetral
 	obj.Trade.IsMinTrailHit = false
-	obj.Trade.IsStopLossHit = true
+	obj.Trade.BestPrice = 0
+	obj.Trade.IsTrailActive = false
+	if obj.Trade.ExitReason == "" {
+		obj.Trade.ExitReason = executor.ExitManual
+	}
+	obj.Trade.IsStopLossHit = obj.Trade.ExitReason == executor.ExitStopLoss
 	obj.ExitSatisfied = true
 	obj.EntrySatisfied = false
+	obj.lastPremiumCheck = time.Time{}
+}
+
+// SetExitReason records why the runner is about to exit the trade, as on a
+// breach of its risk limits.
+func (obj *ATMcs) SetExitReason(reason executor.ExitReason, detail string) {
+	obj.setExitReason(reason, detail)
+}
 
+// setExitReason records why the trade is about to be exited. Exits with no
+// reason recorded are manual.
+func (obj *ATMcs) setExitReason(reason executor.ExitReason, detail string) {
+	obj.Trade.ExitReason = reason
+	obj.Trade.ExitDetail = detail
 }
 
 func (obj *ATMcs) MakeExitPositions() ([]trade.OptionPosition, error) {
@@ -0,0 +1,240 @@
+package atmcs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dragonzurfer/trader/executor"
+)
+
+type ExpiryRule string
+
+const (
+	// MinDaysExpiry is the default sell rule: GetExpiry with
+	// Settings.MinDaysToExpiry. It only applies to the sell leg.
+	MinDaysExpiry ExpiryRule = "min_days"
+	// MonthlyCalendarExpiry is the default hedge rule:
+	// GetMonthlyExpiryCalendarSpread. It only applies to the hedge leg.
+	MonthlyCalendarExpiry ExpiryRule = "monthly_calendar"
+	// WeeklyExpiry is the N-th qualifying expiry from today.
+	WeeklyExpiry ExpiryRule = "weekly"
+	// MonthlyExpiry is the N-th qualifying monthly expiry from today, a
+	// monthly expiry being the last of its month.
+	MonthlyExpiry ExpiryRule = "monthly"
+	// NextAfterSellExpiry is the N-th qualifying expiry after the sell
+	// leg's. It only applies to the hedge leg.
+	NextAfterSellExpiry ExpiryRule = "next_after_sell"
+	// SellLegExpiry is the sell leg's expiry. It only applies to the legs
+	// of a template.
+	SellLegExpiry ExpiryRule = "sell"
+)
+
+// ExpiryPolicy picks the expiry of one leg of the spread. Expiries outside
+// the days to expiry window are never picked, and the hedge always expires
+// after the sell leg.
+type ExpiryPolicy struct {
+	Rule ExpiryRule `json:"rule"`
+	// N counts qualifying expiries from 1; 0 means 1.
+	N int64 `json:"n"`
+	// MinDTE and MaxDTE bound the days to expiry, calendar days unless
+	// Settings.TradingDaysToExpiry is set. A zero MaxDTE leaves it
+	// unbounded.
+	MinDTE int64 `json:"min_dte"`
+	MaxDTE int64 `json:"max_dte"`
+	// SkipExpiryDay never picks an expiry falling today.
+	SkipExpiryDay bool `json:"skip_expiry_day"`
+}
+
+// validate checks the policy for use on leg: "sell", "hedge", or "leg"
+// for a leg of a template, which may use any rule.
+func (p ExpiryPolicy) validate(leg string) error {
+	switch p.Rule {
+	case "", WeeklyExpiry, MonthlyExpiry:
+	case MinDaysExpiry:
+		if leg == "hedge" {
+			return fmt.Errorf("expiry rule %v does not apply to the hedge leg", p.Rule)
+		}
+	case MonthlyCalendarExpiry, NextAfterSellExpiry:
+		if leg == "sell" {
+			return fmt.Errorf("expiry rule %v does not apply to the sell leg", p.Rule)
+		}
+	case SellLegExpiry:
+		if leg != "leg" {
+			return fmt.Errorf("expiry rule %v only applies to template legs", p.Rule)
+		}
+	default:
+		return fmt.Errorf("unknown %v expiry rule %q", leg, p.Rule)
+	}
+	if p.N < 0 || p.MinDTE < 0 || p.MaxDTE < 0 {
+		return fmt.Errorf("%v expiry n, min_dte and max_dte cannot be negative", leg)
+	}
+	if p.MaxDTE > 0 && p.MaxDTE < p.MinDTE {
+		return fmt.Errorf("%v expiry max_dte %v is below min_dte %v", leg, p.MaxDTE, p.MinDTE)
+	}
+	return nil
+}
+
+func (p ExpiryPolicy) String() string {
+	n := p.N
+	if n == 0 {
+		n = 1
+	}
+	rule := p.Rule
+	if rule == "" {
+		rule = "default"
+	}
+	description := fmt.Sprintf("%v n=%v", rule, n)
+	if p.MinDTE > 0 || p.MaxDTE > 0 {
+		description += fmt.Sprintf(" dte=[%v,%v]", p.MinDTE, p.MaxDTE)
+	}
+	if p.SkipExpiryDay {
+		description += " skipping expiry day"
+	}
+	return description
+}
+
+// daysToExpiry counts calendar days from now's date to expiry's date.
+func daysToExpiry(now, expiry time.Time) int64 {
+	expiry = expiry.In(now.Location())
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	expiryDay := time.Date(expiry.Year(), expiry.Month(), expiry.Day(), 0, 0, 0, 0, time.UTC)
+	return int64(expiryDay.Sub(today).Hours() / 24)
+}
+
+// dteFunc counts the days from now to expiry.
+type dteFunc func(now, expiry time.Time) int64
+
+// daysToExpiry counts days to expiry the way Settings.TradingDaysToExpiry
+// asks for.
+func (obj *ATMcs) daysToExpiry(now, expiry time.Time) int64 {
+	if obj.Settings.TradingDaysToExpiry {
+		return int64(obj.ExchangeCalendar().TradingDaysBetween(now, expiry))
+	}
+	return daysToExpiry(now, expiry)
+}
+
+// qualifying returns the expiries inside the policy's window in date order.
+func (p ExpiryPolicy) qualifying(now time.Time, expiries []executor.Expiry, dte dteFunc) []executor.Expiry {
+	var qualifying []executor.Expiry
+	for _, expiry := range expiries {
+		days := dte(now, expiry.ExpiryDate)
+		if days < 0 || days < p.MinDTE || (p.MaxDTE > 0 && days > p.MaxDTE) {
+			continue
+		}
+		if p.SkipExpiryDay && days == 0 {
+			continue
+		}
+		qualifying = append(qualifying, expiry)
+	}
+	sort.SliceStable(qualifying, func(i, j int) bool {
+		return qualifying[i].ExpiryDate.Before(qualifying[j].ExpiryDate)
+	})
+	return qualifying
+}
+
+// isMonthly reports whether expiry is the last of its month in expiries.
+func isMonthly(expiry executor.Expiry, expiries []executor.Expiry) bool {
+	year, month := expiry.ExpiryDate.Year(), expiry.ExpiryDate.Month()
+	for _, other := range expiries {
+		if other.ExpiryDate.Year() == year && other.ExpiryDate.Month() == month && other.ExpiryDate.After(expiry.ExpiryDate) {
+			return false
+		}
+	}
+	return true
+}
+
+// selectExpiry applies the policy. sellExpiry is zero for the sell leg.
+func (p ExpiryPolicy) selectExpiry(now time.Time, sellExpiry time.Time, expiries []executor.Expiry, dte dteFunc) (executor.Expiry, error) {
+	n := p.N
+	if n == 0 {
+		n = 1
+	}
+	var candidates []executor.Expiry
+	for _, expiry := range p.qualifying(now, expiries, dte) {
+		switch p.Rule {
+		case MonthlyExpiry:
+			if !isMonthly(expiry, expiries) {
+				continue
+			}
+		case NextAfterSellExpiry:
+			if !expiry.ExpiryDate.After(sellExpiry) {
+				continue
+			}
+		}
+		candidates = append(candidates, expiry)
+	}
+	if int64(len(candidates)) < n {
+		return executor.Expiry{}, fmt.Errorf("no expiry qualifies for %v on %v among %v", p, now.Format("2006-01-02"), formatExpiries(expiries))
+	}
+	return candidates[n-1], nil
+}
+
+func formatExpiries(expiries []executor.Expiry) string {
+	dates := make([]string, len(expiries))
+	for i, expiry := range expiries {
+		dates[i] = expiry.ExpiryDate.Format("2006-01-02")
+	}
+	return "[" + strings.Join(dates, " ") + "]"
+}
+
+// SelectSellExpiry picks the sell leg's expiry per Settings.SellExpiry.
+func (obj *ATMcs) SelectSellExpiry(expiries []executor.Expiry) (executor.Expiry, error) {
+	policy := obj.Settings.SellExpiry
+	if policy.Rule == "" {
+		policy.Rule = MinDaysExpiry
+	}
+	expiry, err := obj.resolveExpiry(policy, executor.Expiry{}, expiries)
+	if err != nil {
+		return executor.Expiry{}, fmt.Errorf("SelectSellExpiry(): %w", err)
+	}
+	return expiry, nil
+}
+
+// SelectHedgeExpiry picks the hedge leg's expiry per Settings.HedgeExpiry,
+// which must come after sellExpiry.
+func (obj *ATMcs) SelectHedgeExpiry(sellExpiry executor.Expiry, expiries []executor.Expiry) (executor.Expiry, error) {
+	policy := obj.Settings.HedgeExpiry
+	if policy.Rule == "" {
+		policy.Rule = MonthlyCalendarExpiry
+	}
+	expiry, err := obj.resolveExpiry(policy, sellExpiry, expiries)
+	if err != nil {
+		return executor.Expiry{}, fmt.Errorf("SelectHedgeExpiry(): %w", err)
+	}
+	if !expiry.ExpiryDate.After(sellExpiry.ExpiryDate) {
+		return executor.Expiry{}, fmt.Errorf("SelectHedgeExpiry(): hedge expiry %v is not after sell expiry %v for %v",
+			expiry.ExpiryDate.Format("2006-01-02"), sellExpiry.ExpiryDate.Format("2006-01-02"), policy)
+	}
+	return expiry, nil
+}
+
+// resolveExpiry applies policy relative to the sell leg's expiry.
+func (obj *ATMcs) resolveExpiry(policy ExpiryPolicy, sellExpiry executor.Expiry, expiries []executor.Expiry) (executor.Expiry, error) {
+	now := obj.GetCurrentTime()
+	switch policy.Rule {
+	case MinDaysExpiry:
+		qualifying := policy.qualifying(now, expiries, obj.daysToExpiry)
+		if obj.Settings.TradingDaysToExpiry {
+			return obj.minTradingDaysExpiry(now, qualifying)
+		}
+		return GetExpiry(now, obj.MinDaysToExpiry, 0, qualifying)
+	case MonthlyCalendarExpiry:
+		return GetMonthlyExpiryCalendarSpread(now, sellExpiry.ExpiryDate, policy.qualifying(now, expiries, obj.daysToExpiry))
+	case SellLegExpiry:
+		return sellExpiry, nil
+	}
+	return policy.selectExpiry(now, sellExpiry.ExpiryDate, expiries, obj.daysToExpiry)
+}
+
+// minTradingDaysExpiry is GetExpiry counting trading days: the first of
+// the expiries, in date order, at least MinDaysToExpiry trading days away.
+func (obj *ATMcs) minTradingDaysExpiry(now time.Time, expiries []executor.Expiry) (executor.Expiry, error) {
+	for _, expiry := range expiries {
+		if obj.daysToExpiry(now, expiry.ExpiryDate) >= obj.MinDaysToExpiry {
+			return expiry, nil
+		}
+	}
+	return executor.Expiry{}, fmt.Errorf("could not find an expiry that has %v trading days to expiry among %v", obj.MinDaysToExpiry, formatExpiries(expiries))
+}
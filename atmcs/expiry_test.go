@@ -0,0 +1,163 @@
+package atmcs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dragonzurfer/trader/executor"
+	"github.com/stretchr/testify/assert"
+)
+
+func weeklyExpiries(loc *time.Location) []executor.Expiry {
+	var expiries []executor.Expiry
+	for _, date := range []string{"05-18", "05-25", "06-01", "06-08", "06-15", "06-22", "06-29", "07-27"} {
+		expiry, _ := time.ParseInLocation("2006-01-02", "2023-"+date, loc)
+		expiries = append(expiries, executor.Expiry{ExpiryDate: expiry})
+	}
+	return expiries
+}
+
+func TestSelectSellExpiry(t *testing.T) {
+	now := istTime(t, "2023-05-18T10:00:00+05:30")
+	tests := []struct {
+		policy   ExpiryPolicy
+		expected string
+	}{
+		// GetExpiry counts 6.6 days to 2023-05-25 from 10:00.
+		{ExpiryPolicy{}, "2023-06-01"},
+		{ExpiryPolicy{Rule: WeeklyExpiry}, "2023-05-18"},
+		{ExpiryPolicy{Rule: WeeklyExpiry, SkipExpiryDay: true}, "2023-05-25"},
+		{ExpiryPolicy{Rule: WeeklyExpiry, N: 2, SkipExpiryDay: true}, "2023-06-01"},
+		{ExpiryPolicy{Rule: WeeklyExpiry, MinDTE: 10, MaxDTE: 20}, "2023-06-01"},
+		{ExpiryPolicy{Rule: MonthlyExpiry}, "2023-05-25"},
+		{ExpiryPolicy{Rule: MonthlyExpiry, N: 2}, "2023-06-29"},
+		{ExpiryPolicy{Rule: MonthlyExpiry, MinDTE: 8}, "2023-06-29"},
+	}
+	for _, test := range tests {
+		obj := newTestATMcs(t, map[string]interface{}{"sell_expiry": test.policy}, func() time.Time { return now })
+		expiry, err := obj.SelectSellExpiry(weeklyExpiries(obj.ISTLocation))
+		assert.Nil(t, err, "%v", test.policy)
+		assert.Equal(t, test.expected, expiry.ExpiryDate.Format("2006-01-02"), "%v", test.policy)
+	}
+}
+
+func TestSelectSellExpiryReportsWhyNothingQualifies(t *testing.T) {
+	now := istTime(t, "2023-05-18T10:00:00+05:30")
+	obj := newTestATMcs(t, map[string]interface{}{
+		"sell_expiry": ExpiryPolicy{Rule: WeeklyExpiry, MinDTE: 2, MaxDTE: 5},
+	}, func() time.Time { return now })
+
+	_, err := obj.SelectSellExpiry(weeklyExpiries(obj.ISTLocation))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "no expiry qualifies for weekly n=1 dte=[2,5] on 2023-05-18")
+}
+
+func TestSelectHedgeExpiry(t *testing.T) {
+	now := istTime(t, "2023-05-18T10:00:00+05:30")
+	tests := []struct {
+		policy   ExpiryPolicy
+		expected string
+	}{
+		{ExpiryPolicy{}, "2023-06-29"},
+		{ExpiryPolicy{Rule: NextAfterSellExpiry}, "2023-06-01"},
+		{ExpiryPolicy{Rule: NextAfterSellExpiry, N: 3}, "2023-06-15"},
+		{ExpiryPolicy{Rule: WeeklyExpiry, N: 4}, "2023-06-08"},
+		{ExpiryPolicy{Rule: MonthlyExpiry, N: 3}, "2023-07-27"},
+	}
+	for _, test := range tests {
+		obj := newTestATMcs(t, map[string]interface{}{"hedge_expiry": test.policy}, func() time.Time { return now })
+		expiries := weeklyExpiries(obj.ISTLocation)
+		expiry, err := obj.SelectHedgeExpiry(expiries[1], expiries)
+		assert.Nil(t, err, "%v", test.policy)
+		assert.Equal(t, test.expected, expiry.ExpiryDate.Format("2006-01-02"), "%v", test.policy)
+	}
+
+	obj := newTestATMcs(t, map[string]interface{}{
+		"hedge_expiry": ExpiryPolicy{Rule: WeeklyExpiry},
+	}, func() time.Time { return now })
+	expiries := weeklyExpiries(obj.ISTLocation)
+	_, err := obj.SelectHedgeExpiry(expiries[1], expiries)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "is not after sell expiry")
+}
+
+func TestExpiryPolicyValidation(t *testing.T) {
+	assert.Nil(t, ExpiryPolicy{Rule: NextAfterSellExpiry}.validate("hedge"))
+	assert.NotNil(t, ExpiryPolicy{Rule: NextAfterSellExpiry}.validate("sell"))
+	assert.NotNil(t, ExpiryPolicy{Rule: MinDaysExpiry}.validate("hedge"))
+	assert.NotNil(t, ExpiryPolicy{Rule: "fortnightly"}.validate("sell"))
+	assert.NotNil(t, ExpiryPolicy{Rule: WeeklyExpiry, MinDTE: 10, MaxDTE: 5}.validate("sell"))
+}
+
+func TestTradingDaysToExpiry(t *testing.T) {
+	now := istTime(t, "2023-06-22T10:00:00+05:30")
+	policy := ExpiryPolicy{Rule: WeeklyExpiry, MinDTE: 5, SkipExpiryDay: true}
+
+	calendarDays := newTestATMcs(t, map[string]interface{}{"sell_expiry": policy}, func() time.Time { return now })
+	expiry, err := calendarDays.SelectSellExpiry(weeklyExpiries(calendarDays.ISTLocation))
+	assert.Nil(t, err)
+	assert.Equal(t, "2023-06-29", expiry.ExpiryDate.Format("2006-01-02"))
+
+	// the 28th of June is a holiday, leaving 4 trading days to the 29th
+	tradingDays := newTestATMcs(t, map[string]interface{}{
+		"sell_expiry":            policy,
+		"trading_days_to_expiry": true,
+	}, func() time.Time { return now })
+	assert.Equal(t, int64(4), tradingDays.daysToExpiry(now, expiry.ExpiryDate))
+	expiry, err = tradingDays.SelectSellExpiry(weeklyExpiries(tradingDays.ISTLocation))
+	assert.Nil(t, err)
+	assert.Equal(t, "2023-07-27", expiry.ExpiryDate.Format("2006-01-02"))
+
+	tradingDays.Settings.SellExpiry = ExpiryPolicy{}
+	tradingDays.Settings.MinDaysToExpiry = 5
+	expiry, err = tradingDays.SelectSellExpiry(weeklyExpiries(tradingDays.ISTLocation))
+	assert.Nil(t, err)
+	assert.Equal(t, "2023-07-27", expiry.ExpiryDate.Format("2006-01-02"))
+}
+
+func TestCheckExpiries(t *testing.T) {
+	now := istTime(t, "2023-05-16T10:20:00+05:30")
+	atm := newTestATMcs(t, map[string]interface{}{
+		"expiry_check": map[string]interface{}{"weekday": "Thursday"},
+	}, func() time.Time { return now })
+
+	err := atm.CheckExpiries(testExpiries(atm.ISTLocation))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "missing [2023-05-18 2023-06-08] unexpected []")
+	assert.Nil(t, atm.CheckExpiries(weeklyExpiries(atm.ISTLocation)))
+
+	atm.Settings.ExpiryCheck = ExpiryCheck{Weekday: "Thursday", Monthly: true, HorizonDays: 45}
+	err = atm.CheckExpiries(weeklyExpiries(atm.ISTLocation))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "missing [] unexpected [2023-05-18 2023-06-01 2023-06-08 2023-06-15 2023-06-22]")
+
+	// expiries due on a holiday are expected the trading day before
+	atm.Settings.ExpiryCheck = ExpiryCheck{Weekday: "Wednesday", Monthly: true, HorizonDays: 45}
+	expected, err := atm.ExpectedExpiries(now, now.Add(45*24*time.Hour))
+	assert.Nil(t, err)
+	assert.Len(t, expected, 2)
+	assert.Equal(t, "2023-06-27", expected[1].Format("2006-01-02"))
+}
+
+func TestStrictExpiryCheckSkipsEntry(t *testing.T) {
+	now := istTime(t, "2023-05-16T10:20:00+05:30")
+	atm := newTestATMcs(t, map[string]interface{}{
+		"expiry_check": map[string]interface{}{"weekday": "Thursday", "strict": true},
+	}, func() time.Time { return now })
+	atm.SetBroker(newFakeBroker(18310, testExpiries(atm.ISTLocation)))
+
+	assert.Nil(t, atm.makeEntryPositions(executor.Buy))
+	assert.True(t, atm.IsError())
+
+	atm.ReadErrors()
+	atm.Settings.ExpiryCheck.Strict = false
+	assert.NotNil(t, atm.makeEntryPositions(executor.Buy))
+	assert.True(t, atm.IsError())
+}
+
+func TestExpiryCheckValidation(t *testing.T) {
+	assert.Nil(t, ExpiryCheck{}.validate())
+	assert.Nil(t, ExpiryCheck{Weekday: "Thursday"}.validate())
+	assert.NotNil(t, ExpiryCheck{Weekday: "Thu"}.validate())
+	assert.NotNil(t, ExpiryCheck{Weekday: "Thursday", HorizonDays: -1}.validate())
+}
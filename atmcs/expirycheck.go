@@ -0,0 +1,116 @@
+package atmcs
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dragonzurfer/trader/executor"
+	"github.com/dragonzurfer/trader/executor/calendar"
+)
+
+const defaultExpiryCheckHorizonDays = 28
+
+// ExpiryCheck compares the broker's expiries with the ones the exchange
+// calendar expects before every entry. It is off when Weekday is empty.
+type ExpiryCheck struct {
+	// Weekday is the day of the weekly expiry, e.g. "Thursday". Expiries
+	// falling on a closed day are expected on the trading day before.
+	Weekday string `json:"weekday"`
+	// Monthly expects only the last Weekday of each month.
+	Monthly bool `json:"monthly"`
+	// HorizonDays is how many days ahead expiries are compared, 28 by
+	// default, as far expiries are listed sparsely.
+	HorizonDays int `json:"horizon_days"`
+	// Strict skips the entry when the expiries differ rather than only
+	// reporting it.
+	Strict bool `json:"strict"`
+}
+
+func (c ExpiryCheck) enabled() bool {
+	return c.Weekday != ""
+}
+
+func (c ExpiryCheck) validate() error {
+	if !c.enabled() {
+		return nil
+	}
+	if _, err := c.schedule(); err != nil {
+		return err
+	}
+	if c.HorizonDays < 0 {
+		return errors.New("expiry check horizon_days cannot be negative")
+	}
+	return nil
+}
+
+func (c ExpiryCheck) schedule() (calendar.ExpirySchedule, error) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if day.String() == c.Weekday {
+			return calendar.ExpirySchedule{Weekday: day, Monthly: c.Monthly}, nil
+		}
+	}
+	return calendar.ExpirySchedule{}, fmt.Errorf("expiry check weekday %q is not a weekday name", c.Weekday)
+}
+
+func (c ExpiryCheck) horizon() time.Duration {
+	days := c.HorizonDays
+	if days == 0 {
+		days = defaultExpiryCheckHorizonDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// ExpectedExpiries generates the expiry days of Settings.ExpiryCheck from
+// the exchange calendar between from and to.
+func (obj *ATMcs) ExpectedExpiries(from, to time.Time) ([]time.Time, error) {
+	schedule, err := obj.Settings.ExpiryCheck.schedule()
+	if err != nil {
+		return nil, fmt.Errorf("ExpectedExpiries(): %w", err)
+	}
+	return obj.ExchangeCalendar().Expiries(schedule, from, to), nil
+}
+
+// CheckExpiries reports the expected expiries within the horizon that the
+// broker does not list, and the listed ones that are not expected.
+func (obj *ATMcs) CheckExpiries(expiries []executor.Expiry) error {
+	check := obj.Settings.ExpiryCheck
+	if !check.enabled() {
+		return nil
+	}
+	now := obj.GetCurrentTime()
+	end := now.Add(check.horizon())
+	expected, err := obj.ExpectedExpiries(now, end)
+	if err != nil {
+		return fmt.Errorf("CheckExpiries(): %w", err)
+	}
+
+	exchange := obj.ExchangeCalendar()
+	listed := make(map[string]bool)
+	for _, expiry := range expiries {
+		day := exchange.Midnight(expiry.ExpiryDate)
+		if day.Before(exchange.Midnight(now)) || day.After(exchange.Midnight(end)) {
+			continue
+		}
+		listed[day.Format("2006-01-02")] = true
+	}
+	var missing, unexpected []string
+	for _, expiry := range expected {
+		date := expiry.Format("2006-01-02")
+		if !listed[date] {
+			missing = append(missing, date)
+		}
+		delete(listed, date)
+	}
+	for date := range listed {
+		unexpected = append(unexpected, date)
+	}
+	if len(missing) == 0 && len(unexpected) == 0 {
+		return nil
+	}
+	sort.Strings(unexpected)
+	return fmt.Errorf("CheckExpiries(): broker expiries of %v differ from the expected schedule: missing [%v] unexpected [%v]",
+		obj.Symbol, strings.Join(missing, " "), strings.Join(unexpected, " "))
+}
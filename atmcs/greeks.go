@@ -0,0 +1,157 @@
+package atmcs
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dragonzurfer/trader/atmcs/trade"
+	"github.com/dragonzurfer/trader/executor"
+	"github.com/dragonzurfer/trader/executor/pricing"
+)
+
+// PricingSettings values the legs of the trade, see TradeGreeks.
+type PricingSettings struct {
+	// Model is black_scholes, the default, or black76.
+	Model        pricing.Model `json:"model"`
+	RiskFreeRate float64       `json:"risk_free_rate"`
+	// UnderlyingSymbol is priced as the underlying instead of Symbol,
+	// e.g. the future of the expiry for black76.
+	UnderlyingSymbol string `json:"underlying_symbol"`
+	// ShortMarginPercent is the margin blocked by a sold leg as a percent
+	// of its notional, 12 by default, for the margin at risk of
+	// GetExposures.
+	ShortMarginPercent float64 `json:"short_margin_percent"`
+}
+
+const defaultShortMarginPercent = 12
+
+func (p PricingSettings) validate() error {
+	if err := p.Model.Validate(); err != nil {
+		return err
+	}
+	if p.RiskFreeRate < 0 {
+		return errors.New("pricing risk_free_rate cannot be negative")
+	}
+	if p.ShortMarginPercent < 0 || p.ShortMarginPercent > 100 {
+		return errors.New("pricing short_margin_percent must be within 0 and 100")
+	}
+	return nil
+}
+
+func (p PricingSettings) shortMarginPercent() float64 {
+	if p.ShortMarginPercent == 0 {
+		return defaultShortMarginPercent
+	}
+	return p.ShortMarginPercent
+}
+
+func (p PricingSettings) underlyingSymbol(symbol string) string {
+	if p.UnderlyingSymbol != "" {
+		return p.UnderlyingSymbol
+	}
+	return symbol
+}
+
+// LegGreeks values one entry leg at the mid of its market depth.
+type LegGreeks struct {
+	Position trade.OptionPosition
+	// Quantity is the part of Position still held, see
+	// trade.Trade.OpenQuantity.
+	Quantity          int64
+	Price             float64
+	ImpliedVolatility float64
+	// Greeks are per unit of the option and Exposure is Greeks for the
+	// whole position, negative for sold legs.
+	Greeks   pricing.Greeks
+	Exposure pricing.Greeks
+}
+
+type TradeGreeks struct {
+	Underlying float64
+	Legs       []LegGreeks
+	// Net is the sum of the exposures of the legs.
+	Net pricing.Greeks
+}
+
+// TradeGreeks implies the volatility of every entry leg still held from
+// its quotes and returns the Greeks of the legs and of the trade, net of
+// what was already exited.
+func (obj *ATMcs) TradeGreeks() (TradeGreeks, error) {
+	settings := obj.Settings.Pricing
+	underlying, err := obj.Broker.GetLTP(settings.underlyingSymbol(obj.Symbol))
+	if err != nil {
+		return TradeGreeks{}, fmt.Errorf("TradeGreeks(): %w", err)
+	}
+	now := obj.GetCurrentTime()
+	greeks := TradeGreeks{Underlying: underlying}
+	for i, position := range obj.Trade.EntryPositions {
+		open := obj.Trade.OpenQuantity(i)
+		if open == 0 {
+			continue
+		}
+		price, err := midPrice(obj.Broker, position.Strike, position.Expiry, position.Type)
+		if err != nil {
+			return TradeGreeks{}, fmt.Errorf("TradeGreeks(): %w", err)
+		}
+		contract := pricing.Contract{
+			Type:       position.Type,
+			Strike:     position.Strike,
+			Underlying: underlying,
+			Years:      pricing.YearsToExpiry(now, position.Expiry),
+			Rate:       settings.RiskFreeRate,
+		}
+		vol, err := settings.Model.ImpliedVolatility(contract, price)
+		if err != nil {
+			return TradeGreeks{}, fmt.Errorf("TradeGreeks(): %v %v %v: %w", position.Strike, position.Type, position.Expiry.Format("2006-01-02"), err)
+		}
+		leg := LegGreeks{
+			Position:          position,
+			Quantity:          open,
+			Price:             price,
+			ImpliedVolatility: vol,
+			Greeks:            settings.Model.Greeks(contract, vol),
+		}
+		quantity := float64(open)
+		if position.TradeType == executor.Sell {
+			quantity = -quantity
+		}
+		leg.Exposure = leg.Greeks.Scale(quantity)
+		greeks.Legs = append(greeks.Legs, leg)
+		greeks.Net = greeks.Net.Add(leg.Exposure)
+	}
+	return greeks, nil
+}
+
+// GetExposures reports the Greeks of the open trade, with its notional and
+// margin at risk, as one exposure on its underlying.
+func (obj *ATMcs) GetExposures() ([]executor.Exposure, error) {
+	if !obj.InTrade() {
+		return nil, nil
+	}
+	greeks, err := obj.TradeGreeks()
+	if err != nil {
+		return nil, fmt.Errorf("GetExposures(): %w", err)
+	}
+	exposure := executor.Exposure{
+		Underlying: obj.Symbol,
+		Delta:      greeks.Net.Delta,
+		Gamma:      greeks.Net.Gamma,
+		Theta:      greeks.Net.Theta,
+		Vega:       greeks.Net.Vega,
+	}
+	for _, leg := range greeks.Legs {
+		if leg.Position.UnderlyingSymbol != "" {
+			exposure.Underlying = leg.Position.UnderlyingSymbol
+		}
+		exposure.Quantity += leg.Quantity
+		quantity := float64(leg.Quantity)
+		notional := quantity * greeks.Underlying
+		exposure.Notional += notional
+		if leg.Position.TradeType == executor.Sell {
+			exposure.MarginAtRisk += notional * obj.Settings.Pricing.shortMarginPercent() / 100
+		} else {
+			exposure.MarginAtRisk += quantity * leg.Position.Price
+		}
+	}
+	return []executor.Exposure{exposure}, nil
+}
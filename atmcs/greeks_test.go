@@ -0,0 +1,112 @@
+package atmcs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dragonzurfer/trader/executor"
+	"github.com/dragonzurfer/trader/executor/pricing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTradeGreeks(t *testing.T) {
+	now := istTime(t, "2023-05-16T10:20:00+05:30")
+	atm, _, _ := enterPremiumTrade(t, nil, &now)
+
+	greeks, err := atm.TradeGreeks()
+	assert.Nil(t, err)
+	assert.Equal(t, 18310.0, greeks.Underlying)
+	assert.Len(t, greeks.Legs, 2)
+
+	var net pricing.Greeks
+	for _, leg := range greeks.Legs {
+		contract := pricing.Contract{
+			Type:       leg.Position.Type,
+			Strike:     leg.Position.Strike,
+			Underlying: 18310,
+			Years:      pricing.YearsToExpiry(now, leg.Position.Expiry),
+		}
+		// the implied volatility reprices the mid of the quotes
+		assert.InDelta(t, leg.Price, pricing.BlackScholes.Price(contract, leg.ImpliedVolatility), 1e-3)
+		net = net.Add(leg.Exposure)
+	}
+	assert.InDelta(t, net.Vega, greeks.Net.Vega, 1e-9)
+	assert.InDelta(t, net.Theta, greeks.Net.Theta, 1e-9)
+
+	sold, bought := greeks.Legs[0], greeks.Legs[1]
+	assert.Equal(t, executor.Sell, sold.Position.TradeType)
+	assert.InDelta(t, 100.5, sold.Price, 1e-9)
+	assert.InDelta(t, 200.5, bought.Price, 1e-9)
+	// a sold leg earns its time decay and is short volatility
+	assert.Greater(t, sold.Exposure.Theta, 0.0)
+	assert.Less(t, sold.Exposure.Vega, 0.0)
+	assert.Less(t, bought.Exposure.Theta, 0.0)
+	assert.Greater(t, bought.Exposure.Vega, 0.0)
+}
+
+func TestTradeGreeksMissingQuotes(t *testing.T) {
+	now := istTime(t, "2023-05-16T10:20:00+05:30")
+	atm, broker, expiries := enterPremiumTrade(t, nil, &now)
+
+	broker.depths[expiries[2].ExpiryDate] = fakeBidAsk{}
+	_, err := atm.TradeGreeks()
+	assert.NotNil(t, err)
+}
+
+func TestPricingValidation(t *testing.T) {
+	assert.Nil(t, PricingSettings{}.validate())
+	assert.Nil(t, PricingSettings{Model: pricing.Black76, RiskFreeRate: 0.07}.validate())
+	assert.NotNil(t, PricingSettings{Model: "binomial"}.validate())
+	assert.NotNil(t, PricingSettings{RiskFreeRate: -0.01}.validate())
+	assert.NotNil(t, PricingSettings{ShortMarginPercent: 120}.validate())
+}
+
+func TestGetExposures(t *testing.T) {
+	now := istTime(t, "2023-05-16T10:20:00+05:30")
+	atm, _, _ := enterPremiumTrade(t, nil, &now)
+
+	exposures, err := atm.GetExposures()
+	assert.Nil(t, err)
+	assert.Len(t, exposures, 1)
+	greeks, err := atm.TradeGreeks()
+	assert.Nil(t, err)
+	exposure := exposures[0]
+	assert.Equal(t, atm.Symbol, exposure.Underlying)
+	assert.InDelta(t, greeks.Net.Vega, exposure.Vega, 1e-9)
+	assert.InDelta(t, greeks.Net.Theta, exposure.Theta, 1e-9)
+	// 150 options on 18310, 12% margin on the 100 sold and the premium of
+	// the 50 bought at 201
+	assert.Equal(t, int64(150), exposure.Quantity)
+	assert.InDelta(t, 150*18310.0, exposure.Notional, 1e-6)
+	assert.InDelta(t, 100*18310*0.12+50*201, exposure.MarginAtRisk, 1e-6)
+
+	atm.ExitPaper()
+	exposures, err = atm.GetExposures()
+	assert.Nil(t, err)
+	assert.Empty(t, exposures)
+}
+
+func TestGetExposuresNetsPartialExit(t *testing.T) {
+	now := istTime(t, "2023-05-16T10:20:00+05:30")
+	atm := newTestATMcs(t, nil, func() time.Time { return now })
+	broker := newFakeBroker(18310, testExpiries(atm.ISTLocation))
+	atm.SetBroker(broker)
+	atm.AccountTrade(executor.Buy)
+	assert.True(t, atm.InTrade())
+
+	// 40 of the 100 sold are bought back and the hedge is held
+	broker.fillLimits[executor.Buy] = 40
+	atm.ExitAccount()
+	assert.True(t, atm.InTrade())
+
+	greeks, err := atm.TradeGreeks()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(60), greeks.Legs[0].Quantity)
+	assert.Equal(t, int64(50), greeks.Legs[1].Quantity)
+	assert.InDelta(t, -60*greeks.Legs[0].Greeks.Vega, greeks.Legs[0].Exposure.Vega, 1e-9)
+
+	exposures, err := atm.GetExposures()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(110), exposures[0].Quantity)
+	assert.InDelta(t, 60*18310*0.12+50*atm.Trade.EntryPositions[1].Price, exposures[0].MarginAtRisk, 1e-6)
+}
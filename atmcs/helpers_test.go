@@ -0,0 +1,213 @@
+package atmcs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dragonzurfer/trader/executor"
+)
+
+type fakeDepth struct {
+	price    float64
+	quantity int64
+}
+
+func (d fakeDepth) GetPrice() float64     { return d.price }
+func (d fakeDepth) GetQuantity() int64    { return d.quantity }
+func (d fakeDepth) GetNumOfOrders() int64 { return 1 }
+
+type fakeBidAsk struct {
+	bids []executor.MarketDepthLike
+	asks []executor.MarketDepthLike
+}
+
+func (ba fakeBidAsk) GetBids() []executor.MarketDepthLike { return ba.bids }
+func (ba fakeBidAsk) GetAsks() []executor.MarketDepthLike { return ba.asks }
+
+func newFakeBidAsk(bid, ask float64) fakeBidAsk {
+	return fakeBidAsk{
+		bids: []executor.MarketDepthLike{fakeDepth{price: bid, quantity: 1000}},
+		asks: []executor.MarketDepthLike{fakeDepth{price: ask, quantity: 1000}},
+	}
+}
+
+// fakeBroker serves a fixed LTP, expiry list and option depth keyed by
+// expiry, or by strike when listed in strikeDepths. Orders fill fully at their limit price unless their trade type
+// is listed in rejectTypes, or capped by fillLimits in which case they stay
+// partially filled until cancelled.
+type fakeBroker struct {
+	ltp          float64
+	expiries     []executor.Expiry
+	depths       map[time.Time]fakeBidAsk
+	strikeDepths map[float64]fakeBidAsk
+	candles      map[executor.TimeFrame][]executor.CandleLike
+	candleCalls  map[executor.TimeFrame]int
+	rejectTypes  map[executor.TradeType]bool
+	fillLimits   map[executor.TradeType]int64
+	orders       map[string]executor.OrderRequest
+	placed       []executor.OrderRequest
+	cancelled    []string
+}
+
+func newFakeBroker(ltp float64, expiries []executor.Expiry) *fakeBroker {
+	broker := &fakeBroker{
+		ltp:          ltp,
+		expiries:     expiries,
+		depths:       make(map[time.Time]fakeBidAsk),
+		strikeDepths: make(map[float64]fakeBidAsk),
+		candles:      make(map[executor.TimeFrame][]executor.CandleLike),
+		candleCalls:  make(map[executor.TimeFrame]int),
+		rejectTypes:  make(map[executor.TradeType]bool),
+		fillLimits:   make(map[executor.TradeType]int64),
+		orders:       make(map[string]executor.OrderRequest),
+	}
+	for i, expiry := range expiries {
+		price := 100 + float64(i)*50
+		broker.depths[expiry.ExpiryDate] = newFakeBidAsk(price, price+1)
+	}
+	return broker
+}
+
+func (b *fakeBroker) SetCredentialsFilePath(string) {}
+
+func (b *fakeBroker) GetLTP(string) (float64, error) { return b.ltp, nil }
+
+func (b *fakeBroker) GetMarketDepth(string) (executor.BidAskLike, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (b *fakeBroker) GetCandles(symbol string, from, to time.Time, tf executor.TimeFrame) ([]executor.CandleLike, error) {
+	b.candleCalls[tf]++
+	return b.candles[tf], nil
+}
+
+func (b *fakeBroker) GetOptionExpiries(string) ([]executor.Expiry, error) {
+	return b.expiries, nil
+}
+
+func (b *fakeBroker) GetMarketDepthOption(strike float64, expiry time.Time, optionType executor.OptionType) (executor.BidAskLike, error) {
+	if depth, ok := b.strikeDepths[strike]; ok {
+		return depth, nil
+	}
+	depth, ok := b.depths[expiry]
+	if !ok {
+		return nil, fmt.Errorf("no depth for expiry %v", expiry)
+	}
+	return depth, nil
+}
+
+func (b *fakeBroker) GetCandlesOption(float64, time.Time, executor.OptionType, time.Time, time.Time) ([]executor.CandleLike, error) {
+	return nil, nil
+}
+
+func (b *fakeBroker) PlaceOrder(request executor.OrderRequest) (string, error) {
+	id := fmt.Sprintf("order-%d", len(b.placed)+1)
+	b.placed = append(b.placed, request)
+	b.orders[id] = request
+	return id, nil
+}
+
+func (b *fakeBroker) ModifyOrder(id string, request executor.OrderRequest) error {
+	b.orders[id] = request
+	return nil
+}
+
+func (b *fakeBroker) CancelOrder(id string) error {
+	b.cancelled = append(b.cancelled, id)
+	return nil
+}
+
+func (b *fakeBroker) GetOrderStatus(id string) (executor.OrderUpdate, error) {
+	request, ok := b.orders[id]
+	if !ok {
+		return executor.OrderUpdate{}, fmt.Errorf("unknown order %v", id)
+	}
+	if b.rejectTypes[request.TradeType] {
+		return executor.OrderUpdate{OrderID: id, Status: executor.OrderRejected, Message: "rejected"}, nil
+	}
+	if limit, ok := b.fillLimits[request.TradeType]; ok && limit < request.Quantity {
+		status := executor.OrderPartiallyFilled
+		for _, cancelled := range b.cancelled {
+			if cancelled == id {
+				status = executor.OrderCancelled
+			}
+		}
+		return executor.OrderUpdate{
+			OrderID:        id,
+			Status:         status,
+			FilledQuantity: limit,
+			AveragePrice:   request.LimitPrice,
+		}, nil
+	}
+	return executor.OrderUpdate{
+		OrderID:        id,
+		Status:         executor.OrderFilled,
+		FilledQuantity: request.Quantity,
+		AveragePrice:   request.LimitPrice,
+	}, nil
+}
+
+// newTestATMcs writes a settings file into a temporary directory, merging
+// overrides onto a NIFTY default, and returns the constructed object.
+func newTestATMcs(t *testing.T, overrides map[string]interface{}, now func() time.Time) *ATMcs {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Error getting working directory: %v", err)
+	}
+	dir := t.TempDir()
+	settings := map[string]interface{}{
+		"tradeFilePath":       filepath.Join(dir, "trade.json"),
+		"quantity":            100,
+		"strikeDiff":          50,
+		"minDaysToExpiry":     7,
+		"symbol":              "NSE:NIFTY50-INDEX",
+		"min_target_percent":  0.15,
+		"min_sl_percent":      0.05,
+		"min_trail_percent":   0.1,
+		"holidays_file_path":  filepath.Join(wd, "holidays.json"),
+		"tick_size":           0.05,
+		"sleep_duration":      "5m",
+		"order_timeout":       "10ms",
+		"order_poll_interval": "1ms",
+	}
+	for key, value := range overrides {
+		settings[key] = value
+	}
+	data, err := json.Marshal(settings)
+	if err != nil {
+		t.Fatalf("Error marshalling settings: %v", err)
+	}
+	settingsFilePath := filepath.Join(dir, "settings.json")
+	if err := ioutil.WriteFile(settingsFilePath, data, 0644); err != nil {
+		t.Fatalf("Error writing settings: %v", err)
+	}
+	obj := New(settingsFilePath, now)
+	if obj == nil {
+		t.Fatal("ATMcs object init fail")
+	}
+	return obj
+}
+
+func testExpiries(loc *time.Location) []executor.Expiry {
+	return []executor.Expiry{
+		{ExpiryDate: time.Date(2023, 5, 25, 15, 30, 0, 0, loc)},
+		{ExpiryDate: time.Date(2023, 6, 1, 15, 30, 0, 0, loc)},
+		{ExpiryDate: time.Date(2023, 6, 29, 15, 30, 0, 0, loc)},
+	}
+}
+
+func istTime(t *testing.T, value string) time.Time {
+	t.Helper()
+	parsed, err := time.Parse("2006-01-02T15:04:05-07:00", value)
+	if err != nil {
+		t.Fatalf("Error parsing time: %v", err)
+	}
+	return parsed
+}
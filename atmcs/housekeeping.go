@@ -3,6 +3,9 @@ package atmcs
 import (
 	"fmt"
 	"strings"
+
+	"github.com/dragonzurfer/trader/atmcs/trade"
+	"github.com/dragonzurfer/trader/executor"
 )
 
 func (obj *ATMcs) GetEntryMessage() string {
@@ -38,10 +41,13 @@ func (obj *ATMcs) SetEntryMessage() {
 			position.GetExpiry().Format("2006-01-02"),
 			position.GetQuantity(),
 		)
-		messages = append(messages, message)
+		messages = append(messages, message+ordersMessage(position))
 	}
 	depthQuantMessage := fmt.Sprintf("depth Quant sell enter:%0.2f depth Quant buy enter:%0.2f", obj.Trade.DepthQuantityEntrySell, obj.Trade.DepthQuantityEntryBuy)
 	messages = append(messages, depthQuantMessage)
+	if trade.RiskReason != "" {
+		messages = append(messages, fmt.Sprintf("Risk:%v", trade.RiskReason))
+	}
 	entryTimeMsg := fmt.Sprintf("Entry Time:%v", trade.TimeOfEntry.Format("2006-01-02 15:04:05"))
 	messages = append(messages, entryTimeMsg)
 
@@ -62,12 +68,16 @@ func (obj *ATMcs) SetExitMessage() {
 			position.GetExpiry().Format("2006-01-02"),
 			position.GetQuantity(),
 		)
-		messages = append(messages, message)
+		messages = append(messages, message+ordersMessage(position))
 	}
 	depthQuantMessage := fmt.Sprintf("depth Quant sell enter:%0.2f depth Quant buy enter:%0.2f", obj.Trade.DepthQuantityExitSell, obj.Trade.DepthQuantityExitBuy)
 	messages = append(messages, depthQuantMessage)
 	exitTimeMsg := fmt.Sprintf("Exit Time:%v", trade.TimeOfExit.Format("2006-01-02 15:04:05"))
 	messages = append(messages, exitTimeMsg)
+	if trade.ExitReason != "" {
+		reason := executor.Event{Reason: trade.ExitReason, Detail: trade.ExitDetail}
+		messages = append(messages, fmt.Sprintf("Exit Reason:%v", reason))
+	}
 
 	obj.ExitMessage = strings.Join(messages, "\n")
 }
@@ -117,3 +127,11 @@ func (obj *ATMcs) GetCSVTradeExit() []string {
 
 	return exitStrings
 }
+
+func ordersMessage(position trade.OptionPosition) string {
+	var message string
+	for _, order := range position.Orders {
+		message += fmt.Sprintf("order %v: %v %d/%d\n", order.OrderID, order.Status, order.FilledQuantity, order.Quantity)
+	}
+	return message
+}
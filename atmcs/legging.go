@@ -0,0 +1,75 @@
+package atmcs
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/dragonzurfer/trader/atmcs/trade"
+	"github.com/dragonzurfer/trader/executor"
+)
+
+const defaultLegTimeout = time.Minute
+
+// hedgeFirstOrder returns the indices of positions with buy legs ahead of
+// sell legs. On entry this buys the hedge before writing the option, and on
+// exit it buys back the written option before selling the hedge, so a short
+// option is never open on its own.
+func hedgeFirstOrder(positions []trade.OptionPosition) []int {
+	order := make([]int, len(positions))
+	for i := range positions {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return positions[order[a]].TradeType == executor.Buy && positions[order[b]].TradeType != executor.Buy
+	})
+	return order
+}
+
+// enterLegs places the legs hedge first. Once the first leg has filled the
+// remaining legs must fill within LegTimeout; execution stops at the first
+// leg that does not fill completely. The returned positions keep the
+// original leg order and only include legs that were sent to the broker.
+func (obj *ATMcs) enterLegs(positions []trade.OptionPosition) ([]trade.OptionPosition, error) {
+	placed := make([]*trade.OptionPosition, len(positions))
+	var deadline time.Time
+	var err error
+	for _, i := range hedgeFirstOrder(positions) {
+		timeout := obj.orderTimeout()
+		if !deadline.IsZero() {
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				err = fmt.Errorf("leg timeout of %v elapsed before %v %v %v was placed", obj.legTimeout(), positions[i].Strike, positions[i].Type, positions[i].TradeType)
+				break
+			}
+			if remaining < timeout {
+				timeout = remaining
+			}
+		}
+
+		var position trade.OptionPosition
+		position, err = obj.placePosition(positions[i], timeout)
+		placed[i] = &position
+		if err != nil {
+			break
+		}
+		if deadline.IsZero() {
+			deadline = time.Now().Add(obj.legTimeout())
+		}
+	}
+
+	var result []trade.OptionPosition
+	for _, position := range placed {
+		if position != nil {
+			result = append(result, *position)
+		}
+	}
+	return result, err
+}
+
+func (obj *ATMcs) legTimeout() time.Duration {
+	if obj.Settings.LegTimeout.Duration > 0 {
+		return obj.Settings.LegTimeout.Duration
+	}
+	return defaultLegTimeout
+}
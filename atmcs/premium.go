@@ -0,0 +1,136 @@
+package atmcs
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/dragonzurfer/trader/executor"
+)
+
+// premiumExitDetail marks exits on the premium P&L rather than the
+// underlying.
+const premiumExitDetail = "premium"
+
+// PremiumExit exits on the P&L of the entry legs, valued at the prices
+// they would exit at now, alongside the exits on the underlying. Levels
+// left at 0 are off.
+type PremiumExit struct {
+	// StopLoss and Target are in rupees.
+	StopLoss float64 `json:"stop_loss"`
+	Target   float64 `json:"target"`
+	// StopLossPercent and TargetPercent are of the gross premium of the
+	// entry legs.
+	StopLossPercent float64 `json:"stop_loss_percent"`
+	TargetPercent   float64 `json:"target_percent"`
+	// CheckInterval is the least time between valuations, as each one
+	// fetches the depth of every leg. Every tick is checked when unset.
+	CheckInterval DurationWrapper `json:"check_interval"`
+}
+
+func (p PremiumExit) enabled() bool {
+	return p.StopLoss > 0 || p.Target > 0 || p.StopLossPercent > 0 || p.TargetPercent > 0
+}
+
+func (p PremiumExit) validate() error {
+	if p.StopLoss < 0 || p.Target < 0 || p.StopLossPercent < 0 || p.TargetPercent < 0 {
+		return errors.New("premium exit levels cannot be negative")
+	}
+	if p.CheckInterval.Duration < 0 {
+		return errors.New("premium exit check_interval cannot be negative")
+	}
+	return nil
+}
+
+// levels returns the P&L at or below which the trade is stopped out and
+// at or above which the target is hit. The tighter level wins when both
+// an absolute and a percentage one are set.
+func (p PremiumExit) levels(grossPremium float64) (stopLoss float64, target float64, hasStopLoss bool, hasTarget bool) {
+	if p.StopLoss > 0 {
+		stopLoss, hasStopLoss = -p.StopLoss, true
+	}
+	if p.StopLossPercent > 0 {
+		percentLevel := -grossPremium * p.StopLossPercent / 100
+		if !hasStopLoss || percentLevel > stopLoss {
+			stopLoss, hasStopLoss = percentLevel, true
+		}
+	}
+	if p.Target > 0 {
+		target, hasTarget = p.Target, true
+	}
+	if p.TargetPercent > 0 {
+		percentLevel := grossPremium * p.TargetPercent / 100
+		if !hasTarget || percentLevel < target {
+			target, hasTarget = percentLevel, true
+		}
+	}
+	return stopLoss, target, hasStopLoss, hasTarget
+}
+
+// PremiumPnL values the open quantity of the entry legs at the touch they
+// would exit at, bids for bought legs and asks for sold ones, plus whatever
+// was already realized by partial exits.
+func (obj *ATMcs) PremiumPnL() (float64, error) {
+	exitPrices := make([]float64, len(obj.Trade.EntryPositions))
+	for i, position := range obj.Trade.EntryPositions {
+		if obj.Trade.OpenQuantity(i) == 0 {
+			continue
+		}
+		var depth []executor.MarketDepthLike
+		var err error
+		if position.TradeType == executor.Buy {
+			depth, err = GetBids(obj.Broker, position)
+		} else {
+			depth, err = GetAsks(obj.Broker, position)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("PremiumPnL(): %w", err)
+		}
+		price, _ := obj.GetAvgMarketDepth(depth)
+		if price == 0 {
+			return 0, fmt.Errorf("PremiumPnL(): no exit price for %v %v %v", position.Strike, position.Type, position.Expiry)
+		}
+		exitPrices[i] = price
+	}
+	return obj.Trade.UnrealizedPnL(exitPrices) + obj.Trade.RealizedPnL(), nil
+}
+
+// GetPnL is PremiumPnL while in a trade and the realized P&L of the last
+// trade once exited.
+func (obj *ATMcs) GetPnL() (float64, error) {
+	if !obj.Trade.InTrade {
+		return obj.Trade.RealizedPnL(), nil
+	}
+	return obj.PremiumPnL()
+}
+
+// checkPremiumExit reports whether the premium stop loss or target is hit,
+// valuing the legs at most once per CheckInterval.
+func (obj *ATMcs) checkPremiumExit() (stopLossHit bool, targetHit bool) {
+	exit := obj.Settings.PremiumExit
+	if !exit.enabled() {
+		return false, false
+	}
+	now := obj.GetCurrentTime()
+	if !obj.lastPremiumCheck.IsZero() && now.Sub(obj.lastPremiumCheck) < exit.CheckInterval.Duration {
+		return false, false
+	}
+	obj.lastPremiumCheck = now
+
+	pnl, err := obj.PremiumPnL()
+	if err != nil {
+		obj.addError(err)
+		return false, false
+	}
+	stopLoss, target, hasStopLoss, hasTarget := exit.levels(obj.Trade.GrossPremium())
+	if hasStopLoss && pnl <= stopLoss {
+		log.Printf("premium stop loss hit: P&L %.2f <= %.2f at %v\n", pnl, stopLoss, now.Format(time.RFC3339))
+		return true, false
+	}
+	if hasTarget && pnl >= target {
+		log.Printf("premium target hit: P&L %.2f >= %.2f at %v\n", pnl, target, now.Format(time.RFC3339))
+		return false, true
+	}
+	return false, false
+}
@@ -0,0 +1,150 @@
+package atmcs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dragonzurfer/trader/executor"
+	"github.com/stretchr/testify/assert"
+)
+
+// enterPremiumTrade paper trades the default calendar spread on a buy
+// signal, selling 100 puts at 100 and buying 50 at 201, with underlying
+// levels far enough not to trigger.
+func enterPremiumTrade(t *testing.T, premiumExit map[string]interface{}, now *time.Time) (*ATMcs, *fakeBroker, []executor.Expiry) {
+	t.Helper()
+	atm := newTestATMcs(t, map[string]interface{}{"premium_exit": premiumExit}, func() time.Time { return *now })
+	expiries := testExpiries(atm.ISTLocation)
+	broker := newFakeBroker(18310, expiries)
+	atm.SetBroker(broker)
+	atm.PaperTrade(executor.Buy)
+	assert.True(t, atm.InTrade())
+	atm.Trade.TradeType = executor.Buy
+	atm.Trade.EntryPrice = 18310
+	atm.Trade.StopLossPrice = 18000
+	atm.Trade.TargetPrice = 18600
+	return atm, broker, expiries
+}
+
+func TestPremiumStopLoss(t *testing.T) {
+	now := istTime(t, "2023-05-16T10:20:00+05:30")
+	atm, broker, expiries := enterPremiumTrade(t, map[string]interface{}{"stop_loss": 1000}, &now)
+
+	pnl, err := atm.PremiumPnL()
+	assert.Nil(t, err)
+	// the sold leg exits at the ask and the bought one at the bid
+	assert.InDelta(t, -150.0, pnl, 1e-9)
+	atm.ExitOnTick(18310)
+	assert.True(t, atm.InTrade())
+
+	broker.depths[expiries[0].ExpiryDate] = newFakeBidAsk(110, 112)
+	atm.ExitOnTick(18310)
+
+	assert.False(t, atm.InTrade())
+	assert.True(t, atm.Trade.IsStopLossHit)
+	assert.Equal(t, executor.ExitStopLoss, atm.Trade.ExitReason)
+	assert.Equal(t, premiumExitDetail, atm.Trade.ExitDetail)
+	select {
+	case event := <-atm.GetEventChan():
+		assert.Equal(t, executor.Event{Reason: executor.ExitStopLoss, Detail: premiumExitDetail, Time: now}, event)
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for the stop loss event")
+	}
+}
+
+func TestPremiumTargetPercent(t *testing.T) {
+	now := istTime(t, "2023-05-16T10:20:00+05:30")
+	// 10% of the 20050 gross premium
+	atm, broker, expiries := enterPremiumTrade(t, map[string]interface{}{"target_percent": 10}, &now)
+
+	broker.depths[expiries[0].ExpiryDate] = newFakeBidAsk(80, 81)
+	atm.ExitOnTick(18310)
+	assert.True(t, atm.InTrade(), "P&L of 1850 should not reach the target")
+
+	broker.depths[expiries[2].ExpiryDate] = newFakeBidAsk(210, 211)
+	atm.ExitOnTick(18310)
+
+	assert.False(t, atm.InTrade())
+	assert.False(t, atm.Trade.IsStopLossHit)
+	select {
+	case event := <-atm.GetEventChan():
+		assert.Equal(t, executor.ExitTarget, event.Reason)
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for the target event")
+	}
+}
+
+func TestPremiumCheckInterval(t *testing.T) {
+	now := istTime(t, "2023-05-16T10:20:00+05:30")
+	atm, broker, expiries := enterPremiumTrade(t, map[string]interface{}{
+		"stop_loss":      1000,
+		"check_interval": "1m",
+	}, &now)
+
+	atm.ExitOnTick(18310)
+	broker.depths[expiries[0].ExpiryDate] = newFakeBidAsk(110, 112)
+	now = now.Add(30 * time.Second)
+	atm.ExitOnTick(18310)
+	assert.True(t, atm.InTrade(), "legs valued again within the check interval")
+
+	now = now.Add(30 * time.Second)
+	atm.ExitOnTick(18310)
+	assert.False(t, atm.InTrade())
+}
+
+func TestPremiumExitLevels(t *testing.T) {
+	exit := PremiumExit{StopLoss: 1000, StopLossPercent: 2, Target: 500, TargetPercent: 5}
+	stopLoss, target, hasStopLoss, hasTarget := exit.levels(20000)
+	assert.True(t, hasStopLoss)
+	assert.True(t, hasTarget)
+	assert.Equal(t, -400.0, stopLoss)
+	assert.Equal(t, 500.0, target)
+
+	_, _, hasStopLoss, hasTarget = PremiumExit{}.levels(20000)
+	assert.False(t, hasStopLoss)
+	assert.False(t, hasTarget)
+
+	assert.NotNil(t, PremiumExit{StopLoss: -1}.validate())
+	assert.Nil(t, exit.validate())
+}
+
+func TestGetPnL(t *testing.T) {
+	now := istTime(t, "2023-05-16T10:20:00+05:30")
+	atm, broker, expiries := enterPremiumTrade(t, nil, &now)
+
+	pnl, err := atm.GetPnL()
+	assert.Nil(t, err)
+	assert.InDelta(t, -150.0, pnl, 1e-9)
+
+	broker.depths[expiries[0].ExpiryDate] = newFakeBidAsk(90, 91)
+	atm.SetExitReason(executor.ExitRiskLimit, "daily loss")
+	atm.ExitPaper()
+	assert.False(t, atm.InTrade())
+	assert.Equal(t, executor.ExitRiskLimit, atm.Trade.ExitReason)
+	pnl, err = atm.GetPnL()
+	assert.Nil(t, err)
+	// 9 a share on the 100 sold less the spread on the 50 bought
+	assert.InDelta(t, 100*9.0-50*1, pnl, 1e-9)
+}
+
+func TestPremiumPnLAfterPartialExit(t *testing.T) {
+	now := istTime(t, "2023-05-16T10:20:00+05:30")
+	atm := newTestATMcs(t, nil, func() time.Time { return now })
+	expiries := testExpiries(atm.ISTLocation)
+	broker := newFakeBroker(18310, expiries)
+	atm.SetBroker(broker)
+	atm.AccountTrade(executor.Buy)
+	assert.True(t, atm.InTrade())
+
+	// 40 of the 100 sold are bought back and the hedge is held
+	broker.fillLimits[executor.Buy] = 40
+	atm.ExitAccount()
+	assert.True(t, atm.InTrade())
+	sold, bought := atm.Trade.EntryPositions[0], atm.Trade.EntryPositions[1]
+	realized := (sold.Price - atm.Trade.ExitPositions[0].Price) * 40
+
+	broker.depths[expiries[0].ExpiryDate] = newFakeBidAsk(90, 91)
+	pnl, err := atm.GetPnL()
+	assert.Nil(t, err)
+	assert.InDelta(t, realized+(sold.Price-91)*60+(200-bought.Price)*50, pnl, 1e-9)
+}
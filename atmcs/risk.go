@@ -0,0 +1,257 @@
+package atmcs
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/dragonzurfer/trader/atmcs/trade"
+	"github.com/dragonzurfer/trader/executor"
+)
+
+// RiskLimits are the pre-trade checks made on every entry before it is
+// placed. Limits left at 0 are off.
+type RiskLimits struct {
+	// MaxOpenTrades and MaxLotsPerUnderlying count the trades held by
+	// every trader sharing ATMcs.Portfolio, and need it to be set.
+	MaxOpenTrades        int   `json:"max_open_trades"`
+	MaxLotsPerUnderlying int64 `json:"max_lots_per_underlying"`
+	// MaxPremiumOutlay is the most premium paid on the bought legs.
+	MaxPremiumOutlay float64 `json:"max_premium_outlay"`
+	// MinDepthRatio is how many times the quantity of each side the depth
+	// quantity the legs were priced from must be, see
+	// trade.Trade.DepthQuantityEntrySell.
+	MinDepthRatio float64 `json:"min_depth_ratio"`
+	// MaxSpreadPercent is the widest bid/ask spread of a leg, as a percent
+	// of its mid price.
+	MaxSpreadPercent float64 `json:"max_spread_percent"`
+}
+
+func (r RiskLimits) validate() error {
+	if r.MaxOpenTrades < 0 || r.MaxLotsPerUnderlying < 0 || r.MaxPremiumOutlay < 0 || r.MinDepthRatio < 0 || r.MaxSpreadPercent < 0 {
+		return errors.New("risk limits cannot be negative")
+	}
+	return nil
+}
+
+// RiskQuery is an entry about to be placed.
+type RiskQuery struct {
+	Positions []trade.OptionPosition
+	// Legs are the templates Positions were built from, in order.
+	Legs []LegTemplate
+	// DepthQuantitySell and DepthQuantityBuy are the depth quantities the
+	// sold and bought legs were priced from.
+	DepthQuantitySell float64
+	DepthQuantityBuy  float64
+	Underlying        string
+	LotSize           int64
+	// Portfolio is the exposure of the other traders, empty when
+	// ATMcs.Portfolio is not set.
+	Portfolio executor.PortfolioSnapshot
+	Broker    executor.BrokerLike
+}
+
+// RiskDecision is the outcome of a check. Positions are the ones to enter,
+// resized when smaller than the query's, and Reason says why the entry was
+// vetoed or resized.
+type RiskDecision struct {
+	Positions []trade.OptionPosition
+	Vetoed    bool
+	Reason    string
+}
+
+// RiskCheckLike vetoes or resizes an entry.
+type RiskCheckLike interface {
+	CheckEntry(RiskQuery) RiskDecision
+}
+
+func allow(query RiskQuery) RiskDecision {
+	return RiskDecision{Positions: query.Positions}
+}
+
+func veto(format string, args ...interface{}) RiskDecision {
+	return RiskDecision{Vetoed: true, Reason: fmt.Sprintf(format, args...)}
+}
+
+// riskChecks returns the checks of Settings.Risk followed by RiskChecks.
+func (obj *ATMcs) riskChecks() []RiskCheckLike {
+	limits := obj.Settings.Risk
+	var checks []RiskCheckLike
+	if limits.MaxOpenTrades > 0 {
+		checks = append(checks, maxOpenTrades(limits.MaxOpenTrades))
+	}
+	if limits.MaxSpreadPercent > 0 {
+		checks = append(checks, maxSpread(limits.MaxSpreadPercent))
+	}
+	if limits.MinDepthRatio > 0 {
+		checks = append(checks, minDepth(limits.MinDepthRatio))
+	}
+	if limits.MaxLotsPerUnderlying > 0 {
+		checks = append(checks, maxLots(limits.MaxLotsPerUnderlying))
+	}
+	if limits.MaxPremiumOutlay > 0 {
+		checks = append(checks, maxPremiumOutlay(limits.MaxPremiumOutlay))
+	}
+	return append(checks, obj.RiskChecks...)
+}
+
+// checkRisk runs the entry through every check, each seeing the positions
+// the previous one allowed. The reasons of resizes and of a veto are
+// recorded in RiskReason.
+func (obj *ATMcs) checkRisk(positions []trade.OptionPosition) ([]trade.OptionPosition, error) {
+	obj.RiskReason = ""
+	checks := obj.riskChecks()
+	if len(checks) == 0 {
+		return positions, nil
+	}
+	query := RiskQuery{
+		Positions:         positions,
+		Legs:              obj.legTemplates(),
+		DepthQuantitySell: obj.Trade.DepthQuantityEntrySell,
+		DepthQuantityBuy:  obj.Trade.DepthQuantityEntryBuy,
+		Underlying:        obj.Symbol,
+		LotSize:           obj.lotSize(),
+		Broker:            obj.Broker,
+	}
+	if obj.Portfolio != nil {
+		query.Portfolio = obj.Portfolio.Snapshot()
+	}
+	var reasons []string
+	for _, check := range checks {
+		decision := check.CheckEntry(query)
+		if decision.Reason != "" {
+			reasons = append(reasons, decision.Reason)
+		}
+		obj.RiskReason = strings.Join(reasons, "; ")
+		if decision.Vetoed {
+			return nil, fmt.Errorf("entry vetoed: %v", decision.Reason)
+		}
+		query.Positions = decision.Positions
+	}
+	return query.Positions, nil
+}
+
+// resize scales the entry by factor, rounding its base quantity down to
+// whole lots in steps that keep every leg in whole lots, and vetoes the
+// entry when no step is left. Each leg is rebuilt from its template so the
+// hedge ratio is kept.
+func resize(query RiskQuery, factor float64, reason string) RiskDecision {
+	if factor >= 1 {
+		return allow(query)
+	}
+	if len(query.Positions) == 0 || len(query.Legs) != len(query.Positions) {
+		return veto("%v, cannot resize positions that do not match the legs", reason)
+	}
+	lotSize := query.LotSize
+	if lotSize <= 0 {
+		lotSize = 1
+	}
+	step, err := lotStep(query.Legs)
+	if err != nil {
+		return veto("%v, %v", reason, err)
+	}
+	base := float64(query.Positions[0].Quantity) / query.Legs[0].ratio()
+	lots := int64(math.Floor(base*factor/float64(lotSize*step)+1e-9)) * step
+	if lots <= 0 {
+		return veto("%v, too small to resize", reason)
+	}
+	positions := make([]trade.OptionPosition, len(query.Positions))
+	for i, position := range query.Positions {
+		position.Quantity = query.Legs[i].quantity(lots * lotSize)
+		positions[i] = position
+	}
+	return RiskDecision{Positions: positions, Reason: reason + ", resized"}
+}
+
+type maxOpenTrades int
+
+func (limit maxOpenTrades) CheckEntry(query RiskQuery) RiskDecision {
+	if open := len(query.Portfolio.Traders); open >= int(limit) {
+		return veto("%v open trades reach the limit of %v", open, int(limit))
+	}
+	return allow(query)
+}
+
+type maxLots int64
+
+func (limit maxLots) CheckEntry(query RiskQuery) RiskDecision {
+	lotSize := float64(query.LotSize)
+	if lotSize <= 0 {
+		lotSize = 1
+	}
+	held := float64(query.Portfolio.Exposure(query.Underlying).Quantity) / lotSize
+	var entered int64
+	for _, position := range query.Positions {
+		entered += position.Quantity
+	}
+	lots := float64(entered) / lotSize
+	room := float64(limit) - held
+	if room <= 0 {
+		return veto("%v lots held on %v reach the limit of %v", held, query.Underlying, int64(limit))
+	}
+	if lots <= room {
+		return allow(query)
+	}
+	return resize(query, room/lots, fmt.Sprintf("%v lots on %v exceed the limit of %v", held+lots, query.Underlying, int64(limit)))
+}
+
+type maxPremiumOutlay float64
+
+func (limit maxPremiumOutlay) CheckEntry(query RiskQuery) RiskDecision {
+	var outlay float64
+	for _, position := range query.Positions {
+		if position.TradeType == executor.Buy {
+			outlay += position.Price * float64(position.Quantity)
+		}
+	}
+	if outlay <= float64(limit) {
+		return allow(query)
+	}
+	return resize(query, float64(limit)/outlay, fmt.Sprintf("premium outlay of %.2f exceeds %.2f", outlay, float64(limit)))
+}
+
+type minDepth float64
+
+func (ratio minDepth) CheckEntry(query RiskQuery) RiskDecision {
+	var sold, bought float64
+	for _, position := range query.Positions {
+		if position.TradeType == executor.Sell {
+			sold += float64(position.Quantity)
+		} else {
+			bought += float64(position.Quantity)
+		}
+	}
+	factor := 1.0
+	if sold > 0 {
+		factor = math.Min(factor, query.DepthQuantitySell/(float64(ratio)*sold))
+	}
+	if bought > 0 {
+		factor = math.Min(factor, query.DepthQuantityBuy/(float64(ratio)*bought))
+	}
+	if factor >= 1 {
+		return allow(query)
+	}
+	return resize(query, factor, fmt.Sprintf("depth of %.0f sell and %.0f buy is under %v times the quantity", query.DepthQuantitySell, query.DepthQuantityBuy, float64(ratio)))
+}
+
+type maxSpread float64
+
+func (limit maxSpread) CheckEntry(query RiskQuery) RiskDecision {
+	for _, position := range query.Positions {
+		depth, err := query.Broker.GetMarketDepthOption(position.Strike, position.Expiry, position.Type)
+		if err != nil {
+			return veto("no depth for %v %v: %v", position.Strike, position.Type, err)
+		}
+		bids, asks := depth.GetBids(), depth.GetAsks()
+		if len(bids) == 0 || len(asks) == 0 {
+			return veto("no two sided quote for %v %v", position.Strike, position.Type)
+		}
+		bid, ask := bids[0].GetPrice(), asks[0].GetPrice()
+		spread := (ask - bid) / ((ask + bid) / 2) * 100
+		if spread > float64(limit) {
+			return veto("spread of %.2f%% on %v %v exceeds %v%%", spread, position.Strike, position.Type, float64(limit))
+		}
+	}
+	return allow(query)
+}
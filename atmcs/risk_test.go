@@ -0,0 +1,148 @@
+package atmcs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dragonzurfer/trader/executor"
+	"github.com/stretchr/testify/assert"
+)
+
+// newRiskATMcs returns an ATMcs ready to paper trade the default calendar
+// spread, selling 100 puts at 100 and buying 50 at 201, in lots of 25.
+func newRiskATMcs(t *testing.T, risk map[string]interface{}) (*ATMcs, *fakeBroker, []executor.Expiry) {
+	t.Helper()
+	now := istTime(t, "2023-05-16T10:20:00+05:30")
+	atm := newTestATMcs(t, map[string]interface{}{"risk": risk, "lot_size": 25}, func() time.Time { return now })
+	expiries := testExpiries(atm.ISTLocation)
+	broker := newFakeBroker(18310, expiries)
+	atm.SetBroker(broker)
+	return atm, broker, expiries
+}
+
+func quantities(atm *ATMcs) []int64 {
+	var result []int64
+	for _, position := range atm.Trade.EntryPositions {
+		result = append(result, position.Quantity)
+	}
+	return result
+}
+
+func TestRiskPremiumOutlayResizes(t *testing.T) {
+	// the 50 bought at 201 cost 10050
+	atm, _, _ := newRiskATMcs(t, map[string]interface{}{"max_premium_outlay": 6000})
+	atm.PaperTrade(executor.Buy)
+
+	assert.True(t, atm.InTrade())
+	// 6000 of 10050 rounds down to 2 of the 4 sold lots and 1 of the 2 bought
+	assert.Equal(t, []int64{50, 25}, quantities(atm))
+	assert.Contains(t, atm.Trade.RiskReason, "premium outlay")
+	assert.Contains(t, atm.GetEntryMessage(), "Risk:")
+}
+
+func TestRiskResizeKeepsHedgeRatio(t *testing.T) {
+	now := istTime(t, "2023-05-16T10:20:00+05:30")
+	atm := newTestATMcs(t, map[string]interface{}{
+		"risk":     map[string]interface{}{"max_premium_outlay": 7600},
+		"lot_size": 25,
+		"quantity": 150,
+	}, func() time.Time { return now })
+	atm.SetBroker(newFakeBroker(18310, testExpiries(atm.ISTLocation)))
+	atm.PaperTrade(executor.Buy)
+
+	assert.True(t, atm.InTrade())
+	// half of the 6 sold and 3 bought lots is 3 and 1.5, kept 2:1 as 2 and 1
+	assert.Equal(t, []int64{50, 25}, quantities(atm))
+}
+
+func TestRiskVetoes(t *testing.T) {
+	atm, _, _ := newRiskATMcs(t, map[string]interface{}{"max_premium_outlay": 4000})
+	atm.PaperTrade(executor.Buy)
+
+	assert.False(t, atm.InTrade(), "half of the bought lot cannot be entered")
+	assert.True(t, atm.IsError())
+	assert.Contains(t, atm.RiskReason, "too small to resize")
+	assert.Contains(t, atm.ReadErrors()[0], "PaperTrade(): entry vetoed")
+}
+
+func TestNoEntryWithoutPositions(t *testing.T) {
+	atm, broker, expiries := newRiskATMcs(t, nil)
+	delete(broker.depths, expiries[2].ExpiryDate)
+	atm.PaperTrade(executor.Buy)
+
+	assert.False(t, atm.InTrade(), "the hedge could not be priced")
+	assert.Empty(t, atm.Trade.EntryPositions)
+	assert.Contains(t, atm.ReadErrors(), "PaperTrade(): failed to make entry positions")
+}
+
+func TestRiskMaxSpread(t *testing.T) {
+	atm, broker, expiries := newRiskATMcs(t, map[string]interface{}{"max_spread_percent": 2})
+	broker.depths[expiries[0].ExpiryDate] = newFakeBidAsk(100, 104)
+	atm.PaperTrade(executor.Buy)
+	assert.False(t, atm.InTrade())
+	assert.Contains(t, atm.RiskReason, "spread")
+
+	broker.depths[expiries[0].ExpiryDate] = newFakeBidAsk(100, 101)
+	atm.PaperTrade(executor.Buy)
+	assert.True(t, atm.InTrade())
+	assert.Equal(t, "", atm.Trade.RiskReason)
+}
+
+func TestRiskMinDepth(t *testing.T) {
+	// the fake depth quotes 1000 on each side, two thirds of 15 times the
+	// 100 sold
+	atm, _, _ := newRiskATMcs(t, map[string]interface{}{"min_depth_ratio": 15})
+	atm.PaperTrade(executor.Buy)
+
+	assert.True(t, atm.InTrade())
+	assert.Equal(t, []int64{50, 25}, quantities(atm))
+	assert.Contains(t, atm.Trade.RiskReason, "depth")
+}
+
+func TestRiskPortfolioLimits(t *testing.T) {
+	portfolio := executor.NewPortfolio()
+	portfolio.Update("other", []executor.Exposure{{Underlying: "NSE:NIFTY50-INDEX", Quantity: 150}}, time.Now())
+
+	atm, _, _ := newRiskATMcs(t, map[string]interface{}{"max_lots_per_underlying": 10})
+	atm.Portfolio = portfolio
+	atm.PaperTrade(executor.Buy)
+	assert.True(t, atm.InTrade())
+	// 6 lots held leave room for 4 of the 6 lots entered
+	assert.Equal(t, []int64{50, 25}, quantities(atm))
+	assert.Contains(t, atm.Trade.RiskReason, "lots")
+
+	atm, _, _ = newRiskATMcs(t, map[string]interface{}{"max_open_trades": 1})
+	atm.Portfolio = portfolio
+	atm.PaperTrade(executor.Buy)
+	assert.False(t, atm.InTrade())
+	assert.Contains(t, atm.RiskReason, "open trades")
+}
+
+type blockExpiry struct {
+	expiry time.Time
+}
+
+func (b blockExpiry) CheckEntry(query RiskQuery) RiskDecision {
+	for _, position := range query.Positions {
+		if position.Expiry.Equal(b.expiry) {
+			return veto("expiry %v is blocked", b.expiry.Format("2006-01-02"))
+		}
+	}
+	return allow(query)
+}
+
+func TestCustomRiskCheck(t *testing.T) {
+	atm, _, expiries := newRiskATMcs(t, nil)
+	atm.RiskChecks = []RiskCheckLike{blockExpiry{expiry: expiries[0].ExpiryDate}}
+	atm.AccountTrade(executor.Buy)
+
+	assert.False(t, atm.InTrade())
+	assert.Contains(t, atm.RiskReason, "is blocked")
+	broker := atm.Broker.(*fakeBroker)
+	assert.Empty(t, broker.placed, "a vetoed entry places no orders")
+}
+
+func TestRiskLimitsValidation(t *testing.T) {
+	assert.Nil(t, RiskLimits{}.validate())
+	assert.NotNil(t, RiskLimits{MaxSpreadPercent: -1}.validate())
+}
@@ -6,40 +6,55 @@ import (
 	"github.com/dragonzurfer/trader/executor"
 )
 
-func (obj *ATMcs) UpdateSL() {
-
-}
-
 func (obj *ATMcs) ExitOnTick(tickPrice float64) {
+	if obj.Settings.SignalSettings.TickCandles {
+		obj.tickCandleBuilder().AddTick(obj.Symbol, obj.GetCurrentTime(), tickPrice, 0)
+	}
 	if obj.InTrade() {
-		obj.SetMinTrail(tickPrice)
+		if obj.Settings.Trail.isMinTrail() {
+			obj.SetMinTrail(tickPrice)
+		}
 		if obj.IsHitTickSL(tickPrice) {
-			obj.ExitPaper()
-			go func() {
-				obj.StopLossHitChan <- true
-			}()
-			obj.Trade.IsStopLossHit = true
-			obj.ExitSatisfied = true
-			obj.Trade.TimeOfExit = obj.GetCurrentTime()
+			obj.exitOn(executor.ExitStopLoss, "")
 			return
 		}
 		if obj.IsHitTickTarget(tickPrice) {
-			obj.ExitPaper()
-			go func() {
-				obj.TargetHitChan <- true
-			}()
-			obj.ExitSatisfied = true
-			obj.Trade.TimeOfExit = obj.GetCurrentTime()
+			obj.exitOn(executor.ExitTarget, "")
+			return
+		}
+		stopLossHit, targetHit := obj.checkPremiumExit()
+		if stopLossHit {
+			obj.exitOn(executor.ExitStopLoss, premiumExitDetail)
 			return
 		}
-		if obj.IsUpdateMinTrail(tickPrice) {
-			go func() {
-				obj.TrailChan <- true
-			}()
+		if targetHit {
+			obj.exitOn(executor.ExitTarget, premiumExitDetail)
+			return
+		}
+		if obj.UpdateSL(tickPrice) {
+			obj.notify(executor.TrailAdjusted, "")
 			return
 		}
 	}
 }
+
+// exitOn exits the trade for reason and sends the exit as an event. Live
+// trades are exited through the broker. The reason is cleared again if the
+// trade could not be exited.
+func (obj *ATMcs) exitOn(reason executor.ExitReason, detail string) {
+	obj.setExitReason(reason, detail)
+	if obj.Trade.IsLive() {
+		obj.ExitAccount()
+	} else {
+		obj.ExitPaper()
+	}
+	if obj.Trade.InTrade {
+		obj.setExitReason("", "")
+		return
+	}
+	obj.notify(reason, detail)
+}
+
 func (obj *ATMcs) IsUpdateMinTrail(tickPrice float64) bool {
 	if !obj.Trade.IsMinTrailHit {
 		return false
@@ -35,8 +35,7 @@ func TestExitOnTick(t *testing.T) {
 	// Test the case where stop loss should be hit
 	atm.Trade.StopLossPrice = 90.0
 
-	slHitChannel := atm.GetStopLossHitChan()
-	targetHitChannel := atm.GetTargetHitChan()
+	events := atm.GetEventChan()
 
 	go func() {
 		time.Sleep(time.Second)
@@ -44,13 +43,14 @@ func TestExitOnTick(t *testing.T) {
 	}()
 
 	select {
-	case _, ok := <-slHitChannel:
+	case event, ok := <-events:
 		if !ok {
-			t.Fatal("StopLossHitChan was closed")
+			t.Fatal("EventChan was closed")
 		}
+		assert.Equal(t, executor.ExitStopLoss, event.Reason)
 		assert.True(t, atm.ExitSatisfied, "Expected ExitSatisfied to be true")
 	case <-time.After(time.Second * 5):
-		t.Fatal("Timeout waiting for StopLossHitChan")
+		t.Fatal("Timeout waiting for the stop loss event")
 	}
 	atm.SetEntryStates()
 	// Reset ExitSatisfied
@@ -71,9 +71,10 @@ func TestExitOnTick(t *testing.T) {
 	}()
 
 	select {
-	case <-targetHitChannel:
+	case event := <-events:
+		assert.Equal(t, executor.ExitTarget, event.Reason)
 		assert.True(t, atm.ExitSatisfied, "Expected ExitSatisfied to be true")
 	case <-time.After(time.Second * 5):
-		t.Fatal("Timeout waiting for TargetHitChan")
+		t.Fatal("Timeout waiting for the target event")
 	}
 }
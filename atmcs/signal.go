@@ -0,0 +1,82 @@
+package atmcs
+
+import (
+	"errors"
+	"fmt"
+
+	cpr "github.com/dragonzurfer/strategy/CPR"
+	"github.com/dragonzurfer/trader/executor"
+	"github.com/dragonzurfer/trader/executor/signals"
+)
+
+const (
+	CPRSignal          = "cpr"
+	OpeningRangeSignal = "opening_range"
+)
+
+// SignalSettings picks the signal provider. CPR is used when unset.
+type SignalSettings struct {
+	Provider            string  `json:"provider"`
+	OpeningRangeCandles int     `json:"opening_range_candles"`
+	RiskReward          float64 `json:"risk_reward"`
+	// TickCandles computes the signal on 5min candles built from the ticks
+	// passed to ExitOnTick instead of those fetched from the broker.
+	TickCandles bool `json:"tick_candles"`
+}
+
+// CPRSignalProvider is the original CPR reversal signal. The cpr.Signal it
+// was built from is kept in Metadata under "cpr".
+type CPRSignalProvider struct {
+	MinStopLossPercent float64
+	MinTargetPercent   float64
+}
+
+func (p CPRSignalProvider) GetSignal(input executor.SignalInput) (executor.Signal, error) {
+	if len(input.CurrentDayCandles) == 0 || input.PreviousDayCandle == nil {
+		return executor.Signal{}, errors.New("GetSignal(): CPR needs the previous day and at least one intraday candle")
+	}
+	previousDay := NewCandleAdapter([]executor.CandleLike{input.PreviousDayCandle})
+	cprSignal := cpr.GetCPRSignal(p.MinStopLossPercent, p.MinTargetPercent, previousDay, NewCandleAdapter(input.CurrentDayCandles))
+
+	signal := executor.Signal{
+		Direction:     executor.Nuetral,
+		EntryPrice:    cprSignal.EntryPrice,
+		StopLossPrice: cprSignal.StopLossPrice,
+		TargetPrice:   cprSignal.TargetPrice,
+		Message:       cprSignal.Message,
+		Metadata: map[string]interface{}{
+			"cpr":            cprSignal,
+			"crossed_levels": cprSignal.CrossedLevels,
+			"target_levels":  cprSignal.TargetLevels,
+		},
+	}
+	switch cprSignal.Signal {
+	case cpr.Buy:
+		signal.Direction = executor.Buy
+	case cpr.Sell:
+		signal.Direction = executor.Sell
+	}
+	return signal, nil
+}
+
+// signalProvider returns SignalProvider when one was set, or the provider
+// configured in settings.
+func (obj *ATMcs) signalProvider() (executor.SignalProviderLike, error) {
+	if obj.SignalProvider != nil {
+		return obj.SignalProvider, nil
+	}
+	settings := obj.Settings.SignalSettings
+	switch settings.Provider {
+	case "", CPRSignal:
+		return CPRSignalProvider{
+			MinStopLossPercent: obj.Settings.MinStopLossPercent,
+			MinTargetPercent:   obj.Settings.MinTargetPercent,
+		}, nil
+	case OpeningRangeSignal:
+		if settings.OpeningRangeCandles <= 0 {
+			return nil, fmt.Errorf("signal provider %v needs opening_range_candles > 0", settings.Provider)
+		}
+		return signals.OpeningRange{Candles: settings.OpeningRangeCandles, RiskReward: settings.RiskReward}, nil
+	}
+	return nil, fmt.Errorf("unknown signal provider %q", settings.Provider)
+}
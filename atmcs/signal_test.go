@@ -0,0 +1,162 @@
+package atmcs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dragonzurfer/trader/executor"
+	"github.com/dragonzurfer/trader/executor/replay"
+	"github.com/stretchr/testify/assert"
+)
+
+type fixedSignalProvider struct {
+	signal executor.Signal
+	input  executor.SignalInput
+}
+
+func (p *fixedSignalProvider) GetSignal(input executor.SignalInput) (executor.Signal, error) {
+	p.input = input
+	return p.signal, nil
+}
+
+// signalTestBroker serves the daily candle of 2023-05-15 and the 5min
+// candles of 2023-05-16 up to 9:30.
+func signalTestBroker(loc *time.Location) *fakeBroker {
+	broker := newFakeBroker(18310, testExpiries(loc))
+	at := func(day, h, m int) time.Time { return time.Date(2023, 5, day, h, m, 0, 0, loc) }
+	broker.candles[executor.Day] = []executor.CandleLike{
+		replay.Candle{Time: at(15, 0, 0), Open: 18200, High: 18400, Low: 18150, Close: 18300},
+	}
+	broker.candles[executor.Minute5] = []executor.CandleLike{
+		replay.Candle{Time: at(16, 9, 15), Open: 18300, High: 18320, Low: 18290, Close: 18310},
+		replay.Candle{Time: at(16, 9, 20), Open: 18310, High: 18315, Low: 18295, Close: 18300},
+		replay.Candle{Time: at(16, 9, 25), Open: 18300, High: 18305, Low: 18270, Close: 18275},
+	}
+	return broker
+}
+
+func TestIsEntrySatisfiedUsesSignalProvider(t *testing.T) {
+	now := istTime(t, "2023-05-16T09:30:00+05:30")
+	atm := newTestATMcs(t, nil, func() time.Time { return now })
+	atm.SetBroker(signalTestBroker(atm.ISTLocation))
+	provider := &fixedSignalProvider{signal: executor.Signal{
+		Direction:     executor.Sell,
+		EntryPrice:    18275,
+		StopLossPrice: 18320,
+		TargetPrice:   18200,
+	}}
+	atm.SignalProvider = provider
+
+	assert.True(t, atm.IsEntrySatisfied())
+	assert.Equal(t, executor.Sell, atm.GetTradeType())
+	assert.Equal(t, 18320.0, atm.Trade.StopLossPrice)
+	assert.Equal(t, 18200.0, atm.Trade.TargetPrice)
+	assert.Len(t, provider.input.CurrentDayCandles, 3)
+	assert.Equal(t, 18400.0, provider.input.PreviousDayCandle.GetHigh())
+	assert.True(t, provider.input.Time.Equal(now))
+
+	provider.signal = executor.NeutralSignal("nothing")
+	atm.EntrySatisfied = false
+	assert.False(t, atm.IsEntrySatisfied())
+}
+
+func TestOpeningRangeSignalFromSettings(t *testing.T) {
+	now := istTime(t, "2023-05-16T09:30:00+05:30")
+	atm := newTestATMcs(t, map[string]interface{}{
+		"signal": map[string]interface{}{"provider": "opening_range", "opening_range_candles": 2},
+	}, func() time.Time { return now })
+	atm.SetBroker(signalTestBroker(atm.ISTLocation))
+
+	assert.True(t, atm.IsEntrySatisfied())
+	assert.Equal(t, executor.Sell, atm.GetTradeType())
+	assert.Equal(t, 18275.0, atm.Trade.EntryPrice)
+	assert.Equal(t, 18320.0, atm.Trade.StopLossPrice)
+	assert.Equal(t, 18230.0, atm.Trade.TargetPrice)
+}
+
+func TestSignalCandlesAreCached(t *testing.T) {
+	now := istTime(t, "2023-05-16T09:30:00+05:30")
+	atm := newTestATMcs(t, nil, func() time.Time { return now })
+	broker := signalTestBroker(atm.ISTLocation)
+	atm.SetBroker(broker)
+	atm.SignalProvider = &fixedSignalProvider{signal: executor.NeutralSignal("")}
+
+	atm.IsEntrySatisfied()
+	now = now.Add(time.Minute)
+	atm.IsEntrySatisfied()
+
+	assert.Equal(t, 1, broker.candleCalls[executor.Day])
+	assert.Equal(t, 1, broker.candleCalls[executor.Minute5])
+}
+
+func TestSignalCandlesFromConfiguredTimeFrames(t *testing.T) {
+	now := istTime(t, "2023-05-16T09:30:00+05:30")
+	atm := newTestATMcs(t, map[string]interface{}{
+		"candle_timeframes": []string{"min", "1Day"},
+	}, func() time.Time { return now })
+	broker := signalTestBroker(atm.ISTLocation)
+	for i := 0; i < 15; i++ {
+		price := 18300 + float64(i)
+		broker.candles[executor.Minute] = append(broker.candles[executor.Minute], replay.Candle{
+			Time: istTime(t, "2023-05-16T09:15:00+05:30").Add(time.Duration(i) * time.Minute),
+			Open: price, High: price + 1, Low: price - 1, Close: price,
+		})
+	}
+	atm.SetBroker(broker)
+	provider := &fixedSignalProvider{signal: executor.NeutralSignal("")}
+	atm.SignalProvider = provider
+
+	atm.IsEntrySatisfied()
+
+	assert.Equal(t, 0, broker.candleCalls[executor.Minute5])
+	assert.Len(t, provider.input.CurrentDayCandles, 3)
+	assert.Equal(t, 18305.0, provider.input.CurrentDayCandles[1].GetOpen())
+	assert.Equal(t, 18315.0, provider.input.CurrentDayCandles[2].GetHigh())
+}
+
+func TestSignalOnTickCandles(t *testing.T) {
+	now := istTime(t, "2023-05-16T09:15:00+05:30")
+	atm := newTestATMcs(t, map[string]interface{}{
+		"signal": map[string]interface{}{"tick_candles": true},
+	}, func() time.Time { return now })
+	broker := signalTestBroker(atm.ISTLocation)
+	atm.SetBroker(broker)
+	provider := &fixedSignalProvider{signal: executor.NeutralSignal("")}
+	atm.SignalProvider = provider
+
+	for _, price := range []float64{18300, 18320, 18290, 18310, 18330, 18280} {
+		atm.ExitOnTick(price)
+		now = now.Add(2 * time.Minute)
+	}
+	atm.IsEntrySatisfied()
+
+	assert.Equal(t, 0, broker.candleCalls[executor.Minute5])
+	assert.Len(t, provider.input.CurrentDayCandles, 2)
+	assert.Equal(t, 18320.0, provider.input.CurrentDayCandles[0].GetHigh())
+	assert.Equal(t, 18330.0, provider.input.CurrentDayCandles[1].GetClose())
+	assert.Equal(t, 18400.0, provider.input.PreviousDayCandle.GetHigh())
+}
+
+func TestUnknownSignalProvider(t *testing.T) {
+	atm := &ATMcs{}
+	atm.Settings.SignalSettings.Provider = "supertrend"
+	_, err := atm.signalProvider()
+	assert.NotNil(t, err)
+
+	atm.Settings.SignalSettings.Provider = OpeningRangeSignal
+	_, err = atm.signalProvider()
+	assert.NotNil(t, err)
+}
+
+func TestAggregateCandle(t *testing.T) {
+	candle := NewAggregateCandle([]executor.CandleLike{
+		replay.Candle{Open: 100, High: 110, Low: 95, Close: 105, Volume: 10},
+		replay.Candle{Open: 105, High: 120, Low: 101, Close: 115, Volume: 5},
+	})
+	assert.Equal(t, 100.0, candle.GetOpen())
+	assert.Equal(t, 120.0, candle.GetHigh())
+	assert.Equal(t, 95.0, candle.GetLow())
+	assert.Equal(t, 115.0, candle.GetClose())
+	assert.Equal(t, 15.0, candle.GetVolume())
+	assert.Nil(t, NewAggregateCandle(nil))
+}
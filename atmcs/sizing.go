@@ -0,0 +1,230 @@
+package atmcs
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/dragonzurfer/trader/executor"
+)
+
+type SizingMethod string
+
+const (
+	// QuantitySizing enters Settings.Quantity as it is, the original
+	// behaviour and the default.
+	QuantitySizing SizingMethod = "quantity"
+	FixedLotSizing SizingMethod = "fixed_lots"
+	// RiskSizing loses Sizing.RiskPercent of Sizing.Capital when the
+	// underlying moves from the signal's entry to its stop loss.
+	RiskSizing SizingMethod = "risk_per_trade"
+	// CapitalSizing holds Sizing.CapitalPercent of Sizing.Capital in
+	// notional of the underlying.
+	CapitalSizing SizingMethod = "capital_percent"
+	// VolatilitySizing loses Sizing.RiskPercent of Sizing.Capital on a
+	// move of ATRMultiplier average true ranges.
+	VolatilitySizing SizingMethod = "volatility"
+)
+
+// maxLotStep bounds the search for a base lot count that keeps every leg
+// in whole lots.
+const maxLotStep = 100
+
+// Sizing decides the quantity of the base leg of an entry, the quantity
+// LegTemplate.Ratio scales. All methods but quantity round down to whole
+// lots, in steps that keep every leg in whole lots.
+type Sizing struct {
+	Method SizingMethod `json:"method"`
+	// Lots is the number of base lots of fixed_lots.
+	Lots int64 `json:"lots"`
+	// Capital is the account size the percentages are of.
+	Capital        float64 `json:"capital"`
+	RiskPercent    float64 `json:"risk_percent"`
+	CapitalPercent float64 `json:"capital_percent"`
+	// Delta is the loss of one unit of the base leg per point the
+	// underlying moves, 1 by default.
+	Delta float64 `json:"delta"`
+	// ATRPeriod and ATRMultiplier of the volatility method, over
+	// TimeFrame candles, 1Day by default.
+	ATRPeriod     int                `json:"atr_period"`
+	ATRMultiplier float64            `json:"atr_multiplier"`
+	TimeFrame     executor.TimeFrame `json:"timeframe"`
+	// MinLots and MaxLots bound the sized base lots. Below MinLots, 1 by
+	// default, the entry is skipped.
+	MinLots int64 `json:"min_lots"`
+	MaxLots int64 `json:"max_lots"`
+	// LotSizes are the lot sizes by underlying symbol, over
+	// Settings.LotSize.
+	LotSizes map[string]int64 `json:"lot_sizes"`
+}
+
+func (s Sizing) method() SizingMethod {
+	if s.Method == "" {
+		return QuantitySizing
+	}
+	return s.Method
+}
+
+func (s Sizing) delta() float64 {
+	if s.Delta == 0 {
+		return 1
+	}
+	return s.Delta
+}
+
+func (s Sizing) timeFrame() executor.TimeFrame {
+	if s.TimeFrame == "" {
+		return executor.Day
+	}
+	return s.TimeFrame
+}
+
+func (s Sizing) validate(legs []LegTemplate) error {
+	for symbol, lotSize := range s.LotSizes {
+		if lotSize <= 0 {
+			return fmt.Errorf("sizing lot size of %v must be > 0", symbol)
+		}
+	}
+	if s.Capital < 0 || s.RiskPercent < 0 || s.CapitalPercent < 0 || s.Delta < 0 || s.MinLots < 0 || s.MaxLots < 0 {
+		return errors.New("sizing values cannot be negative")
+	}
+	if s.MaxLots > 0 && s.MaxLots < s.MinLots {
+		return errors.New("sizing max_lots cannot be less than min_lots")
+	}
+	method := s.method()
+	if method == QuantitySizing {
+		return nil
+	}
+	step, err := lotStep(legs)
+	if err != nil {
+		return err
+	}
+	switch method {
+	case FixedLotSizing:
+		if s.Lots <= 0 || s.Lots%step != 0 {
+			return fmt.Errorf("sizing fixed_lots needs lots in multiples of %v to keep the hedge ratio, got %v", step, s.Lots)
+		}
+	case RiskSizing:
+		if s.Capital <= 0 || s.RiskPercent <= 0 {
+			return errors.New("sizing risk_per_trade needs capital and risk_percent > 0")
+		}
+	case CapitalSizing:
+		if s.Capital <= 0 || s.CapitalPercent <= 0 {
+			return errors.New("sizing capital_percent needs capital and capital_percent > 0")
+		}
+	case VolatilitySizing:
+		if s.Capital <= 0 || s.RiskPercent <= 0 || s.ATRPeriod <= 0 || s.ATRMultiplier <= 0 {
+			return errors.New("sizing volatility needs capital, risk_percent, atr_period and atr_multiplier > 0")
+		}
+		if s.timeFrame().Duration() == 0 {
+			return fmt.Errorf("sizing volatility cannot use %v candles", s.TimeFrame)
+		}
+	default:
+		return fmt.Errorf("unknown sizing method %q", s.Method)
+	}
+	return nil
+}
+
+// lotStep is the fewest base lots for which the ratio of every leg comes
+// to a whole number of lots, e.g. 2 for the calendar spread's half hedge.
+func lotStep(legs []LegTemplate) (int64, error) {
+	for step := int64(1); step <= maxLotStep; step++ {
+		whole := true
+		for _, leg := range legs {
+			lots := float64(step) * leg.ratio()
+			if math.Abs(lots-math.Round(lots)) > 1e-9 || math.Round(lots) < 1 {
+				whole = false
+				break
+			}
+		}
+		if whole {
+			return step, nil
+		}
+	}
+	var ratios []float64
+	for _, leg := range legs {
+		ratios = append(ratios, leg.ratio())
+	}
+	return 0, fmt.Errorf("hedge ratios %v cannot be kept in whole lots", ratios)
+}
+
+// lotSize is the lot size of Symbol's options, from Sizing.LotSizes or
+// Settings.LotSize, 1 when neither is set.
+func (obj *ATMcs) lotSize() int64 {
+	if lotSize, ok := obj.Settings.Sizing.LotSizes[obj.Symbol]; ok {
+		return lotSize
+	}
+	if obj.Settings.LotSize > 0 {
+		return obj.Settings.LotSize
+	}
+	return 1
+}
+
+// entryQuantity sizes the base leg of an entry on the underlying at ltp.
+func (obj *ATMcs) entryQuantity(ltp float64) (int64, error) {
+	sizing := obj.Settings.Sizing
+	method := sizing.method()
+	if method == QuantitySizing {
+		return obj.Quantity, nil
+	}
+	lotSize := float64(obj.lotSize())
+	var lots float64
+	switch method {
+	case FixedLotSizing:
+		lots = float64(sizing.Lots)
+	case RiskSizing:
+		distance := math.Abs(obj.Trade.EntryPrice - obj.Trade.StopLossPrice)
+		if distance == 0 {
+			return 0, errors.New("entryQuantity(): risk_per_trade needs a stop loss away from the entry")
+		}
+		lots = sizing.Capital * sizing.RiskPercent / 100 / (distance * sizing.delta()) / lotSize
+	case CapitalSizing:
+		if ltp <= 0 {
+			return 0, fmt.Errorf("entryQuantity(): cannot size on an underlying at %v", ltp)
+		}
+		lots = sizing.Capital * sizing.CapitalPercent / 100 / ltp / lotSize
+	case VolatilitySizing:
+		atr, err := obj.sizingATR()
+		if err != nil {
+			return 0, fmt.Errorf("entryQuantity(): %w", err)
+		}
+		lots = sizing.Capital * sizing.RiskPercent / 100 / (sizing.ATRMultiplier * atr * sizing.delta()) / lotSize
+	}
+
+	step, err := lotStep(obj.legTemplates())
+	if err != nil {
+		return 0, fmt.Errorf("entryQuantity(): %w", err)
+	}
+	baseLots := int64(math.Floor(lots/float64(step))) * step
+	if sizing.MaxLots > 0 && baseLots > sizing.MaxLots {
+		baseLots = sizing.MaxLots / step * step
+	}
+	minLots := sizing.MinLots
+	if minLots == 0 {
+		minLots = 1
+	}
+	if baseLots < minLots || baseLots == 0 {
+		return 0, fmt.Errorf("entryQuantity(): %v sizing of %.2f lots is under %v lots in steps of %v", method, lots, minLots, step)
+	}
+	return baseLots * obj.lotSize(), nil
+}
+
+// sizingATR is the average true range of the underlying over the last
+// Sizing.ATRPeriod candles before now.
+func (obj *ATMcs) sizingATR() (float64, error) {
+	sizing := obj.Settings.Sizing
+	tf := sizing.timeFrame()
+	now := obj.GetCurrentTime()
+	// enough calendar time for the period across weekends and holidays
+	from := now.Add(-time.Duration(sizing.ATRPeriod*2+10) * tf.Duration())
+	candles, err := obj.candleStore().GetCandles(obj.Symbol, from, now, tf)
+	if err != nil {
+		return 0, err
+	}
+	atr, ok := averageTrueRange(candles, sizing.ATRPeriod)
+	if !ok || atr <= 0 {
+		return 0, fmt.Errorf("not enough %v candles for an average true range of %v", tf, sizing.ATRPeriod)
+	}
+	return atr, nil
+}
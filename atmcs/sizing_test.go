@@ -0,0 +1,125 @@
+package atmcs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dragonzurfer/trader/executor"
+	"github.com/dragonzurfer/trader/executor/replay"
+	"github.com/stretchr/testify/assert"
+)
+
+// newSizingATMcs returns an ATMcs on NIFTY at 18310 with lots of 25,
+// sizing the default calendar spread with its half hedge.
+func newSizingATMcs(t *testing.T, sizing map[string]interface{}) (*ATMcs, *fakeBroker) {
+	t.Helper()
+	now := istTime(t, "2023-05-16T10:20:00+05:30")
+	atm := newTestATMcs(t, map[string]interface{}{"sizing": sizing, "lot_size": 25}, func() time.Time { return now })
+	broker := newFakeBroker(18310, testExpiries(atm.ISTLocation))
+	atm.SetBroker(broker)
+	atm.Trade.EntryPrice = 18310
+	atm.Trade.StopLossPrice = 18210
+	return atm, broker
+}
+
+func TestFixedLotSizing(t *testing.T) {
+	atm, _ := newSizingATMcs(t, map[string]interface{}{
+		"method":    "fixed_lots",
+		"lots":      4,
+		"lot_sizes": map[string]interface{}{"NSE:NIFTY50-INDEX": 50},
+	})
+	atm.PaperTrade(executor.Buy)
+
+	assert.True(t, atm.InTrade())
+	assert.Equal(t, []int64{200, 100}, quantities(atm))
+}
+
+func TestRiskPerTradeSizing(t *testing.T) {
+	atm, _ := newSizingATMcs(t, map[string]interface{}{
+		"method":       "risk_per_trade",
+		"capital":      1000000,
+		"risk_percent": 1,
+		"delta":        0.5,
+	})
+	// 10000 at risk over 100 points at half a delta is 200, 8 lots
+	quantity, err := atm.entryQuantity(18310)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(200), quantity)
+
+	// 153 units is 6.15 lots, kept to an even count for the hedge
+	atm.Trade.StopLossPrice = 18180
+	quantity, err = atm.entryQuantity(18310)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(150), quantity)
+
+	atm.Trade.StopLossPrice = atm.Trade.EntryPrice
+	_, err = atm.entryQuantity(18310)
+	assert.NotNil(t, err)
+}
+
+func TestCapitalPercentSizing(t *testing.T) {
+	atm, _ := newSizingATMcs(t, map[string]interface{}{
+		"method":          "capital_percent",
+		"capital":         2000000,
+		"capital_percent": 50,
+		"max_lots":        2,
+	})
+	// 54 units of notional would be 2 lots, as many as max_lots allows
+	atm.PaperTrade(executor.Buy)
+	assert.True(t, atm.InTrade())
+	assert.Equal(t, []int64{50, 25}, quantities(atm))
+
+	atm, _ = newSizingATMcs(t, map[string]interface{}{
+		"method":          "capital_percent",
+		"capital":         200000,
+		"capital_percent": 50,
+	})
+	_, err := atm.entryQuantity(18310)
+	assert.NotNil(t, err, "less than the two lots of a hedged entry")
+	assert.Nil(t, atm.makeEntryPositions(executor.Buy))
+	assert.True(t, atm.IsError())
+}
+
+func TestVolatilitySizing(t *testing.T) {
+	atm, broker := newSizingATMcs(t, map[string]interface{}{
+		"method":         "volatility",
+		"capital":        1000000,
+		"risk_percent":   1,
+		"atr_period":     2,
+		"atr_multiplier": 1,
+	})
+	at := func(day int) time.Time { return time.Date(2023, 5, day, 0, 0, 0, 0, atm.ISTLocation) }
+	broker.candles[executor.Day] = []executor.CandleLike{
+		replay.Candle{Time: at(11), Open: 18250, High: 18400, Low: 18200, Close: 18300},
+		replay.Candle{Time: at(12), Open: 18300, High: 18350, Low: 18250, Close: 18300},
+		replay.Candle{Time: at(15), Open: 18300, High: 18450, Low: 18250, Close: 18400},
+	}
+	// true ranges of 100 and 200 put 10000 at risk over 66 units
+	quantity, err := atm.entryQuantity(18310)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(50), quantity)
+
+	broker.candles[executor.Day] = broker.candles[executor.Day][:1]
+	atm.Candles = nil
+	_, err = atm.entryQuantity(18310)
+	assert.NotNil(t, err)
+}
+
+func TestSizingValidation(t *testing.T) {
+	assert.Nil(t, Sizing{}.validate(calendarSpread))
+	assert.Nil(t, Sizing{Method: FixedLotSizing, Lots: 4}.validate(calendarSpread))
+	assert.NotNil(t, Sizing{Method: FixedLotSizing, Lots: 3}.validate(calendarSpread), "the half hedge needs an even lot count")
+	assert.Nil(t, Sizing{Method: FixedLotSizing, Lots: 3}.validate([]LegTemplate{{Side: executor.Sell}}))
+
+	thirds := []LegTemplate{{Side: executor.Sell}, {Side: executor.Buy, Ratio: 0.3333}}
+	assert.NotNil(t, Sizing{Method: FixedLotSizing, Lots: 3}.validate(thirds))
+	step, err := lotStep([]LegTemplate{{Side: executor.Sell}, {Side: executor.Buy, Ratio: 0.3}})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(10), step)
+
+	assert.NotNil(t, Sizing{Method: RiskSizing, Capital: 100000}.validate(calendarSpread))
+	assert.NotNil(t, Sizing{Method: VolatilitySizing, Capital: 100000, RiskPercent: 1}.validate(calendarSpread))
+	assert.NotNil(t, Sizing{Method: "kelly"}.validate(calendarSpread))
+	assert.NotNil(t, Sizing{LotSizes: map[string]int64{"NSE:NIFTY50-INDEX": 0}}.validate(calendarSpread))
+	assert.NotNil(t, Sizing{MinLots: 4, MaxLots: 2}.validate(calendarSpread))
+}
@@ -0,0 +1,224 @@
+package atmcs
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/dragonzurfer/trader/executor"
+	"github.com/dragonzurfer/trader/executor/pricing"
+)
+
+type StrikePolicy string
+
+const (
+	// Nearest100ITM is the original policy and the default: the nearest
+	// in the money multiple of 100, regardless of StrikeDiff.
+	Nearest100ITM StrikePolicy = "nearest_100_itm"
+	ATMStrike     StrikePolicy = "atm"
+	ITMStrike     StrikePolicy = "itm"
+	OTMStrike     StrikePolicy = "otm"
+	DeltaStrike   StrikePolicy = "delta"
+	PremiumStrike StrikePolicy = "premium"
+)
+
+const defaultSearchStrikes = 10
+
+// StrikeSelection configures how the strike of a spread is picked. Strike
+// intervals come from Settings.StrikeDiff.
+type StrikeSelection struct {
+	Policy StrikePolicy `json:"policy"`
+	// Strikes is how many strikes in or out of the money the itm and otm
+	// policies move away from ATM.
+	Strikes int64 `json:"strikes"`
+	// Delta is the absolute delta the delta policy aims for.
+	Delta float64 `json:"delta"`
+	// Premium is the option price the premium policy aims for.
+	Premium float64 `json:"premium"`
+	// SearchStrikes is how many strikes either side of ATM the delta and
+	// premium policies consider.
+	SearchStrikes int64   `json:"search_strikes"`
+	RiskFreeRate  float64 `json:"risk_free_rate"`
+}
+
+// StrikeQuery describes the option a strike is being picked for.
+type StrikeQuery struct {
+	LTP        float64
+	Expiry     time.Time
+	OptionType executor.OptionType
+	Time       time.Time
+	Broker     executor.BrokerLike
+}
+
+type StrikeSelectorLike interface {
+	SelectStrike(StrikeQuery) (float64, error)
+}
+
+// NewStrikeSelector returns the selector for selection, with strikes spaced
+// strikeDiff apart.
+func NewStrikeSelector(selection StrikeSelection, strikeDiff float64) (StrikeSelectorLike, error) {
+	if selection.Policy == "" || selection.Policy == Nearest100ITM {
+		return nearest100ITMSelector{}, nil
+	}
+	if strikeDiff <= 0 {
+		return nil, fmt.Errorf("strike policy %v needs strikeDiff > 0", selection.Policy)
+	}
+	search := selection.SearchStrikes
+	if search <= 0 {
+		search = defaultSearchStrikes
+	}
+	switch selection.Policy {
+	case ATMStrike:
+		return offsetSelector{strikeDiff: strikeDiff}, nil
+	case ITMStrike:
+		return offsetSelector{strikeDiff: strikeDiff, itm: selection.Strikes}, nil
+	case OTMStrike:
+		return offsetSelector{strikeDiff: strikeDiff, itm: -selection.Strikes}, nil
+	case DeltaStrike:
+		if selection.Delta <= 0 || selection.Delta >= 1 {
+			return nil, fmt.Errorf("strike policy delta needs 0 < delta < 1, got %v", selection.Delta)
+		}
+		return &deltaSelector{strikeDiff: strikeDiff, search: search, delta: selection.Delta, rate: selection.RiskFreeRate}, nil
+	case PremiumStrike:
+		if selection.Premium <= 0 {
+			return nil, fmt.Errorf("strike policy premium needs premium > 0, got %v", selection.Premium)
+		}
+		return &premiumSelector{strikeDiff: strikeDiff, search: search, premium: selection.Premium}, nil
+	}
+	return nil, fmt.Errorf("unknown strike policy %q", selection.Policy)
+}
+
+// strikeSelector returns StrikeSelector when one was set, or the selector
+// configured in settings.
+func (obj *ATMcs) strikeSelector() (StrikeSelectorLike, error) {
+	if obj.StrikeSelector != nil {
+		return obj.StrikeSelector, nil
+	}
+	return NewStrikeSelector(obj.Settings.StrikeSelection, obj.Settings.StrikeDiff)
+}
+
+type nearest100ITMSelector struct{}
+
+func (nearest100ITMSelector) SelectStrike(query StrikeQuery) (float64, error) {
+	// GetNearest100ITMStrike is in terms of the spread's signal: puts for
+	// buys and calls for sells.
+	if query.OptionType == executor.PutOption {
+		return GetNearest100ITMStrike(query.LTP, executor.Buy), nil
+	}
+	return GetNearest100ITMStrike(query.LTP, executor.Sell), nil
+}
+
+// offsetSelector moves itm strikes into the money from ATM, or out of the
+// money when itm is negative.
+type offsetSelector struct {
+	strikeDiff float64
+	itm        int64
+}
+
+func (s offsetSelector) SelectStrike(query StrikeQuery) (float64, error) {
+	return offsetStrike(atmStrike(query.LTP, s.strikeDiff), s.strikeDiff, s.itm, query.OptionType), nil
+}
+
+func atmStrike(ltp, strikeDiff float64) float64 {
+	return math.Round(ltp/strikeDiff) * strikeDiff
+}
+
+// offsetStrike is strike moved itm strikes into the money: down for calls
+// and up for puts.
+func offsetStrike(strike, strikeDiff float64, itm int64, optionType executor.OptionType) float64 {
+	offset := float64(itm) * strikeDiff
+	if optionType == executor.PutOption {
+		return strike + offset
+	}
+	return strike - offset
+}
+
+// candidateStrikes are the strikes within search strikes of ATM.
+func candidateStrikes(ltp, strikeDiff float64, search int64) []float64 {
+	atm := atmStrike(ltp, strikeDiff)
+	strikes := make([]float64, 0, 2*search+1)
+	for i := -search; i <= search; i++ {
+		if strike := atm + float64(i)*strikeDiff; strike > 0 {
+			strikes = append(strikes, strike)
+		}
+	}
+	return strikes
+}
+
+// midPrice is the mid of the best bid and ask of an option, or whichever
+// side is quoted.
+func midPrice(broker executor.BrokerLike, strike float64, expiry time.Time, optionType executor.OptionType) (float64, error) {
+	depth, err := broker.GetMarketDepthOption(strike, expiry, optionType)
+	if err != nil {
+		return 0, err
+	}
+	bids, asks := depth.GetBids(), depth.GetAsks()
+	switch {
+	case len(bids) > 0 && len(asks) > 0:
+		return (bids[0].GetPrice() + asks[0].GetPrice()) / 2, nil
+	case len(bids) > 0:
+		return bids[0].GetPrice(), nil
+	case len(asks) > 0:
+		return asks[0].GetPrice(), nil
+	}
+	return 0, fmt.Errorf("no quotes for %v %v %v", strike, optionType, expiry)
+}
+
+// deltaSelector picks the strike whose delta, implied from its quoted mid
+// price, is closest to delta.
+type deltaSelector struct {
+	strikeDiff float64
+	search     int64
+	delta      float64
+	rate       float64
+}
+
+func (s *deltaSelector) SelectStrike(query StrikeQuery) (float64, error) {
+	years := pricing.YearsToExpiry(query.Time, query.Expiry)
+	best, bestDiff := 0.0, math.Inf(1)
+	for _, strike := range candidateStrikes(query.LTP, s.strikeDiff, s.search) {
+		price, err := midPrice(query.Broker, strike, query.Expiry, query.OptionType)
+		if err != nil {
+			continue
+		}
+		contract := pricing.Contract{Type: query.OptionType, Strike: strike, Underlying: query.LTP, Years: years, Rate: s.rate}
+		vol, err := pricing.BlackScholes.ImpliedVolatility(contract, price)
+		if err != nil {
+			continue
+		}
+		delta := math.Abs(pricing.BlackScholes.Greeks(contract, vol).Delta)
+		if diff := math.Abs(delta - s.delta); diff < bestDiff {
+			best, bestDiff = strike, diff
+		}
+	}
+	if math.IsInf(bestDiff, 1) {
+		return 0, errors.New("SelectStrike(): no strike with a usable quote to imply delta from")
+	}
+	return best, nil
+}
+
+// premiumSelector picks the strike whose quoted mid price is closest to
+// premium.
+type premiumSelector struct {
+	strikeDiff float64
+	search     int64
+	premium    float64
+}
+
+func (s *premiumSelector) SelectStrike(query StrikeQuery) (float64, error) {
+	best, bestDiff := 0.0, math.Inf(1)
+	for _, strike := range candidateStrikes(query.LTP, s.strikeDiff, s.search) {
+		price, err := midPrice(query.Broker, strike, query.Expiry, query.OptionType)
+		if err != nil {
+			continue
+		}
+		if diff := math.Abs(price - s.premium); diff < bestDiff {
+			best, bestDiff = strike, diff
+		}
+	}
+	if math.IsInf(bestDiff, 1) {
+		return 0, errors.New("SelectStrike(): no strike with a usable quote")
+	}
+	return best, nil
+}
@@ -0,0 +1,119 @@
+package atmcs
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/dragonzurfer/trader/executor"
+	"github.com/dragonzurfer/trader/executor/pricing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNearest100ITMIsDefault(t *testing.T) {
+	selector, err := NewStrikeSelector(StrikeSelection{}, 0)
+	assert.Nil(t, err)
+
+	put, _ := selector.SelectStrike(StrikeQuery{LTP: 18337, OptionType: executor.PutOption})
+	call, _ := selector.SelectStrike(StrikeQuery{LTP: 18337, OptionType: executor.CallOption})
+	assert.Equal(t, GetNearest100ITMStrike(18337, executor.Buy), put)
+	assert.Equal(t, GetNearest100ITMStrike(18337, executor.Sell), call)
+}
+
+func TestOffsetStrikeSelectors(t *testing.T) {
+	tests := []struct {
+		selection  StrikeSelection
+		optionType executor.OptionType
+		expected   float64
+	}{
+		{StrikeSelection{Policy: ATMStrike}, executor.CallOption, 18350},
+		{StrikeSelection{Policy: ATMStrike}, executor.PutOption, 18350},
+		{StrikeSelection{Policy: ITMStrike, Strikes: 2}, executor.CallOption, 18250},
+		{StrikeSelection{Policy: ITMStrike, Strikes: 2}, executor.PutOption, 18450},
+		{StrikeSelection{Policy: OTMStrike, Strikes: 1}, executor.CallOption, 18400},
+		{StrikeSelection{Policy: OTMStrike, Strikes: 1}, executor.PutOption, 18300},
+	}
+	for _, test := range tests {
+		selector, err := NewStrikeSelector(test.selection, 50)
+		assert.Nil(t, err)
+		strike, err := selector.SelectStrike(StrikeQuery{LTP: 18337, OptionType: test.optionType})
+		assert.Nil(t, err)
+		assert.Equal(t, test.expected, strike, "%v %v", test.selection, test.optionType)
+	}
+}
+
+func TestInvalidStrikeSelection(t *testing.T) {
+	_, err := NewStrikeSelector(StrikeSelection{Policy: ATMStrike}, 0)
+	assert.NotNil(t, err)
+	_, err = NewStrikeSelector(StrikeSelection{Policy: DeltaStrike, Delta: 1.5}, 50)
+	assert.NotNil(t, err)
+	_, err = NewStrikeSelector(StrikeSelection{Policy: "nearest"}, 50)
+	assert.NotNil(t, err)
+}
+
+func TestPremiumStrikeSelector(t *testing.T) {
+	loc, _ := time.LoadLocation("Asia/Kolkata")
+	broker := newFakeBroker(18337, testExpiries(loc))
+	for i := 0; i < 5; i++ {
+		price := 200 - float64(i)*40
+		broker.strikeDepths[18250+float64(i)*50] = newFakeBidAsk(price, price+2)
+	}
+
+	selector, err := NewStrikeSelector(StrikeSelection{Policy: PremiumStrike, Premium: 85, SearchStrikes: 3}, 50)
+	assert.Nil(t, err)
+	strike, err := selector.SelectStrike(StrikeQuery{
+		LTP:        18337,
+		Expiry:     testExpiries(loc)[0].ExpiryDate,
+		OptionType: executor.CallOption,
+		Broker:     broker,
+	})
+	assert.Nil(t, err)
+	// 18250..18450 trade at 201, 161, 121, 81, 41.
+	assert.Equal(t, 18400.0, strike)
+}
+
+func TestDeltaStrikeSelector(t *testing.T) {
+	loc, _ := time.LoadLocation("Asia/Kolkata")
+	now := time.Date(2023, 5, 16, 10, 0, 0, 0, loc)
+	expiry := testExpiries(loc)[0].ExpiryDate
+	broker := newFakeBroker(18337, testExpiries(loc))
+	put := func(strike float64) pricing.Contract {
+		return pricing.Contract{Type: executor.PutOption, Strike: strike, Underlying: 18337, Years: pricing.YearsToExpiry(now, expiry)}
+	}
+	for strike := 17850.0; strike <= 18850; strike += 50 {
+		price := pricing.BlackScholes.Price(put(strike), 0.12)
+		broker.strikeDepths[strike] = newFakeBidAsk(price, price)
+	}
+
+	for _, target := range []float64{0.5, 0.3} {
+		selector, err := NewStrikeSelector(StrikeSelection{Policy: DeltaStrike, Delta: target}, 50)
+		assert.Nil(t, err)
+		strike, err := selector.SelectStrike(StrikeQuery{
+			LTP:        18337,
+			Expiry:     expiry,
+			OptionType: executor.PutOption,
+			Time:       now,
+			Broker:     broker,
+		})
+		assert.Nil(t, err)
+		delta := math.Abs(pricing.BlackScholes.Greeks(put(strike), 0.12).Delta)
+		for other := strike - 50; other <= strike+50; other += 100 {
+			otherDelta := math.Abs(pricing.BlackScholes.Greeks(put(other), 0.12).Delta)
+			assert.LessOrEqual(t, math.Abs(delta-target), math.Abs(otherDelta-target), "target %v", target)
+		}
+	}
+}
+
+func TestPaperTradeUsesStrikeSelection(t *testing.T) {
+	now := istTime(t, "2023-05-16T10:00:00+05:30")
+	obj := newTestATMcs(t, map[string]interface{}{
+		"strike_selection": map[string]interface{}{"policy": "itm", "strikes": 1},
+	}, func() time.Time { return now })
+	obj.SetBroker(newFakeBroker(18337, testExpiries(obj.ISTLocation)))
+
+	obj.PaperTrade(executor.Sell)
+	assert.Len(t, obj.Trade.EntryPositions, 2)
+	assert.Equal(t, 18300.0, obj.Trade.EntryPositions[0].Strike)
+	assert.Equal(t, executor.CallOption, obj.Trade.EntryPositions[0].Type)
+	assert.Equal(t, 18300.0, obj.Trade.EntryPositions[1].Strike)
+}
@@ -0,0 +1,142 @@
+package atmcs
+
+import (
+	"fmt"
+
+	"github.com/dragonzurfer/trader/atmcs/trade"
+	"github.com/dragonzurfer/trader/executor"
+)
+
+type LegOptionType string
+
+const (
+	// SignalOption is the calendar spread's option type: puts on buy
+	// signals and calls on sell signals.
+	SignalOption LegOptionType = "signal"
+	// OppositeOption is the other option type to SignalOption.
+	OppositeOption LegOptionType = "opposite"
+	CallLeg        LegOptionType = LegOptionType(executor.CallOption)
+	PutLeg         LegOptionType = LegOptionType(executor.PutOption)
+)
+
+// LegTemplate describes one leg of an option structure relative to the
+// selected strike and the sell leg's expiry.
+type LegTemplate struct {
+	// Type defaults to SignalOption.
+	Type LegOptionType      `json:"type"`
+	Side executor.TradeType `json:"side"`
+	// StrikeOffset moves the leg that many StrikeDiff strikes into the
+	// money from the selected strike, or out of it when negative.
+	StrikeOffset int64 `json:"strike_offset"`
+	// Expiry defaults to Settings.SellExpiry for sold legs and
+	// Settings.HedgeExpiry for bought ones.
+	Expiry ExpiryPolicy `json:"expiry"`
+	// Ratio scales the quantity from Settings.Sizing; 0 means 1.
+	Ratio float64 `json:"ratio"`
+}
+
+// calendarSpread is the original structure, used when Settings.Legs is
+// empty: sell the selected strike and buy half as many in a later expiry.
+var calendarSpread = []LegTemplate{
+	{Type: SignalOption, Side: executor.Sell, Ratio: 1},
+	{Type: SignalOption, Side: executor.Buy, Ratio: 0.5},
+}
+
+func (obj *ATMcs) legTemplates() []LegTemplate {
+	if len(obj.Settings.Legs) == 0 {
+		return calendarSpread
+	}
+	return obj.Settings.Legs
+}
+
+func signalOptionType(signal executor.TradeType) executor.OptionType {
+	if signal == executor.Buy {
+		return executor.PutOption
+	}
+	return executor.CallOption
+}
+
+func (leg LegTemplate) optionType(signal executor.TradeType) executor.OptionType {
+	signalType := signalOptionType(signal)
+	switch leg.Type {
+	case OppositeOption:
+		if signalType == executor.PutOption {
+			return executor.CallOption
+		}
+		return executor.PutOption
+	case CallLeg, PutLeg:
+		return executor.OptionType(leg.Type)
+	}
+	return signalType
+}
+
+func (leg LegTemplate) ratio() float64 {
+	if leg.Ratio == 0 {
+		return 1
+	}
+	return leg.Ratio
+}
+
+func (leg LegTemplate) quantity(quantity int64) int64 {
+	// the epsilon keeps whole lots whole through the float product
+	return int64(float64(quantity)*leg.ratio() + 1e-9)
+}
+
+func validateLegs(settings Settings) error {
+	for i, leg := range settings.Legs {
+		switch leg.Type {
+		case "", SignalOption, OppositeOption, CallLeg, PutLeg:
+		default:
+			return fmt.Errorf("leg %v: unknown option type %q", i, leg.Type)
+		}
+		if leg.Side != executor.Buy && leg.Side != executor.Sell {
+			return fmt.Errorf("leg %v: side must be %v or %v, got %q", i, executor.Buy, executor.Sell, leg.Side)
+		}
+		if leg.StrikeOffset != 0 && settings.StrikeDiff <= 0 {
+			return fmt.Errorf("leg %v: strike_offset needs strikeDiff > 0", i)
+		}
+		if leg.Ratio < 0 || (settings.Sizing.method() == QuantitySizing && leg.quantity(settings.Quantity) < 1) {
+			return fmt.Errorf("leg %v: ratio %v leaves no quantity out of %v", i, leg.Ratio, settings.Quantity)
+		}
+		if err := leg.Expiry.validate("leg"); err != nil {
+			return fmt.Errorf("leg %v: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// legExpiry resolves the leg's expiry relative to the sell leg's.
+func (obj *ATMcs) legExpiry(leg LegTemplate, sellExpiry executor.Expiry, expiries []executor.Expiry) (executor.Expiry, error) {
+	if leg.Expiry.Rule != "" {
+		return obj.resolveExpiry(leg.Expiry, sellExpiry, expiries)
+	}
+	if leg.Side == executor.Sell {
+		return sellExpiry, nil
+	}
+	return obj.SelectHedgeExpiry(sellExpiry, expiries)
+}
+
+// makeLegPosition builds and prices one leg, at the bid for sold legs and
+// the ask for bought ones.
+func (obj *ATMcs) makeLegPosition(leg LegTemplate, signal executor.TradeType, strike float64, quantity int64, sellExpiry executor.Expiry, expiries []executor.Expiry) (trade.OptionPosition, float64, error) {
+	optionType := leg.optionType(signal)
+	expiry, err := obj.legExpiry(leg, sellExpiry, expiries)
+	if err != nil {
+		return trade.OptionPosition{}, 0, err
+	}
+	strike = offsetStrike(strike, obj.Settings.StrikeDiff, leg.StrikeOffset, optionType)
+	position := obj.MakeEntryPosition(obj.Symbol, strike, expiry, optionType, leg.Side, leg.quantity(quantity))
+
+	var depth []executor.MarketDepthLike
+	if leg.Side == executor.Sell {
+		depth, err = GetBids(obj.Broker, position)
+	} else {
+		depth, err = GetAsks(obj.Broker, position)
+	}
+	if err != nil {
+		return trade.OptionPosition{}, 0, err
+	}
+	var depthQuantity float64
+	position.Price, depthQuantity = obj.GetAvgMarketDepth(depth)
+	return position, depthQuantity, nil
+}
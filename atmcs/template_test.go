@@ -0,0 +1,134 @@
+package atmcs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dragonzurfer/trader/executor"
+	"github.com/stretchr/testify/assert"
+)
+
+func ironCondorLegs() []map[string]interface{} {
+	sameExpiry := map[string]interface{}{"rule": "sell"}
+	return []map[string]interface{}{
+		{"type": "CE", "side": "Sell", "strike_offset": -1},
+		{"type": "CE", "side": "Buy", "strike_offset": -3, "expiry": sameExpiry},
+		{"type": "PE", "side": "Sell", "strike_offset": -1},
+		{"type": "PE", "side": "Buy", "strike_offset": -3, "expiry": sameExpiry},
+	}
+}
+
+func TestDefaultLegsAreCalendarSpread(t *testing.T) {
+	now := istTime(t, "2023-05-16T10:20:00+05:30")
+	atm := newTestATMcs(t, nil, func() time.Time { return now })
+	expiries := testExpiries(atm.ISTLocation)
+	atm.SetBroker(newFakeBroker(18310, expiries))
+
+	atm.PaperTrade(executor.Buy)
+
+	positions := atm.Trade.EntryPositions
+	assert.Len(t, positions, 2)
+	assert.Equal(t, GetNearest100ITMStrike(18310, executor.Buy), positions[0].Strike)
+	assert.Equal(t, positions[0].Strike, positions[1].Strike)
+	assert.Equal(t, executor.Sell, positions[0].TradeType)
+	assert.Equal(t, executor.Buy, positions[1].TradeType)
+	assert.Equal(t, int64(100), positions[0].Quantity)
+	assert.Equal(t, int64(50), positions[1].Quantity)
+	assert.Equal(t, executor.PutOption, positions[1].Type)
+	assert.True(t, positions[0].Expiry.Equal(expiries[0].ExpiryDate))
+	assert.True(t, positions[1].Expiry.Equal(expiries[2].ExpiryDate))
+	// bids of the sold leg, asks of the bought one
+	assert.Equal(t, 100.0, positions[0].Price)
+	assert.Equal(t, 201.0, positions[1].Price)
+}
+
+func TestIronCondorTemplate(t *testing.T) {
+	now := istTime(t, "2023-05-16T10:20:00+05:30")
+	atm := newTestATMcs(t, map[string]interface{}{
+		"strike_selection": map[string]interface{}{"policy": "atm"},
+		"legs":             ironCondorLegs(),
+	}, func() time.Time { return now })
+	expiries := testExpiries(atm.ISTLocation)
+	broker := newFakeBroker(18337, expiries)
+	atm.SetBroker(broker)
+
+	atm.AccountTrade(executor.Sell)
+
+	assert.True(t, atm.InTrade())
+	assert.False(t, atm.IsError(), "unexpected errors %v", atm.ReadErrors())
+	positions := atm.Trade.EntryPositions
+	assert.Len(t, positions, 4)
+	expected := []struct {
+		strike     float64
+		optionType executor.OptionType
+		tradeType  executor.TradeType
+	}{
+		{18400, executor.CallOption, executor.Sell},
+		{18500, executor.CallOption, executor.Buy},
+		{18300, executor.PutOption, executor.Sell},
+		{18200, executor.PutOption, executor.Buy},
+	}
+	for i, leg := range expected {
+		assert.Equal(t, leg.strike, positions[i].Strike, "leg %v", i)
+		assert.Equal(t, leg.optionType, positions[i].Type, "leg %v", i)
+		assert.Equal(t, leg.tradeType, positions[i].TradeType, "leg %v", i)
+		assert.Equal(t, int64(100), positions[i].Quantity, "leg %v", i)
+		assert.True(t, positions[i].Expiry.Equal(expiries[0].ExpiryDate), "leg %v", i)
+	}
+	// wings are bought before either short is written
+	assert.Equal(t, executor.Buy, broker.placed[0].TradeType)
+	assert.Equal(t, executor.Buy, broker.placed[1].TradeType)
+
+	atm.ExitAccount()
+
+	assert.False(t, atm.InTrade())
+	assert.Len(t, broker.placed, 8)
+	assert.Len(t, atm.Trade.ExitPositions, 4)
+}
+
+func TestRatioAndStraddleTemplates(t *testing.T) {
+	now := istTime(t, "2023-05-16T10:20:00+05:30")
+	atm := newTestATMcs(t, map[string]interface{}{
+		"strike_selection": map[string]interface{}{"policy": "atm"},
+		"legs": []map[string]interface{}{
+			{"side": "Sell", "ratio": 2},
+			{"type": "opposite", "side": "Sell", "ratio": 2},
+			{"side": "Buy", "strike_offset": 1, "expiry": map[string]interface{}{"rule": "next_after_sell"}},
+		},
+	}, func() time.Time { return now })
+	expiries := testExpiries(atm.ISTLocation)
+	atm.SetBroker(newFakeBroker(18337, expiries))
+
+	atm.PaperTrade(executor.Buy)
+
+	positions := atm.Trade.EntryPositions
+	assert.Len(t, positions, 3)
+	assert.Equal(t, executor.PutOption, positions[0].Type)
+	assert.Equal(t, executor.CallOption, positions[1].Type)
+	assert.Equal(t, 18350.0, positions[0].Strike)
+	assert.Equal(t, 18350.0, positions[1].Strike)
+	assert.Equal(t, int64(200), positions[0].Quantity)
+	assert.Equal(t, 18400.0, positions[2].Strike)
+	assert.Equal(t, int64(100), positions[2].Quantity)
+	assert.True(t, positions[2].Expiry.Equal(expiries[1].ExpiryDate))
+}
+
+func TestValidateLegs(t *testing.T) {
+	settings := Settings{Quantity: 100, StrikeDiff: 50}
+	tests := []LegTemplate{
+		{Side: executor.Nuetral},
+		{Side: executor.Buy, Type: "XX"},
+		{Side: executor.Buy, Ratio: 0.001},
+		{Side: executor.Buy, Ratio: -1},
+		{Side: executor.Buy, Expiry: ExpiryPolicy{Rule: "fortnightly"}},
+	}
+	for _, leg := range tests {
+		settings.Legs = []LegTemplate{leg}
+		assert.NotNil(t, validateLegs(settings), "%+v", leg)
+	}
+
+	settings.Legs = []LegTemplate{{Side: executor.Sell, StrikeOffset: 1}}
+	assert.Nil(t, validateLegs(settings))
+	settings.StrikeDiff = 0
+	assert.NotNil(t, validateLegs(settings))
+}
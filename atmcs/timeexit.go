@@ -0,0 +1,156 @@
+package atmcs
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/dragonzurfer/trader/executor"
+	"github.com/dragonzurfer/trader/executor/calendar"
+)
+
+// TimeExit squares off trades by the clock rather than by price. Times of
+// day are "15:04" in the exchange's time zone and rules left unset are off.
+type TimeExit struct {
+	// SquareOff is the time of day from which open trades are exited.
+	SquareOff string `json:"square_off"`
+	// MaxHolding is the longest a trade is held.
+	MaxHolding DurationWrapper `json:"max_holding"`
+	// ExitBeforeExpiry exits ExpiryDays market days before the expiry day
+	// of the earliest expiring leg, or on it when 0, from ExpiryExitTime.
+	ExitBeforeExpiry bool   `json:"exit_before_expiry"`
+	ExpiryDays       int    `json:"expiry_days"`
+	ExpiryExitTime   string `json:"expiry_exit_time"`
+}
+
+// Details of time exits.
+const (
+	SquareOffExit    = "square_off"
+	MaxHoldingExit   = "max_holding"
+	BeforeExpiryExit = "before_expiry"
+)
+
+// defaultExpiryExitTime is the market open.
+const defaultExpiryExitTime = "09:15"
+
+func (t TimeExit) validate() error {
+	if t.SquareOff != "" {
+		if _, err := calendar.ParseTimeOfDay(t.SquareOff); err != nil {
+			return fmt.Errorf("square_off: %w", err)
+		}
+	}
+	if t.MaxHolding.Duration < 0 {
+		return errors.New("max_holding cannot be negative")
+	}
+	if t.ExpiryDays < 0 {
+		return errors.New("expiry_days cannot be negative")
+	}
+	if t.ExpiryExitTime != "" {
+		if _, err := calendar.ParseTimeOfDay(t.ExpiryExitTime); err != nil {
+			return fmt.Errorf("expiry_exit_time: %w", err)
+		}
+	}
+	return nil
+}
+
+// atTimeOfDay is value, a validated "15:04", on the exchange day of day.
+func (obj *ATMcs) atTimeOfDay(day time.Time, value string) time.Time {
+	offset, _ := calendar.ParseTimeOfDay(value)
+	return obj.ExchangeCalendar().At(day, offset)
+}
+
+// IsTimeExitSatisfied reports whether a time exit is due for the open
+// trade, recording the reason in the trade when it is. The caller exits.
+func (obj *ATMcs) IsTimeExitSatisfied() bool {
+	if !obj.InTrade() {
+		return false
+	}
+	detail, ok := obj.timeExitDetail(obj.GetCurrentTime())
+	if !ok {
+		return false
+	}
+	obj.setExitReason(executor.ExitTime, detail)
+	return true
+}
+
+func (obj *ATMcs) timeExitDetail(now time.Time) (string, bool) {
+	rules := obj.Settings.TimeExit
+	if rules.SquareOff != "" && !now.Before(obj.atTimeOfDay(now, rules.SquareOff)) {
+		return SquareOffExit, true
+	}
+	if rules.MaxHolding.Duration > 0 && now.Sub(obj.Trade.TimeOfEntry) >= rules.MaxHolding.Duration {
+		return MaxHoldingExit, true
+	}
+	if rules.ExitBeforeExpiry {
+		var earliest time.Time
+		for _, position := range obj.Trade.EntryPositions {
+			if earliest.IsZero() || position.Expiry.Before(earliest) {
+				earliest = position.Expiry
+			}
+		}
+		if !earliest.IsZero() && !now.Before(obj.expiryExitTime(rules, earliest)) {
+			return BeforeExpiryExit, true
+		}
+	}
+	return "", false
+}
+
+// entryTimeExit reports whether the square off or before expiry exit would
+// be due at once for a trade entered at now, and which. Such entries are
+// not made, or the trader would enter and be squared off on every step.
+func (obj *ATMcs) entryTimeExit(now time.Time) (string, bool) {
+	rules := obj.Settings.TimeExit
+	if rules.SquareOff != "" && !now.Before(obj.atTimeOfDay(now, rules.SquareOff)) {
+		return SquareOffExit, true
+	}
+	if rules.ExitBeforeExpiry {
+		earliest, err := obj.earliestEntryExpiry()
+		if err != nil {
+			log.Println("entryTimeExit():", err.Error())
+			return "", false
+		}
+		if !now.Before(obj.expiryExitTime(rules, earliest)) {
+			return BeforeExpiryExit, true
+		}
+	}
+	return "", false
+}
+
+// earliestEntryExpiry is the earliest expiry among the legs an entry would
+// be made in now.
+func (obj *ATMcs) earliestEntryExpiry() (time.Time, error) {
+	expiries, err := obj.Broker.GetOptionExpiries(obj.Symbol)
+	if err != nil {
+		return time.Time{}, err
+	}
+	sellExpiry, err := obj.SelectSellExpiry(expiries)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var earliest time.Time
+	for _, leg := range obj.legTemplates() {
+		expiry, err := obj.legExpiry(leg, sellExpiry, expiries)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if earliest.IsZero() || expiry.ExpiryDate.Before(earliest) {
+			earliest = expiry.ExpiryDate
+		}
+	}
+	return earliest, nil
+}
+
+// expiryExitTime is when ExitBeforeExpiry exits a trade whose earliest
+// expiring leg expires on earliest.
+func (obj *ATMcs) expiryExitTime(rules TimeExit, earliest time.Time) time.Time {
+	exitDay := earliest
+	for i := 0; i < rules.ExpiryDays; i++ {
+		exitDay = obj.GetPreviousNonWeekendNonHolidayDate(exitDay)
+	}
+	exitTime := rules.ExpiryExitTime
+	if exitTime == "" {
+		exitTime = defaultExpiryExitTime
+	}
+	return obj.atTimeOfDay(exitDay, exitTime)
+}
@@ -0,0 +1,132 @@
+package atmcs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dragonzurfer/trader/executor"
+	"github.com/stretchr/testify/assert"
+)
+
+func enterTimeExitTrade(t *testing.T, timeExit map[string]interface{}, now *time.Time) *ATMcs {
+	t.Helper()
+	atm := newTestATMcs(t, map[string]interface{}{"time_exit": timeExit}, func() time.Time { return *now })
+	atm.SetBroker(newFakeBroker(18310, testExpiries(atm.ISTLocation)))
+	atm.PaperTrade(executor.Buy)
+	assert.True(t, atm.InTrade())
+	return atm
+}
+
+func TestSquareOff(t *testing.T) {
+	now := istTime(t, "2023-05-16T10:20:00+05:30")
+	atm := enterTimeExitTrade(t, map[string]interface{}{"square_off": "15:15"}, &now)
+
+	now = istTime(t, "2023-05-16T15:14:00+05:30")
+	assert.False(t, atm.IsTimeExitSatisfied())
+	now = istTime(t, "2023-05-16T15:15:00+05:30")
+	assert.True(t, atm.IsTimeExitSatisfied())
+	assert.Equal(t, executor.ExitTime, atm.Trade.ExitReason)
+	assert.Equal(t, SquareOffExit, atm.Trade.ExitDetail)
+
+	atm.ExitPaper()
+	assert.Equal(t, executor.ExitTime, atm.Trade.ExitReason)
+	assert.Equal(t, SquareOffExit, atm.Trade.ExitDetail)
+	assert.False(t, atm.Trade.IsStopLossHit)
+	assert.False(t, atm.IsTimeExitSatisfied())
+}
+
+func TestMaxHolding(t *testing.T) {
+	now := istTime(t, "2023-05-16T10:20:00+05:30")
+	atm := enterTimeExitTrade(t, map[string]interface{}{"max_holding": "2h"}, &now)
+
+	now = now.Add(119 * time.Minute)
+	assert.False(t, atm.IsTimeExitSatisfied())
+	now = now.Add(time.Minute)
+	assert.True(t, atm.IsTimeExitSatisfied())
+	assert.Equal(t, MaxHoldingExit, atm.Trade.ExitDetail)
+
+	// a new entry clears the reason
+	atm.ExitPaper()
+	atm.PaperTrade(executor.Buy)
+	assert.Equal(t, executor.ExitReason(""), atm.Trade.ExitReason)
+	assert.Equal(t, "", atm.Trade.ExitDetail)
+}
+
+func TestExitBeforeExpiry(t *testing.T) {
+	now := istTime(t, "2023-05-16T10:20:00+05:30")
+	atm := enterTimeExitTrade(t, map[string]interface{}{
+		"exit_before_expiry": true,
+		"expiry_days":        1,
+		"expiry_exit_time":   "14:30",
+	}, &now)
+
+	// the sold leg expires on the 25th
+	now = istTime(t, "2023-05-24T14:29:00+05:30")
+	assert.False(t, atm.IsTimeExitSatisfied())
+	now = istTime(t, "2023-05-24T14:30:00+05:30")
+	assert.True(t, atm.IsTimeExitSatisfied())
+	assert.Equal(t, BeforeExpiryExit, atm.Trade.ExitDetail)
+
+	// market holidays are not counted: the 28th of June is one
+	atm.Trade.EntryPositions = atm.Trade.EntryPositions[1:]
+	atm.setExitReason("", "")
+	now = istTime(t, "2023-06-27T14:30:00+05:30")
+	assert.True(t, atm.IsTimeExitSatisfied())
+	now = istTime(t, "2023-06-26T14:30:00+05:30")
+	assert.False(t, atm.IsTimeExitSatisfied())
+}
+
+func TestManualExitReason(t *testing.T) {
+	now := istTime(t, "2023-05-16T10:20:00+05:30")
+	atm := enterTimeExitTrade(t, map[string]interface{}{}, &now)
+
+	atm.ExitPaper()
+	assert.Equal(t, executor.ExitManual, atm.Trade.ExitReason)
+	assert.False(t, atm.Trade.IsStopLossHit)
+	assert.Contains(t, atm.GetExitMessage(), "Exit Reason:manual")
+}
+
+func TestTimeExitValidation(t *testing.T) {
+	assert.NotNil(t, TimeExit{SquareOff: "3pm"}.validate())
+	assert.NotNil(t, TimeExit{ExpiryExitTime: "25:00"}.validate())
+	assert.NotNil(t, TimeExit{ExpiryDays: -1}.validate())
+	assert.NotNil(t, TimeExit{MaxHolding: DurationWrapper{-time.Minute}}.validate())
+	assert.Nil(t, TimeExit{SquareOff: "15:15", ExitBeforeExpiry: true}.validate())
+}
+
+func TestNoEntryWhileTimeExitDue(t *testing.T) {
+	now := istTime(t, "2023-05-16T15:10:00+05:30")
+	atm := newTestATMcs(t, map[string]interface{}{"time_exit": map[string]interface{}{"square_off": "15:15"}}, func() time.Time { return now })
+	atm.SetBroker(signalTestBroker(atm.ISTLocation))
+	atm.SignalProvider = &fixedSignalProvider{signal: executor.Signal{
+		Direction:     executor.Buy,
+		EntryPrice:    18310,
+		StopLossPrice: 18250,
+		TargetPrice:   18400,
+	}}
+	assert.True(t, atm.IsEntrySatisfied())
+	atm.PaperTrade(atm.GetTradeType())
+
+	now = istTime(t, "2023-05-16T15:15:00+05:30")
+	assert.True(t, atm.IsTimeExitSatisfied())
+	atm.ExitPaper()
+	assert.False(t, atm.IsEntrySatisfied(), "no re-entry after square off")
+	_, due := atm.entryTimeExit(istTime(t, "2023-05-17T09:30:00+05:30"))
+	assert.False(t, due)
+
+	// the sold leg would expire on the 25th, exited from the 24th
+	atm.Settings.TimeExit = TimeExit{ExitBeforeExpiry: true, ExpiryDays: 1}
+	_, due = atm.entryTimeExit(istTime(t, "2023-05-23T15:00:00+05:30"))
+	assert.False(t, due)
+	detail, due := atm.entryTimeExit(istTime(t, "2023-05-24T09:15:00+05:30"))
+	assert.True(t, due)
+	assert.Equal(t, BeforeExpiryExit, detail)
+}
+
+func TestNowFollowsCurrentTime(t *testing.T) {
+	now := istTime(t, "2023-05-16T10:20:00+05:30")
+	atm := newTestATMcs(t, nil, func() time.Time { return now })
+
+	var clock executor.ClockLike = atm
+	assert.Equal(t, now, clock.Now())
+}
@@ -0,0 +1,114 @@
+package trade
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dragonzurfer/trader/executor"
+)
+
+// Fill is a single execution reported against an order.
+type Fill struct {
+	Quantity int64
+	Price    float64
+	Time     time.Time
+}
+
+// Order tracks one broker order through pending, partially filled and a
+// terminal filled, rejected or cancelled state.
+type Order struct {
+	OrderID        string
+	Status         executor.OrderStatus
+	Quantity       int64
+	FilledQuantity int64
+	AveragePrice   float64
+	Fills          []Fill
+	PlacedAt       time.Time
+	UpdatedAt      time.Time
+	Message        string `json:",omitempty"`
+}
+
+var orderTransitions = map[executor.OrderStatus][]executor.OrderStatus{
+	executor.OrderPending: {
+		executor.OrderPending,
+		executor.OrderPartiallyFilled,
+		executor.OrderFilled,
+		executor.OrderRejected,
+		executor.OrderCancelled,
+	},
+	executor.OrderPartiallyFilled: {
+		executor.OrderPartiallyFilled,
+		executor.OrderFilled,
+		executor.OrderCancelled,
+	},
+}
+
+func NewOrder(orderID string, quantity int64, placedAt time.Time) Order {
+	return Order{
+		OrderID:   orderID,
+		Status:    executor.OrderPending,
+		Quantity:  quantity,
+		PlacedAt:  placedAt,
+		UpdatedAt: placedAt,
+	}
+}
+
+// Apply moves the order to the state reported by the broker. Updates carry
+// cumulative filled quantity and average price; the difference to the
+// previous update is recorded as a Fill.
+func (o *Order) Apply(update executor.OrderUpdate) error {
+	if update.OrderID != "" && update.OrderID != o.OrderID {
+		return fmt.Errorf("update for order %v applied to order %v", update.OrderID, o.OrderID)
+	}
+	status := update.Status
+	if status == executor.OrderPending && update.FilledQuantity > 0 {
+		status = executor.OrderPartiallyFilled
+	}
+	if o.Status.IsTerminal() {
+		if status == o.Status && update.FilledQuantity == o.FilledQuantity {
+			return nil
+		}
+		return fmt.Errorf("order %v is already %v, cannot move to %v", o.OrderID, o.Status, status)
+	}
+	if !isValidTransition(o.Status, status) {
+		return fmt.Errorf("order %v cannot move from %v to %v", o.OrderID, o.Status, status)
+	}
+	if update.FilledQuantity < o.FilledQuantity {
+		return fmt.Errorf("order %v filled quantity went back from %v to %v", o.OrderID, o.FilledQuantity, update.FilledQuantity)
+	}
+	if o.Quantity > 0 && update.FilledQuantity > o.Quantity {
+		return fmt.Errorf("order %v filled %v of %v", o.OrderID, update.FilledQuantity, o.Quantity)
+	}
+
+	if delta := update.FilledQuantity - o.FilledQuantity; delta > 0 {
+		filledValue := update.AveragePrice*float64(update.FilledQuantity) - o.AveragePrice*float64(o.FilledQuantity)
+		o.Fills = append(o.Fills, Fill{
+			Quantity: delta,
+			Price:    filledValue / float64(delta),
+			Time:     update.UpdatedAt,
+		})
+		o.FilledQuantity = update.FilledQuantity
+		o.AveragePrice = update.AveragePrice
+	}
+	o.Status = status
+	if !update.UpdatedAt.IsZero() {
+		o.UpdatedAt = update.UpdatedAt
+	}
+	if update.Message != "" {
+		o.Message = update.Message
+	}
+	return nil
+}
+
+func (o Order) IsOpen() bool {
+	return !o.Status.IsTerminal()
+}
+
+func isValidTransition(from, to executor.OrderStatus) bool {
+	for _, status := range orderTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
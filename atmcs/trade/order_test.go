@@ -0,0 +1,54 @@
+package trade
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dragonzurfer/trader/executor"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrderApplyPartialFills(t *testing.T) {
+	placedAt := time.Date(2023, 5, 16, 10, 20, 0, 0, time.UTC)
+	order := NewOrder("1", 100, placedAt)
+
+	err := order.Apply(executor.OrderUpdate{OrderID: "1", Status: executor.OrderPending, FilledQuantity: 40, AveragePrice: 10, UpdatedAt: placedAt.Add(time.Second)})
+	assert.Nil(t, err)
+	assert.Equal(t, executor.OrderPartiallyFilled, order.Status)
+
+	err = order.Apply(executor.OrderUpdate{OrderID: "1", Status: executor.OrderFilled, FilledQuantity: 100, AveragePrice: 11.2, UpdatedAt: placedAt.Add(2 * time.Second)})
+	assert.Nil(t, err)
+	assert.Equal(t, executor.OrderFilled, order.Status)
+	assert.Len(t, order.Fills, 2)
+	assert.Equal(t, int64(60), order.Fills[1].Quantity)
+	assert.InDelta(t, 12.0, order.Fills[1].Price, 1e-9)
+
+	// repeated terminal updates are ignored, changes after terminal are not
+	assert.Nil(t, order.Apply(executor.OrderUpdate{OrderID: "1", Status: executor.OrderFilled, FilledQuantity: 100, AveragePrice: 11.2}))
+	assert.NotNil(t, order.Apply(executor.OrderUpdate{OrderID: "1", Status: executor.OrderCancelled, FilledQuantity: 100}))
+}
+
+func TestOrderApplyInvalid(t *testing.T) {
+	order := NewOrder("1", 100, time.Time{})
+	assert.NotNil(t, order.Apply(executor.OrderUpdate{OrderID: "2", Status: executor.OrderFilled}))
+	assert.NotNil(t, order.Apply(executor.OrderUpdate{OrderID: "1", Status: executor.OrderFilled, FilledQuantity: 120}))
+
+	assert.Nil(t, order.Apply(executor.OrderUpdate{OrderID: "1", Status: executor.OrderPartiallyFilled, FilledQuantity: 50}))
+	assert.NotNil(t, order.Apply(executor.OrderUpdate{OrderID: "1", Status: executor.OrderRejected, FilledQuantity: 50}))
+	assert.NotNil(t, order.Apply(executor.OrderUpdate{OrderID: "1", Status: executor.OrderPartiallyFilled, FilledQuantity: 30}))
+}
+
+func TestOptionPositionAveragesOrders(t *testing.T) {
+	var position OptionPosition
+	position.AddOrder(NewOrder("1", 50, time.Time{}))
+	position.AddOrder(NewOrder("2", 50, time.Time{}))
+	assert.True(t, position.HasOpenOrders())
+
+	assert.Nil(t, position.ApplyOrderUpdate(executor.OrderUpdate{OrderID: "1", Status: executor.OrderFilled, FilledQuantity: 50, AveragePrice: 10}))
+	assert.Nil(t, position.ApplyOrderUpdate(executor.OrderUpdate{OrderID: "2", Status: executor.OrderCancelled, FilledQuantity: 25, AveragePrice: 16}))
+	assert.NotNil(t, position.ApplyOrderUpdate(executor.OrderUpdate{OrderID: "3", Status: executor.OrderFilled}))
+
+	assert.False(t, position.HasOpenOrders())
+	assert.Equal(t, int64(75), position.Quantity)
+	assert.InDelta(t, 12.0, position.Price, 1e-9)
+}
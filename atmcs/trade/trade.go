@@ -1,6 +1,7 @@
 package trade
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/dragonzurfer/trader/executor"
@@ -19,12 +20,63 @@ type OptionPosition struct {
 	Price     float64
 	TradeType executor.TradeType
 	Quantity  int64
+	Orders    []Order `json:",omitempty"`
 }
 
 func (op OptionPosition) GetTradeType() executor.TradeType { return op.TradeType }
 func (op OptionPosition) GetPrice() float64                { return op.Price }
 func (op OptionPosition) GetQuantity() int64               { return op.Quantity }
 
+// AddOrder attaches a broker order to the position. For positions with
+// orders, Quantity is the filled quantity and Price the average fill price
+// across all orders.
+func (op *OptionPosition) AddOrder(order Order) {
+	op.Orders = append(op.Orders, order)
+	op.recalculate()
+}
+
+func (op *OptionPosition) ApplyOrderUpdate(update executor.OrderUpdate) error {
+	for i := range op.Orders {
+		if op.Orders[i].OrderID == update.OrderID {
+			if err := op.Orders[i].Apply(update); err != nil {
+				return err
+			}
+			op.recalculate()
+			return nil
+		}
+	}
+	return fmt.Errorf("order %v not found on %v %v", update.OrderID, op.Strike, op.Type)
+}
+
+func (op OptionPosition) LastOrder() (Order, bool) {
+	if len(op.Orders) == 0 {
+		return Order{}, false
+	}
+	return op.Orders[len(op.Orders)-1], true
+}
+
+func (op OptionPosition) HasOpenOrders() bool {
+	for _, order := range op.Orders {
+		if order.IsOpen() {
+			return true
+		}
+	}
+	return false
+}
+
+func (op *OptionPosition) recalculate() {
+	var quantity int64
+	var value float64
+	for _, order := range op.Orders {
+		quantity += order.FilledQuantity
+		value += order.AveragePrice * float64(order.FilledQuantity)
+	}
+	op.Quantity = quantity
+	if quantity > 0 {
+		op.Price = value / float64(quantity)
+	}
+}
+
 func (o Option) GetExpiry() time.Time               { return o.Expiry }
 func (o Option) GetStrike() float64                 { return o.Strike }
 func (o Option) GetOptionType() executor.OptionType { return o.Type }
@@ -48,6 +100,19 @@ type Trade struct {
 	DepthQuantityEntryBuy  float64
 	DepthQuantityExitSell  float64
 	DepthQuantityExitBuy   float64
+
+	// InitialStopLossPrice is the stop loss at entry, before any trailing.
+	InitialStopLossPrice float64
+	// BestPrice is the most favourable underlying price since entry, that
+	// trailing stops are measured from.
+	BestPrice     float64
+	IsTrailActive bool
+	// ExitReason is why the trade was last closed, and ExitDetail which
+	// rule closed it, e.g. a premium stop or the square off time.
+	ExitReason executor.ExitReason
+	ExitDetail string
+	// RiskReason is why the pre-trade risk checks resized the entry.
+	RiskReason string `json:",omitempty"`
 }
 
 func (t *Trade) GetEntryPositions() []OptionPosition {
@@ -97,3 +162,100 @@ func (t *Trade) GetTargetPrice() float64 {
 func (t *Trade) SetTargetPrice(targetPrice float64) {
 	t.TargetPrice = targetPrice
 }
+
+func (t *Trade) HasOpenOrders() bool {
+	for _, position := range t.EntryPositions {
+		if position.HasOpenOrders() {
+			return true
+		}
+	}
+	for _, position := range t.ExitPositions {
+		if position.HasOpenOrders() {
+			return true
+		}
+	}
+	return false
+}
+
+// IsLive reports whether the trade was entered through the broker, its
+// entry legs carrying the orders that filled them.
+func (t *Trade) IsLive() bool {
+	for _, position := range t.EntryPositions {
+		if len(position.Orders) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// OpenQuantity is the quantity of entry leg i still held: its quantity
+// less what the orders of its exit position filled. Paper exits, which
+// have no orders, close the trade at once.
+func (t *Trade) OpenQuantity(i int) int64 {
+	quantity := t.EntryPositions[i].Quantity
+	if i < len(t.ExitPositions) && len(t.ExitPositions[i].Orders) > 0 {
+		quantity -= t.ExitPositions[i].Quantity
+	}
+	if quantity < 0 {
+		return 0
+	}
+	return quantity
+}
+
+// IsPartiallyEntered reports whether any entry leg was filled for less than
+// the quantity wanted, including legs whose orders were rejected outright.
+func (t *Trade) IsPartiallyEntered() bool {
+	for _, position := range t.EntryPositions {
+		for _, order := range position.Orders {
+			if order.FilledQuantity < order.Quantity && order.Status != executor.OrderPending {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RealizedPnL is the profit of the exited quantity of every leg, pairing
+// exit positions with entry positions by index.
+func (t *Trade) RealizedPnL() float64 {
+	pnl := 0.0
+	for i, exitPosition := range t.ExitPositions {
+		if i >= len(t.EntryPositions) {
+			break
+		}
+		pnl += positionPnL(t.EntryPositions[i], exitPosition.Price, exitPosition.Quantity)
+	}
+	return pnl
+}
+
+func positionPnL(entryPosition OptionPosition, exitPrice float64, quantity int64) float64 {
+	switch entryPosition.TradeType {
+	case executor.Buy:
+		return (exitPrice - entryPosition.Price) * float64(quantity)
+	case executor.Sell:
+		return (entryPosition.Price - exitPrice) * float64(quantity)
+	}
+	return 0
+}
+
+// UnrealizedPnL is the profit of the open quantity of every entry leg if it
+// were exited at the price at the same index of exitPrices.
+func (t *Trade) UnrealizedPnL(exitPrices []float64) float64 {
+	pnl := 0.0
+	for i, entryPosition := range t.EntryPositions {
+		if i >= len(exitPrices) {
+			break
+		}
+		pnl += positionPnL(entryPosition, exitPrices[i], t.OpenQuantity(i))
+	}
+	return pnl
+}
+
+// GrossPremium is the premium paid and received across all entry legs.
+func (t *Trade) GrossPremium() float64 {
+	premium := 0.0
+	for _, position := range t.EntryPositions {
+		premium += position.Price * float64(position.Quantity)
+	}
+	return premium
+}
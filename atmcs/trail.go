@@ -0,0 +1,235 @@
+package atmcs
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/dragonzurfer/trader/executor"
+)
+
+type TrailMode string
+
+const (
+	// MinTrail is the original single step: once the price has moved
+	// min_trail_percent in favour and then by the initial risk, the stop
+	// goes to entry.
+	MinTrail TrailMode = "min_trail"
+	// StepTrail moves the stop Step points for every Step points the best
+	// price gains beyond entry.
+	StepTrail TrailMode = "step"
+	// PercentTrail keeps the stop Percent percent behind the best price.
+	PercentTrail TrailMode = "percent"
+	// ATRTrail keeps the stop ATRMultiplier average true ranges behind the
+	// best price.
+	ATRTrail TrailMode = "atr"
+	// CandleTrail keeps the stop at the lowest low, or highest high for
+	// sells, of the last CandleLookback completed candles.
+	CandleTrail TrailMode = "candle"
+	// BreakEvenTrail moves the stop once, to entry plus Offset points in
+	// the trade's favour, after the price has gone past that level.
+	BreakEvenTrail TrailMode = "break_even"
+)
+
+// TrailSettings configures how the stop loss follows the underlying. The
+// stop only ever moves in the trade's favour.
+type TrailSettings struct {
+	// Mode defaults to MinTrail.
+	Mode TrailMode `json:"mode"`
+	// ActivatePercent is how far, in percent of entry, the price has to
+	// move in favour before trailing starts. Not used by MinTrail.
+	ActivatePercent float64 `json:"activate_percent"`
+	Step            float64 `json:"step"`
+	Percent         float64 `json:"percent"`
+	ATRPeriod       int     `json:"atr_period"`
+	ATRMultiplier   float64 `json:"atr_multiplier"`
+	// CandleLookback defaults to 1.
+	CandleLookback int     `json:"candle_lookback"`
+	Offset         float64 `json:"offset"`
+	// TimeFrame of the candles ATR and candle trails use, 5min by default.
+	TimeFrame executor.TimeFrame `json:"timeframe"`
+}
+
+func (t TrailSettings) validate() error {
+	if t.ActivatePercent < 0 {
+		return errors.New("trail activate_percent cannot be negative")
+	}
+	if t.TimeFrame != "" && t.TimeFrame.Duration() == 0 {
+		return fmt.Errorf("trail timeframe %v is not supported", t.TimeFrame)
+	}
+	switch t.Mode {
+	case "", MinTrail, BreakEvenTrail:
+	case StepTrail:
+		if t.Step <= 0 {
+			return errors.New("step trail needs step > 0")
+		}
+	case PercentTrail:
+		if t.Percent <= 0 {
+			return errors.New("percent trail needs percent > 0")
+		}
+	case ATRTrail:
+		if t.ATRPeriod <= 0 || t.ATRMultiplier <= 0 {
+			return errors.New("atr trail needs atr_period and atr_multiplier > 0")
+		}
+	case CandleTrail:
+		if t.CandleLookback < 0 {
+			return errors.New("candle trail candle_lookback cannot be negative")
+		}
+	default:
+		return fmt.Errorf("unknown trail mode %q", t.Mode)
+	}
+	return nil
+}
+
+func (t TrailSettings) isMinTrail() bool {
+	return t.Mode == "" || t.Mode == MinTrail
+}
+
+func (t TrailSettings) timeFrame() executor.TimeFrame {
+	if t.TimeFrame == "" {
+		return executor.Minute5
+	}
+	return t.TimeFrame
+}
+
+// UpdateSL trails the stop loss behind tickPrice and reports whether it
+// moved. The best price and whether trailing has started are kept in the
+// trade, so the stop carries on from where it was after a restart.
+func (obj *ATMcs) UpdateSL(tickPrice float64) bool {
+	settings := obj.Settings.Trail
+	if settings.isMinTrail() {
+		return obj.IsUpdateMinTrail(tickPrice)
+	}
+	buy := obj.Trade.TradeType == executor.Buy
+	if obj.Trade.TradeType != executor.Buy && obj.Trade.TradeType != executor.Sell {
+		return false
+	}
+	if obj.Trade.BestPrice == 0 || (buy && tickPrice > obj.Trade.BestPrice) || (!buy && tickPrice < obj.Trade.BestPrice) {
+		obj.Trade.BestPrice = tickPrice
+	}
+
+	if !obj.Trade.IsTrailActive {
+		if favourableMove(obj.Trade.EntryPrice, obj.Trade.BestPrice, buy) < settings.ActivatePercent {
+			return false
+		}
+		obj.Trade.IsTrailActive = true
+	}
+
+	stopLoss, ok, err := obj.trailStopLoss(settings, buy)
+	if err != nil {
+		obj.addError(fmt.Errorf("UpdateSL(): %w", err))
+		return false
+	}
+	if !ok {
+		return false
+	}
+	if (buy && stopLoss <= obj.Trade.StopLossPrice) || (!buy && stopLoss >= obj.Trade.StopLossPrice) {
+		return false
+	}
+	obj.Trade.StopLossPrice = stopLoss
+	return true
+}
+
+// favourableMove is how far best has moved from entry in the trade's
+// favour, in percent.
+func favourableMove(entry, best float64, buy bool) float64 {
+	if entry == 0 {
+		return 0
+	}
+	if buy {
+		return (best - entry) / entry * 100
+	}
+	return (entry - best) / entry * 100
+}
+
+// trailStopLoss is where the mode would put the stop now. ok is false when
+// the mode has nothing to say yet, such as before enough candles.
+func (obj *ATMcs) trailStopLoss(settings TrailSettings, buy bool) (float64, bool, error) {
+	entry, best := obj.Trade.EntryPrice, obj.Trade.BestPrice
+	direction := 1.0
+	if !buy {
+		direction = -1
+	}
+	switch settings.Mode {
+	case BreakEvenTrail:
+		stopLoss := entry + direction*settings.Offset
+		if direction*(best-stopLoss) <= 0 {
+			return 0, false, nil
+		}
+		return stopLoss, true, nil
+	case StepTrail:
+		steps := math.Floor(direction * (best - entry) / settings.Step)
+		if steps < 1 {
+			return 0, false, nil
+		}
+		return obj.Trade.InitialStopLossPrice + direction*steps*settings.Step, true, nil
+	case PercentTrail:
+		return best * (1 - direction*settings.Percent/100), true, nil
+	case ATRTrail:
+		candles, err := obj.GetCurrentDayCandles(obj.GetCurrentTime(), settings.timeFrame())
+		if err != nil {
+			return 0, false, err
+		}
+		atr, ok := averageTrueRange(candles, settings.ATRPeriod)
+		if !ok {
+			return 0, false, nil
+		}
+		return best - direction*settings.ATRMultiplier*atr, true, nil
+	case CandleTrail:
+		candles, err := obj.GetCurrentDayCandles(obj.GetCurrentTime(), settings.timeFrame())
+		if err != nil {
+			return 0, false, err
+		}
+		lookback := settings.CandleLookback
+		if lookback == 0 {
+			lookback = 1
+		}
+		if len(candles) < lookback {
+			return 0, false, nil
+		}
+		recent := candles[len(candles)-lookback:]
+		stopLoss := recent[0].GetLow()
+		if !buy {
+			stopLoss = recent[0].GetHigh()
+		}
+		for _, candle := range recent[1:] {
+			if buy {
+				stopLoss = math.Min(stopLoss, candle.GetLow())
+			} else {
+				stopLoss = math.Max(stopLoss, candle.GetHigh())
+			}
+		}
+		return stopLoss, true, nil
+	}
+	return 0, false, nil
+}
+
+// averageTrueRange is the simple average of the true range of the last
+// period candles.
+func averageTrueRange(candles []executor.CandleLike, period int) (float64, bool) {
+	if len(candles) < period {
+		return 0, false
+	}
+	total := 0.0
+	for i := len(candles) - period; i < len(candles); i++ {
+		high, low := candles[i].GetHigh(), candles[i].GetLow()
+		trueRange := high - low
+		if i > 0 {
+			previousClose := candles[i-1].GetClose()
+			trueRange = math.Max(trueRange, math.Max(math.Abs(high-previousClose), math.Abs(low-previousClose)))
+		}
+		total += trueRange
+	}
+	return total / float64(period), true
+}
+
+// GetCurrentDayCandles returns the day's completed tf candles up to
+// currentTime, built from ticks when the signal is.
+func (obj *ATMcs) GetCurrentDayCandles(currentTime time.Time, tf executor.TimeFrame) ([]executor.CandleLike, error) {
+	from, _ := obj.sessionBounds(currentTime)
+	if obj.Settings.SignalSettings.TickCandles {
+		return obj.tickCandleBuilder().GetCandles(obj.Symbol, from, currentTime, tf)
+	}
+	return obj.candleStore().GetCandles(obj.Symbol, from, currentTime, tf)
+}
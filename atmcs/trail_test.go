@@ -0,0 +1,164 @@
+package atmcs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dragonzurfer/trader/executor"
+	"github.com/dragonzurfer/trader/executor/replay"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTrailATMcs is in a trade entered at 100 with its stop at stopLoss.
+func newTrailATMcs(t *testing.T, trail map[string]interface{}, tradeType executor.TradeType, stopLoss float64) *ATMcs {
+	t.Helper()
+	now := istTime(t, "2023-05-16T09:30:00+05:30")
+	atm := newTestATMcs(t, map[string]interface{}{"trail": trail}, func() time.Time { return now })
+	atm.SetBroker(signalTestBroker(atm.ISTLocation))
+	atm.Trade.InTrade = true
+	atm.Trade.TradeType = tradeType
+	atm.Trade.EntryPrice = 100
+	atm.Trade.StopLossPrice = stopLoss
+	atm.Trade.InitialStopLossPrice = stopLoss
+	atm.Trade.TargetPrice = 100 + 2*(100-stopLoss)
+	return atm
+}
+
+func TestStepTrail(t *testing.T) {
+	atm := newTrailATMcs(t, map[string]interface{}{"mode": "step", "step": 5}, executor.Buy, 90)
+
+	assert.False(t, atm.UpdateSL(104))
+	assert.True(t, atm.UpdateSL(105))
+	assert.Equal(t, 95.0, atm.Trade.StopLossPrice)
+	assert.True(t, atm.UpdateSL(112))
+	assert.Equal(t, 100.0, atm.Trade.StopLossPrice)
+	assert.False(t, atm.UpdateSL(108))
+	assert.Equal(t, 100.0, atm.Trade.StopLossPrice)
+	assert.Equal(t, 112.0, atm.Trade.BestPrice)
+}
+
+func TestPercentTrailActivatesAndRatchets(t *testing.T) {
+	atm := newTrailATMcs(t, map[string]interface{}{
+		"mode":             "percent",
+		"percent":          2,
+		"activate_percent": 1,
+	}, executor.Sell, 110)
+
+	assert.False(t, atm.UpdateSL(99.5))
+	assert.False(t, atm.Trade.IsTrailActive)
+	assert.True(t, atm.UpdateSL(98))
+	assert.True(t, atm.Trade.IsTrailActive)
+	assert.InDelta(t, 99.96, atm.Trade.StopLossPrice, 1e-9)
+	assert.False(t, atm.UpdateSL(99))
+	assert.InDelta(t, 99.96, atm.Trade.StopLossPrice, 1e-9)
+}
+
+func TestATRTrail(t *testing.T) {
+	atm := newTrailATMcs(t, map[string]interface{}{
+		"mode":           "atr",
+		"atr_period":     2,
+		"atr_multiplier": 1.5,
+	}, executor.Sell, 18400)
+	atm.Trade.EntryPrice = 18310
+
+	// true ranges of the last two candles are 20 and 35
+	assert.True(t, atm.UpdateSL(18280))
+	assert.InDelta(t, 18280+1.5*27.5, atm.Trade.StopLossPrice, 1e-9)
+}
+
+func TestCandleTrail(t *testing.T) {
+	atm := newTrailATMcs(t, map[string]interface{}{"mode": "candle", "candle_lookback": 2}, executor.Buy, 18200)
+	atm.Trade.EntryPrice = 18250
+
+	assert.True(t, atm.UpdateSL(18300))
+	assert.Equal(t, 18270.0, atm.Trade.StopLossPrice)
+
+	// too few candles for the lookback leaves the stop alone
+	atm.Settings.Trail.CandleLookback = 4
+	atm.Trade.StopLossPrice = 18200
+	assert.False(t, atm.UpdateSL(18300))
+}
+
+func TestBreakEvenTrail(t *testing.T) {
+	atm := newTrailATMcs(t, map[string]interface{}{
+		"mode":             "break_even",
+		"offset":           2,
+		"activate_percent": 5,
+	}, executor.Buy, 90)
+
+	assert.False(t, atm.UpdateSL(104))
+	assert.True(t, atm.UpdateSL(105))
+	assert.Equal(t, 102.0, atm.Trade.StopLossPrice)
+	assert.False(t, atm.UpdateSL(120))
+}
+
+func TestBreakEvenTrailWaitsForOffset(t *testing.T) {
+	atm := newTrailATMcs(t, map[string]interface{}{
+		"mode":   "break_even",
+		"offset": 2,
+	}, executor.Sell, 110)
+
+	// between entry and entry less the offset the stop would sit below
+	// the market and stop the trade out at a loss
+	assert.False(t, atm.UpdateSL(99))
+	assert.Equal(t, 110.0, atm.Trade.StopLossPrice)
+	assert.True(t, atm.UpdateSL(97))
+	assert.Equal(t, 98.0, atm.Trade.StopLossPrice)
+}
+
+func TestTrailSurvivesRestart(t *testing.T) {
+	trail := map[string]interface{}{"mode": "percent", "percent": 10}
+	atm := newTrailATMcs(t, trail, executor.Buy, 90)
+
+	atm.ExitOnTick(118)
+	select {
+	case event := <-atm.GetEventChan():
+		assert.False(t, event.IsExit())
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for the trail event")
+	}
+	assert.InDelta(t, 106.2, atm.Trade.StopLossPrice, 1e-9)
+	assert.Nil(t, atm.LogTrade())
+
+	restarted := newTrailATMcs(t, trail, executor.Buy, 90)
+	restarted.Settings.TradeFilePath = atm.Settings.TradeFilePath
+	assert.Nil(t, restarted.LoadFromJSON())
+	assert.Equal(t, 118.0, restarted.Trade.BestPrice)
+
+	// a lower price after the restart does not loosen the stop
+	assert.False(t, restarted.UpdateSL(115))
+	assert.InDelta(t, 106.2, restarted.Trade.StopLossPrice, 1e-9)
+	restarted.ExitOnTick(106)
+	assert.False(t, restarted.InTrade())
+}
+
+func TestTrailSettingsValidation(t *testing.T) {
+	invalid := []TrailSettings{
+		{Mode: "chandelier"},
+		{Mode: StepTrail},
+		{Mode: PercentTrail, Percent: -1},
+		{Mode: ATRTrail, ATRPeriod: 14},
+		{Mode: CandleTrail, CandleLookback: -1},
+		{Mode: BreakEvenTrail, ActivatePercent: -1},
+		{Mode: CandleTrail, TimeFrame: executor.Month},
+	}
+	for _, settings := range invalid {
+		assert.NotNil(t, settings.validate(), "%+v", settings)
+	}
+	assert.Nil(t, TrailSettings{}.validate())
+	assert.Nil(t, TrailSettings{Mode: ATRTrail, ATRPeriod: 14, ATRMultiplier: 2}.validate())
+}
+
+func TestAverageTrueRange(t *testing.T) {
+	candles := []executor.CandleLike{
+		replay.Candle{High: 110, Low: 100, Close: 105},
+		replay.Candle{High: 108, Low: 104, Close: 106},
+		replay.Candle{High: 115, Low: 107, Close: 114},
+	}
+	atr, ok := averageTrueRange(candles, 2)
+	assert.True(t, ok)
+	// true ranges of 4 and 9, the last measured from the previous close
+	assert.Equal(t, 6.5, atr)
+	_, ok = averageTrueRange(candles, 4)
+	assert.False(t, ok)
+}
@@ -24,6 +24,13 @@ type CandleLike interface {
 	GetOI() float64
 }
 
+// TimedCandleLike is implemented by candles that know when they opened.
+// Brokers should return them where they can, so responses can be recorded.
+type TimedCandleLike interface {
+	CandleLike
+	GetTime() time.Time
+}
+
 type OptionType string
 
 const (
@@ -56,6 +63,30 @@ const (
 	Year     TimeFrame = "1year"
 )
 
+// Duration is the length of a candle of the time frame. Month and year
+// frames have no fixed length and return 0.
+func (tf TimeFrame) Duration() time.Duration {
+	switch tf {
+	case Minute:
+		return time.Minute
+	case Minute5:
+		return 5 * time.Minute
+	case Minute15:
+		return 15 * time.Minute
+	case Minute30:
+		return 30 * time.Minute
+	case Minute45:
+		return 45 * time.Minute
+	case Hour:
+		return time.Hour
+	case Hour4:
+		return 4 * time.Hour
+	case Day:
+		return 24 * time.Hour
+	}
+	return 0
+}
+
 type OptionLike interface {
 	GetExpiry() time.Time
 	GetStrike() float64
@@ -83,4 +114,8 @@ type BrokerLike interface {
 	GetOptionExpiries(string) ([]Expiry, error)
 	GetMarketDepthOption(float64, time.Time, OptionType) (BidAskLike, error)
 	GetCandlesOption(float64, time.Time, OptionType, time.Time, time.Time) ([]CandleLike, error)
+	PlaceOrder(OrderRequest) (string, error)
+	ModifyOrder(string, OrderRequest) error
+	CancelOrder(string) error
+	GetOrderStatus(string) (OrderUpdate, error)
 }
@@ -0,0 +1,220 @@
+// Package calendar knows when an exchange trades: the sessions of a regular
+// day, the weekend, holidays, and special days such as half days or the
+// Muhurat session on Diwali.
+package calendar
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+// Session is one trading session of a day. Open and Close are offsets from
+// midnight in the exchange time zone.
+type Session struct {
+	Name  string
+	Open  time.Duration
+	Close time.Duration
+}
+
+type Calendar struct {
+	Name     string
+	Location *time.Location
+	// Sessions are the sessions of a regular trading day, in order.
+	Sessions []Session
+	Weekend  []time.Weekday
+	// Holidays are the dates, as 2006-01-02, the exchange is closed.
+	Holidays map[string]bool
+	// Special replaces the sessions of a date, over Weekend and Holidays.
+	// A date with no sessions is closed.
+	Special map[string][]Session
+}
+
+// NSE is the 9:15 to 15:30 equity and F&O session of the National Stock
+// Exchange, without holidays.
+func NSE(location *time.Location) *Calendar {
+	return &Calendar{
+		Name:     "NSE",
+		Location: location,
+		Sessions: []Session{{Name: "regular", Open: clock(9, 15), Close: clock(15, 30)}},
+		Weekend:  []time.Weekday{time.Saturday, time.Sunday},
+		Holidays: make(map[string]bool),
+		Special:  make(map[string][]Session),
+	}
+}
+
+// MCX is the day and evening session of the Multi Commodity Exchange,
+// without holidays.
+func MCX(location *time.Location) *Calendar {
+	return &Calendar{
+		Name:     "MCX",
+		Location: location,
+		Sessions: []Session{
+			{Name: "day", Open: clock(9, 0), Close: clock(17, 0)},
+			{Name: "evening", Open: clock(17, 0), Close: clock(23, 30)},
+		},
+		Weekend:  []time.Weekday{time.Saturday, time.Sunday},
+		Holidays: make(map[string]bool),
+		Special:  make(map[string][]Session),
+	}
+}
+
+func clock(hour, minute int) time.Duration {
+	return time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute
+}
+
+// AddHolidays closes the given 2006-01-02 dates.
+func (c *Calendar) AddHolidays(dates ...string) error {
+	if c.Holidays == nil {
+		c.Holidays = make(map[string]bool)
+	}
+	for _, date := range dates {
+		if _, err := time.Parse(dateLayout, date); err != nil {
+			return fmt.Errorf("holiday %q is not YYYY-MM-DD", date)
+		}
+		c.Holidays[date] = true
+	}
+	return nil
+}
+
+// SetSpecial replaces the sessions of the 2006-01-02 date.
+func (c *Calendar) SetSpecial(date string, sessions ...Session) error {
+	if _, err := time.Parse(dateLayout, date); err != nil {
+		return fmt.Errorf("special day %q is not YYYY-MM-DD", date)
+	}
+	if err := validateSessions(sessions); err != nil {
+		return fmt.Errorf("special day %v: %w", date, err)
+	}
+	if c.Special == nil {
+		c.Special = make(map[string][]Session)
+	}
+	c.Special[date] = sessions
+	return nil
+}
+
+// Validate checks that the calendar has a regular trading day.
+func (c *Calendar) Validate() error {
+	if len(c.Sessions) == 0 {
+		return errors.New("no sessions")
+	}
+	if err := validateSessions(c.Sessions); err != nil {
+		return err
+	}
+	weekend := make(map[time.Weekday]bool)
+	for _, day := range c.Weekend {
+		weekend[day] = true
+	}
+	if len(weekend) >= 7 {
+		return errors.New("every day is a weekend")
+	}
+	return nil
+}
+
+func validateSessions(sessions []Session) error {
+	for i, session := range sessions {
+		if session.Open < 0 || session.Close > 24*time.Hour || session.Open >= session.Close {
+			return fmt.Errorf("session %q must open before it closes, within the day", session.Name)
+		}
+		if i > 0 && session.Open < sessions[i-1].Close {
+			return fmt.Errorf("session %q overlaps the one before it", session.Name)
+		}
+	}
+	return nil
+}
+
+// Midnight is the start of the exchange day t falls on.
+func (c *Calendar) Midnight(t time.Time) time.Time {
+	t = t.In(c.location(t))
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// At is offset past midnight of the exchange day t falls on.
+func (c *Calendar) At(t time.Time, offset time.Duration) time.Time {
+	return c.Midnight(t).Add(offset)
+}
+
+func (c *Calendar) location(t time.Time) *time.Location {
+	if c.Location == nil {
+		return t.Location()
+	}
+	return c.Location
+}
+
+// SessionsOn returns the sessions of the exchange day t falls on, none when
+// the exchange is closed.
+func (c *Calendar) SessionsOn(t time.Time) []Session {
+	day := c.Midnight(t)
+	date := day.Format(dateLayout)
+	if sessions, ok := c.Special[date]; ok {
+		return sessions
+	}
+	if c.Holidays[date] {
+		return nil
+	}
+	for _, weekday := range c.Weekend {
+		if day.Weekday() == weekday {
+			return nil
+		}
+	}
+	return c.Sessions
+}
+
+// IsTradingDay reports whether the exchange has a session on the day t
+// falls on.
+func (c *Calendar) IsTradingDay(t time.Time) bool {
+	return len(c.SessionsOn(t)) > 0
+}
+
+// SessionAt returns the session open at t.
+func (c *Calendar) SessionAt(t time.Time) (Session, bool) {
+	for _, session := range c.SessionsOn(t) {
+		if !t.Before(c.At(t, session.Open)) && t.Before(c.At(t, session.Close)) {
+			return session, true
+		}
+	}
+	return Session{}, false
+}
+
+// IsOpen reports whether a session is open at t.
+func (c *Calendar) IsOpen(t time.Time) bool {
+	_, ok := c.SessionAt(t)
+	return ok
+}
+
+// PreviousTradingDay is t moved back by whole days to the last trading day
+// before it.
+func (c *Calendar) PreviousTradingDay(t time.Time) time.Time {
+	previous := t.AddDate(0, 0, -1)
+	for !c.IsTradingDay(previous) {
+		previous = previous.AddDate(0, 0, -1)
+	}
+	return previous
+}
+
+// NextTradingDay is t moved forward by whole days to the first trading day
+// after it.
+func (c *Calendar) NextTradingDay(t time.Time) time.Time {
+	next := t.AddDate(0, 0, 1)
+	for !c.IsTradingDay(next) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// TradingDaysBetween counts the trading days after the day of from up to
+// and including the day of to, negative when to is before from.
+func (c *Calendar) TradingDaysBetween(from, to time.Time) int {
+	start, end, sign := c.Midnight(from), c.Midnight(to), 1
+	if end.Before(start) {
+		start, end, sign = end, start, -1
+	}
+	days := 0
+	for day := start.AddDate(0, 0, 1); !day.After(end); day = day.AddDate(0, 0, 1) {
+		if c.IsTradingDay(day) {
+			days++
+		}
+	}
+	return sign * days
+}
@@ -0,0 +1,195 @@
+package calendar
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func ist(t *testing.T) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Fatalf("LoadLocation() failed: %v", err)
+	}
+	return loc
+}
+
+func TestNSEDays(t *testing.T) {
+	loc := ist(t)
+	nse := NSE(loc)
+	if err := nse.AddHolidays("2023-06-28"); err != nil {
+		t.Fatalf("AddHolidays() failed: %v", err)
+	}
+
+	tuesday := time.Date(2023, 6, 27, 10, 0, 0, 0, loc)
+	if !nse.IsTradingDay(tuesday) || !nse.IsOpen(tuesday) {
+		t.Fatalf("expected %v to be open", tuesday)
+	}
+	if nse.IsOpen(time.Date(2023, 6, 27, 15, 30, 0, 0, loc)) {
+		t.Fatal("expected the close to be outside the session")
+	}
+	if nse.IsTradingDay(time.Date(2023, 6, 28, 10, 0, 0, 0, loc)) {
+		t.Fatal("expected the holiday to be closed")
+	}
+	if nse.IsTradingDay(time.Date(2023, 7, 1, 10, 0, 0, 0, loc)) {
+		t.Fatal("expected Saturday to be closed")
+	}
+
+	// dates are of the exchange time zone: this is Thursday the 29th in IST
+	if !nse.IsTradingDay(time.Date(2023, 6, 28, 20, 0, 0, 0, time.UTC)) {
+		t.Fatal("expected the UTC evening of the holiday to be the next day in IST")
+	}
+
+	friday := time.Date(2023, 6, 30, 11, 30, 0, 0, loc)
+	if previous := nse.PreviousTradingDay(friday); !previous.Equal(time.Date(2023, 6, 29, 11, 30, 0, 0, loc)) {
+		t.Fatalf("expected Thursday, got %v", previous)
+	}
+	if previous := nse.PreviousTradingDay(time.Date(2023, 6, 29, 9, 15, 0, 0, loc)); previous.Day() != 27 {
+		t.Fatalf("expected the holiday to be skipped, got %v", previous)
+	}
+	if next := nse.NextTradingDay(friday); next.Day() != 3 || next.Month() != time.July {
+		t.Fatalf("expected Monday the 3rd, got %v", next)
+	}
+}
+
+func TestSpecialDays(t *testing.T) {
+	loc := ist(t)
+	mcx := MCX(loc)
+	muhurat := Session{Name: "muhurat", Open: clock(18, 15), Close: clock(19, 15)}
+	if err := mcx.SetSpecial("2023-11-12", muhurat); err != nil {
+		t.Fatalf("SetSpecial() failed: %v", err)
+	}
+	if err := mcx.SetSpecial("2023-11-14", Session{Name: "evening", Open: clock(17, 0), Close: clock(23, 30)}); err != nil {
+		t.Fatalf("SetSpecial() failed: %v", err)
+	}
+
+	if session, ok := mcx.SessionAt(time.Date(2023, 11, 12, 18, 30, 0, 0, loc)); !ok || session.Name != "muhurat" {
+		t.Fatalf("expected the Sunday Muhurat session, got %+v %v", session, ok)
+	}
+	if mcx.IsOpen(time.Date(2023, 11, 14, 10, 0, 0, 0, loc)) {
+		t.Fatal("expected the morning of the evening-only day to be closed")
+	}
+	if session, ok := mcx.SessionAt(time.Date(2023, 11, 13, 21, 0, 0, 0, loc)); !ok || session.Name != "evening" {
+		t.Fatalf("expected the regular evening session, got %+v %v", session, ok)
+	}
+
+	if err := mcx.SetSpecial("2023-11-15", Session{Open: clock(15, 0), Close: clock(9, 0)}); err == nil {
+		t.Fatal("expected a session closing before it opens to fail")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	holidaysPath := filepath.Join(dir, "holidays.json")
+	if err := os.WriteFile(holidaysPath, []byte(`{"holiday_dates": ["2023-06-28"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := `{
+		"exchanges": {
+			"NSE_FO": {
+				"sessions": [{"name": "regular", "open": "09:15", "close": "15:30"}],
+				"holidays": ["2023-08-15"],
+				"holidays_file_path": "` + holidaysPath + `",
+				"special_days": [
+					{"date": "2023-11-12", "sessions": [{"name": "muhurat", "open": "18:15", "close": "19:15"}]},
+					{"date": "2023-11-13"}
+				]
+			}
+		}
+	}`
+	path := filepath.Join(dir, "calendar.json")
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	calendars, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	nse, ok := calendars["NSE_FO"]
+	if !ok {
+		t.Fatal("expected NSE_FO to be loaded")
+	}
+	if nse.Location.String() != "Asia/Kolkata" {
+		t.Fatalf("expected the default location, got %v", nse.Location)
+	}
+	closed := []time.Time{
+		time.Date(2023, 6, 28, 10, 0, 0, 0, nse.Location),
+		time.Date(2023, 8, 15, 10, 0, 0, 0, nse.Location),
+		time.Date(2023, 11, 13, 10, 0, 0, 0, nse.Location),
+	}
+	for _, day := range closed {
+		if nse.IsTradingDay(day) {
+			t.Errorf("expected %v to be closed", day)
+		}
+	}
+	if !nse.IsOpen(time.Date(2023, 11, 12, 18, 15, 0, 0, nse.Location)) {
+		t.Error("expected the Muhurat session to be open")
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	invalid := []string{
+		`{}`,
+		`{"exchanges": {"X": {"sessions": []}}}`,
+		`{"exchanges": {"X": {"sessions": [{"open": "9:15am", "close": "15:30"}]}}}`,
+		`{"exchanges": {"X": {"sessions": [{"open": "09:00", "close": "17:00"}, {"open": "16:00", "close": "23:30"}]}}}`,
+		`{"exchanges": {"X": {"sessions": [{"open": "09:15", "close": "15:30"}], "weekend": ["Sun"]}}}`,
+		`{"exchanges": {"X": {"sessions": [{"open": "09:15", "close": "15:30"}], "holidays": ["28-06-2023"]}}}`,
+		`{"exchanges": {"X": {"location": "Mars/Olympus", "sessions": [{"open": "09:15", "close": "15:30"}]}}}`,
+	}
+	for _, config := range invalid {
+		if _, err := Parse([]byte(config)); err == nil {
+			t.Errorf("expected %v to fail", config)
+		}
+	}
+}
+
+func TestTradingDaysBetween(t *testing.T) {
+	loc := ist(t)
+	nse := NSE(loc)
+	if err := nse.AddHolidays("2023-06-28"); err != nil {
+		t.Fatal(err)
+	}
+	friday := time.Date(2023, 6, 23, 14, 0, 0, 0, loc)
+	thursday := time.Date(2023, 6, 29, 15, 30, 0, 0, loc)
+
+	// Monday, Tuesday and Thursday
+	if days := nse.TradingDaysBetween(friday, thursday); days != 3 {
+		t.Fatalf("expected 3 trading days, got %v", days)
+	}
+	if days := nse.TradingDaysBetween(thursday, friday); days != -3 {
+		t.Fatalf("expected -3 trading days, got %v", days)
+	}
+	if days := nse.TradingDaysBetween(thursday, thursday.Add(-time.Hour)); days != 0 {
+		t.Fatalf("expected no trading days within a day, got %v", days)
+	}
+}
+
+func TestExpiries(t *testing.T) {
+	loc := ist(t)
+	nse := NSE(loc)
+	if err := nse.AddHolidays("2023-06-29"); err != nil {
+		t.Fatal(err)
+	}
+	from := time.Date(2023, 6, 12, 10, 0, 0, 0, loc)
+	to := time.Date(2023, 7, 31, 0, 0, 0, 0, loc)
+
+	weekly := nse.Expiries(ExpirySchedule{Weekday: time.Thursday}, from, to)
+	want := []string{"2023-06-15", "2023-06-22", "2023-06-28", "2023-07-06", "2023-07-13", "2023-07-20", "2023-07-27"}
+	if len(weekly) != len(want) {
+		t.Fatalf("expected %v, got %v", want, weekly)
+	}
+	for i, expiry := range weekly {
+		if expiry.Format(dateLayout) != want[i] {
+			t.Errorf("expected %v, got %v", want[i], expiry.Format(dateLayout))
+		}
+	}
+
+	monthly := nse.Expiries(ExpirySchedule{Weekday: time.Thursday, Monthly: true}, from, to)
+	if len(monthly) != 2 || monthly[0].Format(dateLayout) != "2023-06-28" || monthly[1].Format(dateLayout) != "2023-07-27" {
+		t.Fatalf("expected the June expiry rolled back to the 28th and July's, got %v", monthly)
+	}
+}
@@ -0,0 +1,193 @@
+package calendar
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+// Config is a calendar file of exchanges by name, e.g.
+//
+//	{
+//	  "exchanges": {
+//	    "NSE_FO": {
+//	      "location": "Asia/Kolkata",
+//	      "sessions": [{"name": "regular", "open": "09:15", "close": "15:30"}],
+//	      "holidays_file_path": "holidays.json",
+//	      "special_days": [
+//	        {"date": "2023-11-12", "sessions": [{"name": "muhurat", "open": "18:15", "close": "19:15"}]}
+//	      ]
+//	    }
+//	  }
+//	}
+type Config struct {
+	Exchanges map[string]ExchangeConfig `json:"exchanges"`
+}
+
+type ExchangeConfig struct {
+	// Location defaults to Asia/Kolkata.
+	Location string          `json:"location"`
+	Sessions []SessionConfig `json:"sessions"`
+	// Weekend lists weekday names and defaults to Saturday and Sunday.
+	Weekend  []string `json:"weekend"`
+	Holidays []string `json:"holidays"`
+	// HolidaysFilePath adds the holiday_dates of a holidays file.
+	HolidaysFilePath string             `json:"holidays_file_path"`
+	SpecialDays      []SpecialDayConfig `json:"special_days"`
+}
+
+// SessionConfig times are "15:04" in the exchange time zone.
+type SessionConfig struct {
+	Name  string `json:"name"`
+	Open  string `json:"open"`
+	Close string `json:"close"`
+}
+
+// SpecialDayConfig replaces the sessions of Date, such as a half day. A
+// special day without sessions is closed.
+type SpecialDayConfig struct {
+	Date     string          `json:"date"`
+	Sessions []SessionConfig `json:"sessions"`
+}
+
+type holidaysFile struct {
+	HolidayDates []string `json:"holiday_dates"`
+}
+
+// Load reads the calendar file at path.
+func Load(path string) (map[string]*Calendar, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("calendar.Load(): %w", err)
+	}
+	calendars, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("calendar.Load(): %v: %w", path, err)
+	}
+	return calendars, nil
+}
+
+// Parse builds the calendars of a calendar file.
+func Parse(data []byte) (map[string]*Calendar, error) {
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+	if len(config.Exchanges) == 0 {
+		return nil, errors.New("no exchanges")
+	}
+	calendars := make(map[string]*Calendar, len(config.Exchanges))
+	for name, exchange := range config.Exchanges {
+		calendar, err := exchange.Calendar(name)
+		if err != nil {
+			return nil, err
+		}
+		calendars[name] = calendar
+	}
+	return calendars, nil
+}
+
+// Calendar builds the calendar of the exchange called name.
+func (e ExchangeConfig) Calendar(name string) (*Calendar, error) {
+	locationName := e.Location
+	if locationName == "" {
+		locationName = "Asia/Kolkata"
+	}
+	location, err := time.LoadLocation(locationName)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", name, err)
+	}
+	calendar := &Calendar{
+		Name:     name,
+		Location: location,
+		Weekend:  []time.Weekday{time.Saturday, time.Sunday},
+		Holidays: make(map[string]bool),
+		Special:  make(map[string][]Session),
+	}
+	if calendar.Sessions, err = parseSessions(e.Sessions); err != nil {
+		return nil, fmt.Errorf("%v: %w", name, err)
+	}
+	if e.Weekend != nil {
+		if calendar.Weekend, err = parseWeekdays(e.Weekend); err != nil {
+			return nil, fmt.Errorf("%v: %w", name, err)
+		}
+	}
+	if err := calendar.AddHolidays(e.Holidays...); err != nil {
+		return nil, fmt.Errorf("%v: %w", name, err)
+	}
+	if e.HolidaysFilePath != "" {
+		data, err := ioutil.ReadFile(e.HolidaysFilePath)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", name, err)
+		}
+		var holidays holidaysFile
+		if err := json.Unmarshal(data, &holidays); err != nil {
+			return nil, fmt.Errorf("%v: %v: %w", name, e.HolidaysFilePath, err)
+		}
+		if err := calendar.AddHolidays(holidays.HolidayDates...); err != nil {
+			return nil, fmt.Errorf("%v: %w", name, err)
+		}
+	}
+	for _, day := range e.SpecialDays {
+		sessions, err := parseSessions(day.Sessions)
+		if err != nil {
+			return nil, fmt.Errorf("%v: special day %v: %w", name, day.Date, err)
+		}
+		if err := calendar.SetSpecial(day.Date, sessions...); err != nil {
+			return nil, fmt.Errorf("%v: %w", name, err)
+		}
+	}
+	if err := calendar.Validate(); err != nil {
+		return nil, fmt.Errorf("%v: %w", name, err)
+	}
+	return calendar, nil
+}
+
+func parseSessions(configs []SessionConfig) ([]Session, error) {
+	sessions := make([]Session, len(configs))
+	for i, config := range configs {
+		open, err := ParseTimeOfDay(config.Open)
+		if err != nil {
+			return nil, fmt.Errorf("session %q open: %w", config.Name, err)
+		}
+		closing, err := ParseTimeOfDay(config.Close)
+		if err != nil {
+			return nil, fmt.Errorf("session %q close: %w", config.Name, err)
+		}
+		sessions[i] = Session{Name: config.Name, Open: open, Close: closing}
+	}
+	return sessions, nil
+}
+
+func parseWeekdays(names []string) ([]time.Weekday, error) {
+	weekdays := make([]time.Weekday, 0, len(names))
+	for _, name := range names {
+		found := false
+		for day := time.Sunday; day <= time.Saturday; day++ {
+			if day.String() == name {
+				weekdays = append(weekdays, day)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("weekend day %q is not a weekday name", name)
+		}
+	}
+	return weekdays, nil
+}
+
+// ParseTimeOfDay returns "15:04" as the time since midnight. "24:00" is
+// the end of the day.
+func ParseTimeOfDay(value string) (time.Duration, error) {
+	if value == "24:00" {
+		return 24 * time.Hour, nil
+	}
+	parsed, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("time of day %q is not HH:MM", value)
+	}
+	return time.Duration(parsed.Hour())*time.Hour + time.Duration(parsed.Minute())*time.Minute, nil
+}
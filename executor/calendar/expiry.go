@@ -0,0 +1,37 @@
+package calendar
+
+import "time"
+
+// ExpirySchedule is the rule an exchange lists option expiries by: every
+// Weekday, or the last Weekday of each month when Monthly. An expiry that
+// falls on a closed day moves back to the trading day before it.
+type ExpirySchedule struct {
+	Weekday time.Weekday
+	Monthly bool
+}
+
+// Expiries returns the expiry days, at midnight, between the days of from
+// and to inclusive.
+func (c *Calendar) Expiries(schedule ExpirySchedule, from, to time.Time) []time.Time {
+	start, end := c.Midnight(from), c.Midnight(to)
+	var expiries []time.Time
+	// an expiry rolled back can fall before its nominal day, so the days
+	// just after the range are looked at too
+	for day := start; !day.After(end.AddDate(0, 0, 7)); day = day.AddDate(0, 0, 1) {
+		if day.Weekday() != schedule.Weekday {
+			continue
+		}
+		if schedule.Monthly && day.AddDate(0, 0, 7).Month() == day.Month() {
+			continue
+		}
+		expiry := day
+		if !c.IsTradingDay(expiry) {
+			expiry = c.PreviousTradingDay(expiry)
+		}
+		if expiry.Before(start) || expiry.After(end) {
+			continue
+		}
+		expiries = append(expiries, expiry)
+	}
+	return expiries
+}
@@ -0,0 +1,130 @@
+package candles
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dragonzurfer/trader/executor"
+)
+
+// Builder builds candles of each of TimeFrames from ticks, aligned to the
+// session, for brokers that only stream prices. Ticks outside the session
+// or older than the last one added for the symbol are dropped, and the
+// candle still forming can be read before it completes.
+type Builder struct {
+	Session
+	TimeFrames []executor.TimeFrame
+
+	mu       sync.Mutex
+	built    map[seriesKey]*builtSeries
+	lastTick map[string]time.Time
+}
+
+type builtSeries struct {
+	candles []Candle
+	forming *Candle
+}
+
+func NewBuilder(session Session, timeFrames ...executor.TimeFrame) *Builder {
+	return &Builder{
+		Session:    session,
+		TimeFrames: timeFrames,
+		built:      make(map[seriesKey]*builtSeries),
+		lastTick:   make(map[string]time.Time),
+	}
+}
+
+// AddTick adds a trade of volume at price, 0 when the volume is unknown.
+func (b *Builder) AddTick(symbol string, t time.Time, price, volume float64) {
+	if !b.InSession(t) {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if t.Before(b.lastTick[symbol]) {
+		return
+	}
+	b.lastTick[symbol] = t
+	for _, tf := range b.TimeFrames {
+		if tf.Duration() == 0 {
+			continue
+		}
+		key := seriesKey{symbol: symbol, tf: tf}
+		built, ok := b.built[key]
+		if !ok {
+			built = &builtSeries{}
+			b.built[key] = built
+		}
+		built.add(b.bucketStart(t, tf), price, volume)
+	}
+}
+
+func (s *builtSeries) add(start time.Time, price, volume float64) {
+	if s.forming != nil {
+		if start.Equal(s.forming.Time) {
+			if price > s.forming.High {
+				s.forming.High = price
+			}
+			if price < s.forming.Low {
+				s.forming.Low = price
+			}
+			s.forming.Close = price
+			s.forming.Volume += volume
+			return
+		}
+		s.candles = append(s.candles, *s.forming)
+	}
+	s.forming = &Candle{Time: start, Open: price, High: price, Low: price, Close: price, Volume: volume}
+}
+
+// GetCandles returns the candles built for symbol opened at or after from
+// that had completed by to. A candle completes at its end even when no
+// later tick has arrived.
+func (b *Builder) GetCandles(symbol string, from, to time.Time, tf executor.TimeFrame) ([]executor.CandleLike, error) {
+	if !b.isBuilt(tf) {
+		return nil, fmt.Errorf("GetCandles(): %v candles are not built", tf)
+	}
+	if tf == executor.Day {
+		from = b.bucketStart(from, tf)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	built, ok := b.built[seriesKey{symbol: symbol, tf: tf}]
+	if !ok {
+		return nil, nil
+	}
+	candles := built.candles
+	if built.forming != nil {
+		candles = append(candles[:len(candles):len(candles)], *built.forming)
+	}
+	var completed []Candle
+	for _, candle := range candles {
+		if candle.Time.Before(from) || b.candleEnd(candle.Time, tf).After(to) {
+			continue
+		}
+		completed = append(completed, candle)
+	}
+	return toCandleLike(completed), nil
+}
+
+// Forming returns the last candle of symbol ticks were added to, which is
+// incomplete until its end.
+func (b *Builder) Forming(symbol string, tf executor.TimeFrame) (Candle, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	built, ok := b.built[seriesKey{symbol: symbol, tf: tf}]
+	if !ok || built.forming == nil {
+		return Candle{}, false
+	}
+	return *built.forming, true
+}
+
+func (b *Builder) isBuilt(tf executor.TimeFrame) bool {
+	for _, built := range b.TimeFrames {
+		if built == tf {
+			return tf.Duration() != 0
+		}
+	}
+	return false
+}
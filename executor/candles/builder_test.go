@@ -0,0 +1,77 @@
+package candles
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dragonzurfer/trader/executor"
+)
+
+func TestBuilderBuildsAlignedCandles(t *testing.T) {
+	builder := NewBuilder(NSESession(ist), executor.Minute5, executor.Minute15, executor.Day)
+	ticks := []struct {
+		at     time.Time
+		price  float64
+		volume float64
+	}{
+		{at(9, 10), 90, 1}, // pre-open, dropped
+		{at(9, 15), 100, 1},
+		{at(9, 16).Add(20 * time.Second), 104, 2},
+		{at(9, 19).Add(59 * time.Second), 98, 1},
+		{at(9, 21), 101, 3},
+		{at(9, 19).Add(30 * time.Second), 150, 1}, // out of order, dropped
+		{at(9, 31), 107, 1},
+	}
+	for _, tick := range ticks {
+		builder.AddTick("NIFTY", tick.at, tick.price, tick.volume)
+	}
+
+	candles, err := builder.GetCandles("NIFTY", at(9, 15), at(9, 31), executor.Minute5)
+	if err != nil {
+		t.Fatalf("GetCandles() failed: %v", err)
+	}
+	// 9:25 had no ticks, so there is no candle for it
+	if len(candles) != 2 {
+		t.Fatalf("got %v 5min candles, want 2", len(candles))
+	}
+	first := candles[0].(Candle)
+	want := Candle{Time: at(9, 15), Open: 100, High: 104, Low: 98, Close: 98, Volume: 4}
+	if first != want {
+		t.Errorf("first candle = %+v, want %+v", first, want)
+	}
+	if second := candles[1].(Candle); !second.Time.Equal(at(9, 20)) || second.Close != 101 || second.High != 101 {
+		t.Errorf("second candle = %+v", second)
+	}
+
+	candles, _ = builder.GetCandles("NIFTY", at(9, 15), at(9, 31), executor.Minute15)
+	if len(candles) != 1 || candles[0].GetHigh() != 104 || candles[0].GetClose() != 101 {
+		t.Errorf("15min candles = %+v", candles)
+	}
+	if forming, ok := builder.Forming("NIFTY", executor.Minute15); !ok || !forming.Time.Equal(at(9, 30)) {
+		t.Errorf("forming 15min candle = %+v, %v", forming, ok)
+	}
+}
+
+func TestBuilderCompletesCandlesWithoutTicks(t *testing.T) {
+	builder := NewBuilder(NSESession(ist), executor.Minute5, executor.Day)
+	builder.AddTick("NIFTY", at(15, 27), 100, 0)
+	builder.AddTick("NIFTY", at(15, 29), 102, 0)
+
+	candles, _ := builder.GetCandles("NIFTY", at(9, 15), at(15, 29), executor.Minute5)
+	if len(candles) != 0 {
+		t.Errorf("got %v candles before the close, want 0", len(candles))
+	}
+	// the last candle of the day is cut short at the close
+	candles, _ = builder.GetCandles("NIFTY", at(9, 15), at(15, 30), executor.Minute5)
+	if len(candles) != 1 || candles[0].GetClose() != 102 {
+		t.Errorf("got %+v at the close, want the 15:25 candle", candles)
+	}
+	candles, _ = builder.GetCandles("NIFTY", at(9, 15), at(15, 30), executor.Day)
+	if len(candles) != 1 || candles[0].GetOpen() != 100 {
+		t.Errorf("got %+v daily candles, want 1", candles)
+	}
+
+	if _, err := builder.GetCandles("NIFTY", at(9, 15), at(15, 30), executor.Hour); err == nil {
+		t.Errorf("GetCandles() served a time frame that is not built")
+	}
+}
@@ -0,0 +1,78 @@
+// Package candles caches broker candles and builds time frames the broker
+// does not serve by aggregating finer ones.
+package candles
+
+import (
+	"sort"
+	"time"
+
+	"github.com/dragonzurfer/trader/executor"
+)
+
+// Candle is an executor.TimedCandleLike timestamped at candle open.
+type Candle struct {
+	Time   time.Time
+	Open   float64
+	High   float64
+	Low    float64
+	Close  float64
+	Volume float64
+	OI     float64
+}
+
+func (c Candle) GetTime() time.Time { return c.Time }
+func (c Candle) GetOpen() float64   { return c.Open }
+func (c Candle) GetHigh() float64   { return c.High }
+func (c Candle) GetLow() float64    { return c.Low }
+func (c Candle) GetClose() float64  { return c.Close }
+func (c Candle) GetVolume() float64 { return c.Volume }
+func (c Candle) GetOI() float64     { return c.OI }
+
+func NewCandle(candle executor.TimedCandleLike) Candle {
+	return Candle{
+		Time:   candle.GetTime(),
+		Open:   candle.GetOpen(),
+		High:   candle.GetHigh(),
+		Low:    candle.GetLow(),
+		Close:  candle.GetClose(),
+		Volume: candle.GetVolume(),
+		OI:     candle.GetOI(),
+	}
+}
+
+// merge adds candles to series, replacing those with the same open time,
+// and keeps the result in time order.
+func merge(series []Candle, candles []Candle) []Candle {
+	index := make(map[int64]int, len(series))
+	for i, candle := range series {
+		index[candle.Time.UnixNano()] = i
+	}
+	for _, candle := range candles {
+		if i, ok := index[candle.Time.UnixNano()]; ok {
+			series[i] = candle
+			continue
+		}
+		index[candle.Time.UnixNano()] = len(series)
+		series = append(series, candle)
+	}
+	sort.SliceStable(series, func(i, j int) bool { return series[i].Time.Before(series[j].Time) })
+	return series
+}
+
+// combine folds candles, oldest first, into one opening at t.
+func combine(t time.Time, candles []Candle) Candle {
+	combined := candles[0]
+	combined.Time = t
+	for _, candle := range candles[1:] {
+		if candle.High > combined.High {
+			combined.High = candle.High
+		}
+		if candle.Low < combined.Low {
+			combined.Low = candle.Low
+		}
+		combined.Close = candle.Close
+		combined.Volume += candle.Volume
+		combined.OI = candle.OI
+	}
+	return combined
+}
@@ -0,0 +1,83 @@
+package candles
+
+import (
+	"time"
+
+	"github.com/dragonzurfer/trader/executor"
+)
+
+// Session is the trading day candles are aligned to.
+type Session struct {
+	// Location is the exchange time zone. The location of the times
+	// given is used when nil.
+	Location *time.Location
+	// Open and Close are offsets from midnight. Intraday candles are
+	// aligned to Open, the last one of the day is cut short at Close and
+	// daily candles complete at it.
+	Open  time.Duration
+	Close time.Duration
+}
+
+// NSESession is the 9:15 to 15:30 session of the National Stock Exchange.
+func NSESession(location *time.Location) Session {
+	return Session{
+		Location: location,
+		Open:     9*time.Hour + 15*time.Minute,
+		Close:    15*time.Hour + 30*time.Minute,
+	}
+}
+
+// InSession reports whether t falls between the open and close of its day.
+func (s Session) InSession(t time.Time) bool {
+	day := s.midnight(t)
+	return !t.Before(day.Add(s.Open)) && t.Before(day.Add(s.Close))
+}
+
+func (s Session) midnight(t time.Time) time.Time {
+	location := s.Location
+	if location == nil {
+		location = t.Location()
+	}
+	t = t.In(location)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, location)
+}
+
+// bucketStart is the open of the tf candle containing t.
+func (s Session) bucketStart(t time.Time, tf executor.TimeFrame) time.Time {
+	day := s.midnight(t)
+	if tf == executor.Day {
+		return day
+	}
+	d := tf.Duration()
+	open := day.Add(s.Open)
+	n := t.Sub(open) / d
+	if t.Before(open) && t.Sub(open)%d != 0 {
+		n--
+	}
+	return open.Add(n * d)
+}
+
+// candleEnd is when the tf candle opened at start completes.
+func (s Session) candleEnd(start time.Time, tf executor.TimeFrame) time.Time {
+	sessionClose := s.midnight(start).Add(s.Close)
+	if tf == executor.Day {
+		return sessionClose
+	}
+	end := start.Add(tf.Duration())
+	if start.Before(sessionClose) && end.After(sessionClose) {
+		return sessionClose
+	}
+	return end
+}
+
+// coveredUntil is the open of the first tf candle not yet complete at t.
+func (s Session) coveredUntil(t time.Time, tf executor.TimeFrame) time.Time {
+	start := s.bucketStart(t, tf)
+	if s.candleEnd(start, tf).After(t) {
+		return start
+	}
+	if tf == executor.Day {
+		return s.midnight(start.AddDate(0, 0, 1))
+	}
+	return start.Add(tf.Duration())
+}
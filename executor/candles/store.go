@@ -0,0 +1,219 @@
+package candles
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dragonzurfer/trader/executor"
+)
+
+var errUntimed = errors.New("broker candles carry no time")
+
+// Store serves candles through the GetCandles signature of
+// executor.BrokerLike. Completed candles are cached per symbol and time
+// frame, so a repeated request only asks the broker for what it has not
+// yet seen complete.
+type Store struct {
+	Session
+	Broker executor.BrokerLike
+	// Direct lists the time frames fetched from Broker as they are. Any
+	// other is aggregated from the coarsest direct time frame dividing it.
+	Direct []executor.TimeFrame
+
+	now    func() time.Time
+	mu     sync.Mutex
+	series map[seriesKey]*series
+}
+
+type seriesKey struct {
+	symbol string
+	tf     executor.TimeFrame
+}
+
+// series holds every completed candle opened in [from, to).
+type series struct {
+	candles []Candle
+	from    time.Time
+	to      time.Time
+}
+
+// NewStore aggregates everything but daily candles from one minute
+// candles, over the NSE session.
+func NewStore(broker executor.BrokerLike, location *time.Location, now func() time.Time) *Store {
+	return &Store{
+		Session: NSESession(location),
+		Broker:  broker,
+		Direct:  []executor.TimeFrame{executor.Minute, executor.Day},
+		now:     now,
+		series:  make(map[seriesKey]*series),
+	}
+}
+
+// GetCandles returns the candles opened at or after from that had completed
+// by to, never looking past now. Daily candles are matched by date.
+//
+// Direct time frames whose candles do not implement
+// executor.TimedCandleLike are passed through from the broker uncached.
+func (s *Store) GetCandles(symbol string, from, to time.Time, tf executor.TimeFrame) ([]executor.CandleLike, error) {
+	if tf.Duration() == 0 {
+		return nil, fmt.Errorf("GetCandles(): time frame %v is not supported", tf)
+	}
+	if tf == executor.Day {
+		from = s.bucketStart(from, tf)
+	}
+	if now := s.now(); to.After(now) {
+		to = now
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.isDirect(tf) {
+		candles, err := s.fetch(symbol, tf, from, to)
+		if errors.Is(err, errUntimed) {
+			return s.Broker.GetCandles(symbol, from, to, tf)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("GetCandles(): %w", err)
+		}
+		return toCandleLike(s.completed(candles, tf, from, to)), nil
+	}
+
+	candles, err := s.aggregate(symbol, tf, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("GetCandles(): %w", err)
+	}
+	return toCandleLike(s.completed(candles, tf, from, to)), nil
+}
+
+func (s *Store) isDirect(tf executor.TimeFrame) bool {
+	for _, direct := range s.Direct {
+		if direct == tf {
+			return true
+		}
+	}
+	return false
+}
+
+// source is the coarsest direct time frame tf can be built from.
+func (s *Store) source(tf executor.TimeFrame) (executor.TimeFrame, error) {
+	var best executor.TimeFrame
+	for _, direct := range s.Direct {
+		d := direct.Duration()
+		if d == 0 || d >= tf.Duration() || tf.Duration()%d != 0 {
+			continue
+		}
+		if d > best.Duration() {
+			best = direct
+		}
+	}
+	if best == "" {
+		return "", fmt.Errorf("no direct time frame in %v divides %v", s.Direct, tf)
+	}
+	return best, nil
+}
+
+// fetch asks the broker for the part of [from, to] not cached yet and
+// returns the cached series.
+func (s *Store) fetch(symbol string, tf executor.TimeFrame, from, to time.Time) ([]Candle, error) {
+	from = s.bucketStart(from, tf)
+	if to.Before(from) {
+		return nil, nil
+	}
+	key := seriesKey{symbol: symbol, tf: tf}
+	cached, ok := s.series[key]
+	if !ok {
+		cached = &series{from: from, to: from}
+	}
+
+	type gap struct{ from, to time.Time }
+	var gaps []gap
+	if from.Before(cached.from) {
+		gaps = append(gaps, gap{from, cached.from})
+	}
+	// Until a candle after cached.to has completed there is nothing new.
+	covered := s.coveredUntil(to, tf)
+	if covered.After(cached.to) {
+		gaps = append(gaps, gap{cached.to, to})
+	}
+	for _, g := range gaps {
+		response, err := s.Broker.GetCandles(symbol, g.from, g.to, tf)
+		if err != nil {
+			return nil, err
+		}
+		fetched := make([]Candle, 0, len(response))
+		for _, candle := range response {
+			timed, ok := candle.(executor.TimedCandleLike)
+			if !ok {
+				return nil, errUntimed
+			}
+			fetched = append(fetched, NewCandle(timed))
+		}
+		cached.candles = merge(cached.candles, s.completed(fetched, tf, g.from, g.to))
+	}
+
+	if from.Before(cached.from) {
+		cached.from = from
+	}
+	if covered.After(cached.to) {
+		cached.to = covered
+	}
+	s.series[key] = cached
+	return cached.candles, nil
+}
+
+// aggregate builds tf candles from the source time frame's. The last one
+// may still be incomplete.
+func (s *Store) aggregate(symbol string, tf executor.TimeFrame, from, to time.Time) ([]Candle, error) {
+	source, err := s.source(tf)
+	if err != nil {
+		return nil, err
+	}
+	candles, err := s.fetch(symbol, source, s.bucketStart(from, tf), to)
+	if errors.Is(err, errUntimed) {
+		return nil, fmt.Errorf("cannot aggregate %v from %v: %w", tf, source, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var aggregated []Candle
+	var bucket []Candle
+	var start time.Time
+	for _, candle := range candles {
+		if candle.Time.Before(from) || candle.Time.After(to) {
+			continue
+		}
+		candleStart := s.bucketStart(candle.Time, tf)
+		if len(bucket) > 0 && !candleStart.Equal(start) {
+			aggregated = append(aggregated, combine(start, bucket))
+			bucket = nil
+		}
+		start = candleStart
+		bucket = append(bucket, candle)
+	}
+	if len(bucket) > 0 {
+		aggregated = append(aggregated, combine(start, bucket))
+	}
+	return aggregated, nil
+}
+
+func (s *Store) completed(candles []Candle, tf executor.TimeFrame, from, to time.Time) []Candle {
+	var completed []Candle
+	for _, candle := range candles {
+		if candle.Time.Before(from) || s.candleEnd(candle.Time, tf).After(to) {
+			continue
+		}
+		completed = append(completed, candle)
+	}
+	return completed
+}
+
+func toCandleLike(candles []Candle) []executor.CandleLike {
+	candleLikes := make([]executor.CandleLike, len(candles))
+	for i, candle := range candles {
+		candleLikes[i] = candle
+	}
+	return candleLikes
+}
@@ -0,0 +1,193 @@
+package candles
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dragonzurfer/trader/executor"
+)
+
+var ist = time.FixedZone("IST", 5*3600+1800)
+
+func at(h, m int) time.Time {
+	return time.Date(2023, 5, 16, h, m, 0, 0, ist)
+}
+
+type fetchCall struct {
+	from, to time.Time
+	tf       executor.TimeFrame
+}
+
+type untimedCandle struct{ executor.CandleLike }
+
+// minuteBroker serves one minute candles for the session of 2023-05-16,
+// the i-th opening at 100+i, and daily candles for the 15th and 16th. Like
+// the replay broker it returns candles opened at or after from that had
+// completed by to, matching daily candles by date.
+type minuteBroker struct {
+	executor.BrokerLike
+	candles map[executor.TimeFrame][]Candle
+	untimed bool
+	calls   []fetchCall
+}
+
+func newMinuteBroker() *minuteBroker {
+	b := &minuteBroker{candles: make(map[executor.TimeFrame][]Candle)}
+	for t, i := at(9, 15), 0; t.Before(at(15, 30)); t, i = t.Add(time.Minute), i+1 {
+		price := 100 + float64(i)
+		b.candles[executor.Minute] = append(b.candles[executor.Minute], Candle{
+			Time: t, Open: price, High: price + 2, Low: price - 1, Close: price + 1, Volume: 10,
+		})
+	}
+	b.candles[executor.Day] = []Candle{
+		{Time: time.Date(2023, 5, 15, 0, 0, 0, 0, ist), Open: 90, High: 120, Low: 80, Close: 100},
+		{Time: time.Date(2023, 5, 16, 0, 0, 0, 0, ist), Open: 100, High: 477, Low: 99, Close: 475},
+	}
+	return b
+}
+
+func (b *minuteBroker) GetCandles(symbol string, from, to time.Time, tf executor.TimeFrame) ([]executor.CandleLike, error) {
+	b.calls = append(b.calls, fetchCall{from, to, tf})
+	if tf == executor.Day {
+		to = time.Date(to.Year(), to.Month(), to.Day()+1, 0, 0, 0, 0, to.Location())
+	}
+	var candles []executor.CandleLike
+	for _, candle := range b.candles[tf] {
+		if candle.Time.Before(from) || candle.Time.Add(tf.Duration()).After(to) {
+			continue
+		}
+		if b.untimed {
+			candles = append(candles, untimedCandle{candle})
+		} else {
+			candles = append(candles, candle)
+		}
+	}
+	return candles, nil
+}
+
+func newTestStore(broker *minuteBroker, now *time.Time) *Store {
+	return NewStore(broker, ist, func() time.Time { return *now })
+}
+
+func TestStoreFetchesIncrementally(t *testing.T) {
+	broker := newMinuteBroker()
+	now := at(9, 20).Add(30 * time.Second)
+	store := newTestStore(broker, &now)
+
+	candles, err := store.GetCandles("NIFTY", at(9, 15), now, executor.Minute)
+	if err != nil {
+		t.Fatalf("GetCandles() failed: %v", err)
+	}
+	if len(candles) != 5 {
+		t.Fatalf("got %v candles at 9:20, want 5", len(candles))
+	}
+
+	// nothing new has completed within the same minute
+	store.GetCandles("NIFTY", at(9, 15), now.Add(10*time.Second), executor.Minute)
+	if len(broker.calls) != 1 {
+		t.Errorf("broker called %v times within a minute, want 1", len(broker.calls))
+	}
+
+	now = at(9, 25).Add(30 * time.Second)
+	candles, _ = store.GetCandles("NIFTY", at(9, 15), now, executor.Minute)
+	if len(candles) != 10 {
+		t.Fatalf("got %v candles at 9:25, want 10", len(candles))
+	}
+	if last := broker.calls[len(broker.calls)-1]; !last.from.Equal(at(9, 20)) {
+		t.Errorf("second fetch from %v, want 9:20", last.from)
+	}
+	if got := candles[9].(Candle).Time; !got.Equal(at(9, 24)) {
+		t.Errorf("last candle opened at %v, want 9:24", got)
+	}
+}
+
+func TestStoreAggregatesIntraday(t *testing.T) {
+	broker := newMinuteBroker()
+	now := at(9, 31)
+	store := newTestStore(broker, &now)
+
+	candles, err := store.GetCandles("NIFTY", at(9, 15), now, executor.Minute5)
+	if err != nil {
+		t.Fatalf("GetCandles() failed: %v", err)
+	}
+	if len(candles) != 3 {
+		t.Fatalf("got %v 5min candles, want 3", len(candles))
+	}
+	first := candles[0].(Candle)
+	if !first.Time.Equal(at(9, 15)) || first.Open != 100 || first.High != 106 || first.Low != 99 || first.Close != 105 || first.Volume != 50 {
+		t.Errorf("first 5min candle = %+v", first)
+	}
+
+	now = at(15, 40)
+	candles, _ = store.GetCandles("NIFTY", at(9, 15), now, executor.Hour)
+	if len(candles) != 7 {
+		t.Fatalf("got %v hourly candles, want 7", len(candles))
+	}
+	last := candles[6].(Candle)
+	if !last.Time.Equal(at(15, 15)) || last.Volume != 150 {
+		t.Errorf("last hourly candle = %+v, want the 15 minutes to the close", last)
+	}
+	for _, call := range broker.calls {
+		if call.tf != executor.Minute {
+			t.Errorf("fetched %v, want only one minute candles", call.tf)
+		}
+	}
+}
+
+func TestStoreAggregatesDay(t *testing.T) {
+	broker := newMinuteBroker()
+	now := at(15, 0)
+	store := newTestStore(broker, &now)
+	store.Direct = []executor.TimeFrame{executor.Minute}
+
+	candles, _ := store.GetCandles("NIFTY", at(9, 15), now, executor.Day)
+	if len(candles) != 0 {
+		t.Fatalf("got %v daily candles before the close, want 0", len(candles))
+	}
+
+	now = at(16, 0)
+	candles, _ = store.GetCandles("NIFTY", at(9, 15), now, executor.Day)
+	if len(candles) != 1 {
+		t.Fatalf("got %v daily candles after the close, want 1", len(candles))
+	}
+	day := candles[0].(Candle)
+	if day.Open != 100 || day.Close != 475 || day.High != 476 || day.Volume != 3750 {
+		t.Errorf("daily candle = %+v", day)
+	}
+}
+
+func TestStoreCachesDailyCandles(t *testing.T) {
+	broker := newMinuteBroker()
+	now := at(10, 0)
+	store := newTestStore(broker, &now)
+	previousDay := time.Date(2023, 5, 15, 9, 15, 0, 0, ist)
+
+	for i := 0; i < 3; i++ {
+		candles, err := store.GetCandles("NIFTY", previousDay, previousDay.Add(6*time.Hour+15*time.Minute), executor.Day)
+		if err != nil {
+			t.Fatalf("GetCandles() failed: %v", err)
+		}
+		if len(candles) != 1 || candles[0].GetClose() != 100 {
+			t.Fatalf("got %+v, want the candle of the 15th", candles)
+		}
+		now = now.Add(5 * time.Minute)
+	}
+	if len(broker.calls) != 1 {
+		t.Errorf("broker called %v times, want 1", len(broker.calls))
+	}
+}
+
+func TestStorePassesUntimedCandlesThrough(t *testing.T) {
+	broker := newMinuteBroker()
+	broker.untimed = true
+	now := at(9, 31)
+	store := newTestStore(broker, &now)
+
+	candles, err := store.GetCandles("NIFTY", at(9, 15), now, executor.Minute)
+	if err != nil || len(candles) != 16 {
+		t.Errorf("got %v candles and error %v, want the 16 from the broker", len(candles), err)
+	}
+	if _, err := store.GetCandles("NIFTY", at(9, 15), now, executor.Minute5); err == nil {
+		t.Errorf("aggregating untimed candles succeeded")
+	}
+}
@@ -0,0 +1,166 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// Dispatcher fans the ticks of one feed out to every subscriber of their
+// symbol, so several traders can share a connection. Each subscriber gets
+// the latest price: when it has not read the previous tick yet, that tick
+// is replaced rather than holding up the others.
+type Dispatcher struct {
+	Feed StreamingBrokerLike
+	// OnEvent is called with every feed event, after subscriptions have
+	// been restored on a reconnect. Events are logged when nil.
+	OnEvent func(FeedEvent)
+
+	mu          sync.Mutex
+	subscribers map[string][]chan float64
+}
+
+func NewDispatcher(feed StreamingBrokerLike) *Dispatcher {
+	return &Dispatcher{
+		Feed:        feed,
+		subscribers: make(map[string][]chan float64),
+	}
+}
+
+// Subscribe returns a channel of symbol's prices, subscribing the feed to
+// symbol for its first subscriber.
+func (d *Dispatcher) Subscribe(symbol string) (<-chan float64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.subscribers[symbol]) == 0 {
+		if err := d.Feed.Subscribe(symbol); err != nil {
+			return nil, fmt.Errorf("Subscribe(): %w", err)
+		}
+	}
+	ticks := make(chan float64, 1)
+	d.subscribers[symbol] = append(d.subscribers[symbol], ticks)
+	return ticks, nil
+}
+
+// AddTrader points trader's Ticks at symbol's prices.
+func (d *Dispatcher) AddTrader(trader *Trader, symbol string) error {
+	ticks, err := d.Subscribe(symbol)
+	if err != nil {
+		return err
+	}
+	trader.Ticks = ticks
+	return nil
+}
+
+// Unsubscribe closes ticks, unsubscribing the feed from symbol once no one
+// is left listening.
+func (d *Dispatcher) Unsubscribe(symbol string, ticks <-chan float64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	subscribers := d.subscribers[symbol]
+	for i, subscriber := range subscribers {
+		if subscriber != ticks {
+			continue
+		}
+		close(subscriber)
+		subscribers = append(subscribers[:i], subscribers[i+1:]...)
+		if len(subscribers) > 0 {
+			d.subscribers[symbol] = subscribers
+			return nil
+		}
+		delete(d.subscribers, symbol)
+		if err := d.Feed.Unsubscribe(symbol); err != nil {
+			return fmt.Errorf("Unsubscribe(): %w", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("Unsubscribe(): not subscribed to %v", symbol)
+}
+
+// Run dispatches until ctx is cancelled or the feed closes, then closes
+// every subscriber's channel.
+func (d *Dispatcher) Run(ctx context.Context) error {
+	defer d.closeAll()
+	ticks, events := d.Feed.Ticks(), d.Feed.Events()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case tick, ok := <-ticks:
+			if !ok {
+				return errors.New("Run(): feed closed")
+			}
+			d.dispatch(tick)
+		case event, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			d.onEvent(event)
+		}
+	}
+}
+
+func (d *Dispatcher) dispatch(tick Tick) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, subscriber := range d.subscribers[tick.Symbol] {
+		select {
+		case subscriber <- tick.Price:
+			continue
+		default:
+		}
+		// drop the stale price the subscriber has not read
+		select {
+		case <-subscriber:
+		default:
+		}
+		select {
+		case subscriber <- tick.Price:
+		default:
+		}
+	}
+}
+
+func (d *Dispatcher) onEvent(event FeedEvent) {
+	if event.Type == FeedReconnected {
+		if err := d.resubscribe(); err != nil {
+			log.Println("dispatcher: resubscribing after reconnect failed:", err.Error())
+		}
+	}
+	if d.OnEvent != nil {
+		d.OnEvent(event)
+		return
+	}
+	if event.Err != nil {
+		log.Printf("dispatcher: feed %v: %v\n", event.Type, event.Err)
+		return
+	}
+	log.Printf("dispatcher: feed %v\n", event.Type)
+}
+
+func (d *Dispatcher) resubscribe() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.subscribers) == 0 {
+		return nil
+	}
+	symbols := make([]string, 0, len(d.subscribers))
+	for symbol := range d.subscribers {
+		symbols = append(symbols, symbol)
+	}
+	return d.Feed.Subscribe(symbols...)
+}
+
+func (d *Dispatcher) closeAll() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for symbol, subscribers := range d.subscribers {
+		for _, subscriber := range subscribers {
+			close(subscriber)
+		}
+		delete(d.subscribers, symbol)
+	}
+}
@@ -0,0 +1,146 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func receive(t *testing.T, ticks <-chan float64) float64 {
+	t.Helper()
+	select {
+	case price := <-ticks:
+		return price
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for a tick")
+	}
+	return 0
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.After(time.Second)
+	for !condition() {
+		select {
+		case <-deadline:
+			t.Fatal("timeout waiting for condition")
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func TestDispatcherFansOutBySymbol(t *testing.T) {
+	feed := NewLocalFeed(10)
+	dispatcher := NewDispatcher(feed)
+	first, _ := dispatcher.Subscribe("NIFTY")
+	second, _ := dispatcher.Subscribe("NIFTY")
+	bank, _ := dispatcher.Subscribe("BANKNIFTY")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- dispatcher.Run(ctx) }()
+
+	feed.Push(Tick{Symbol: "NIFTY", Price: 18300})
+	if a, b := receive(t, first), receive(t, second); a != 18300 || b != 18300 {
+		t.Errorf("subscribers got %v and %v, want 18300", a, b)
+	}
+	feed.Push(Tick{Symbol: "BANKNIFTY", Price: 43000})
+	if price := receive(t, bank); price != 43000 {
+		t.Errorf("BANKNIFTY subscriber got %v", price)
+	}
+	select {
+	case price := <-first:
+		t.Errorf("NIFTY subscriber got another symbol's tick %v", price)
+	default:
+	}
+
+	if err := dispatcher.Unsubscribe("NIFTY", first); err != nil {
+		t.Fatalf("Unsubscribe() failed: %v", err)
+	}
+	if !feed.IsSubscribed("NIFTY") {
+		t.Errorf("feed unsubscribed while NIFTY still has a subscriber")
+	}
+	dispatcher.Unsubscribe("NIFTY", second)
+	if feed.IsSubscribed("NIFTY") {
+		t.Errorf("feed still subscribed to NIFTY with no subscribers")
+	}
+	if _, ok := <-second; ok {
+		t.Errorf("unsubscribed channel is still open")
+	}
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if _, ok := <-bank; ok {
+		t.Errorf("channel still open after Run returned")
+	}
+}
+
+func TestDispatcherKeepsLatestPrice(t *testing.T) {
+	feed := NewLocalFeed(10)
+	dispatcher := NewDispatcher(feed)
+	ticks, _ := dispatcher.Subscribe("NIFTY")
+
+	for _, price := range []float64{1, 2, 3} {
+		dispatcher.dispatch(Tick{Symbol: "NIFTY", Price: price})
+	}
+	if price := receive(t, ticks); price != 3 {
+		t.Errorf("got %v, want the latest price 3", price)
+	}
+}
+
+func TestDispatcherResubscribesOnReconnect(t *testing.T) {
+	feed := NewLocalFeed(10)
+	dispatcher := NewDispatcher(feed)
+	events := make(chan FeedEvent, 10)
+	dispatcher.OnEvent = func(event FeedEvent) { events <- event }
+	ticks, _ := dispatcher.Subscribe("NIFTY")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go dispatcher.Run(ctx)
+
+	feed.Disconnect(errors.New("connection reset"))
+	if event := <-events; event.Type != FeedDisconnected || event.Err == nil {
+		t.Errorf("got event %+v, want a disconnect with its cause", event)
+	}
+	if feed.Push(Tick{Symbol: "NIFTY", Price: 1}) {
+		t.Errorf("disconnected feed sent a tick")
+	}
+
+	feed.Reconnect()
+	if event := <-events; event.Type != FeedReconnected {
+		t.Errorf("got event %+v, want a reconnect", event)
+	}
+	if !feed.IsSubscribed("NIFTY") {
+		t.Fatalf("NIFTY not resubscribed after reconnect")
+	}
+	feed.Push(Tick{Symbol: "NIFTY", Price: 18310})
+	if price := receive(t, ticks); price != 18310 {
+		t.Errorf("got %v after reconnect, want 18310", price)
+	}
+}
+
+func TestDispatcherDrivesTraderExits(t *testing.T) {
+	feed := NewLocalFeed(10)
+	dispatcher := NewDispatcher(feed)
+	exec := newFakeExecutor()
+	trader := Trader{ID: "test", Executor: exec}
+	if err := dispatcher.AddTrader(&trader, "NIFTY"); err != nil {
+		t.Fatalf("AddTrader() failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go dispatcher.Run(ctx)
+	go trader.Run(ctx)
+
+	waitFor(t, exec.InTrade)
+	exec.setEntry(false)
+	feed.Push(Tick{Symbol: "NIFTY", Price: 90})
+	waitFor(t, func() bool { return !exec.InTrade() })
+
+	feed.Close()
+}
@@ -0,0 +1,49 @@
+package executor
+
+import "time"
+
+// ExitReason is why a trade was closed. TrailAdjusted is the one reason an
+// Event carries for a trade that is still open.
+type ExitReason string
+
+const (
+	ExitStopLoss ExitReason = "stop_loss"
+	ExitTarget   ExitReason = "target"
+	// TrailAdjusted reports that the stop loss was trailed.
+	TrailAdjusted ExitReason = "trail_adjust"
+	// ExitTime is an exit by the clock, such as a square off time.
+	ExitTime ExitReason = "time"
+	// ExitManual is an exit asked for outside the executor's own rules.
+	ExitManual    ExitReason = "manual"
+	ExitRiskLimit ExitReason = "risk_limit"
+	// ExitError is an exit forced by a failure, such as unwinding a
+	// partially entered spread.
+	ExitError ExitReason = "error"
+)
+
+// Event is sent by an executor on its event channel when it closes its
+// trade, or moves its stop loss, on its own.
+type Event struct {
+	Reason ExitReason
+	// Detail qualifies the reason, e.g. which rule fired.
+	Detail string
+	Time   time.Time
+}
+
+// IsExit reports whether the event closed the trade.
+func (e Event) IsExit() bool {
+	return e.Reason != TrailAdjusted
+}
+
+func (e Event) String() string {
+	if e.Detail == "" {
+		return string(e.Reason)
+	}
+	return string(e.Reason) + " (" + e.Detail + ")"
+}
+
+// ExitReasonLike is implemented by executors that record why the runner,
+// rather than the executor itself, exits their trade.
+type ExitReasonLike interface {
+	SetExitReason(reason ExitReason, detail string)
+}
@@ -0,0 +1,248 @@
+package executor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// PnLLike is implemented by executors that can value their trade: the
+// profit of the open trade at current prices, or the realized profit of
+// the last one once exited.
+type PnLLike interface {
+	GetPnL() (float64, error)
+}
+
+// GuardLimits are account wide limits shared by every trader. Limits left
+// at 0 are off.
+type GuardLimits struct {
+	// MaxDailyLoss is the largest loss of the day, realized and unrealized
+	// across traders, before trading halts for the rest of the day.
+	MaxDailyLoss float64 `json:"max_daily_loss"`
+	// MaxTradesPerDay stops new entries once that many trades were entered
+	// during the day.
+	MaxTradesPerDay int `json:"max_trades_per_day"`
+	// KillSwitchFilePath halts trading for as long as the file exists.
+	KillSwitchFilePath string `json:"kill_switch_file_path"`
+	// StateFilePath persists the day's trades, realized P&L and breach, so
+	// a restart on the same day does not resume trading.
+	StateFilePath string `json:"state_file_path"`
+	// FlattenOnBreach exits open trades when trading halts.
+	FlattenOnBreach bool `json:"flatten_on_breach"`
+}
+
+type guardState struct {
+	Date     string  `json:"date"`
+	Trades   int     `json:"trades"`
+	Realized float64 `json:"realized"`
+	// Breach is why trading halted for the day.
+	Breach string `json:"breach,omitempty"`
+}
+
+// Guard enforces GuardLimits across traders. It is safe for concurrent
+// use.
+type Guard struct {
+	Limits GuardLimits
+	// Location decides when a day starts, the local time zone when nil.
+	Location *time.Location
+
+	mu         sync.Mutex
+	state      guardState
+	unrealized map[string]float64
+}
+
+// NewGuard returns a guard for limits with the state persisted in
+// limits.StateFilePath, if any.
+func NewGuard(limits GuardLimits, location *time.Location) (*Guard, error) {
+	g := &Guard{Limits: limits, Location: location, unrealized: make(map[string]float64)}
+	if limits.StateFilePath == "" {
+		return g, nil
+	}
+	data, err := ioutil.ReadFile(limits.StateFilePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return g, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("NewGuard(): %w", err)
+	}
+	if err := json.Unmarshal(data, &g.state); err != nil {
+		return nil, fmt.Errorf("NewGuard(): %w", err)
+	}
+	return g, nil
+}
+
+// Halted reports whether trading is halted at now, and why: the kill
+// switch is on or the day's loss limit was breached.
+func (g *Guard) Halted(now time.Time) (bool, string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.halted(now)
+}
+
+func (g *Guard) halted(now time.Time) (bool, string) {
+	if g.Limits.KillSwitchFilePath != "" {
+		if _, err := os.Stat(g.Limits.KillSwitchFilePath); err == nil {
+			return true, "kill switch is on"
+		}
+	}
+	g.rollover(now)
+	if g.state.Breach != "" {
+		return true, g.state.Breach
+	}
+	return false, ""
+}
+
+// CanEnter reports whether a new trade may be entered at now, and why not.
+func (g *Guard) CanEnter(now time.Time) (bool, string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if halted, reason := g.halted(now); halted {
+		return false, reason
+	}
+	if g.Limits.MaxTradesPerDay > 0 && g.state.Trades >= g.Limits.MaxTradesPerDay {
+		return false, fmt.Sprintf("%v trades reach the daily limit of %v", g.state.Trades, g.Limits.MaxTradesPerDay)
+	}
+	return true, ""
+}
+
+// RecordEntry counts a trade entered by trader id.
+func (g *Guard) RecordEntry(id string, now time.Time) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.rollover(now)
+	g.state.Trades++
+	return g.save()
+}
+
+// UpdatePnL marks the open trade of trader id at pnl and halts trading
+// when the day's loss reaches the limit.
+func (g *Guard) UpdatePnL(id string, pnl float64, now time.Time) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.rollover(now)
+	g.unrealized[id] = pnl
+	return g.checkLoss()
+}
+
+// RecordExit books the realized pnl of the trade trader id exited.
+func (g *Guard) RecordExit(id string, pnl float64, now time.Time) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.rollover(now)
+	delete(g.unrealized, id)
+	g.state.Realized += pnl
+	if err := g.checkLoss(); err != nil {
+		return err
+	}
+	return g.save()
+}
+
+// Kill turns the kill switch on, halting every trader until its file is
+// removed.
+func (g *Guard) Kill(reason string) error {
+	if g.Limits.KillSwitchFilePath == "" {
+		return errors.New("Kill(): no kill switch file configured")
+	}
+	if err := ioutil.WriteFile(g.Limits.KillSwitchFilePath, []byte(reason+"\n"), 0644); err != nil {
+		return fmt.Errorf("Kill(): %w", err)
+	}
+	return nil
+}
+
+// PnL is the day's realized and unrealized profit across traders.
+func (g *Guard) PnL(now time.Time) (realized float64, unrealized float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.rollover(now)
+	for _, pnl := range g.unrealized {
+		unrealized += pnl
+	}
+	return g.state.Realized, unrealized
+}
+
+func (g *Guard) checkLoss() error {
+	if g.Limits.MaxDailyLoss <= 0 || g.state.Breach != "" {
+		return nil
+	}
+	pnl := g.state.Realized
+	for _, unrealized := range g.unrealized {
+		pnl += unrealized
+	}
+	if pnl > -g.Limits.MaxDailyLoss {
+		return nil
+	}
+	g.state.Breach = fmt.Sprintf("daily loss of %.2f reaches the limit of %.2f", -pnl, g.Limits.MaxDailyLoss)
+	return g.save()
+}
+
+// rollover starts a new day's state once now is past the day of the
+// current one. Open trades keep their unrealized P&L.
+func (g *Guard) rollover(now time.Time) {
+	if g.Location != nil {
+		now = now.In(g.Location)
+	}
+	date := now.Format("2006-01-02")
+	if g.state.Date == date {
+		return
+	}
+	g.state = guardState{Date: date}
+}
+
+func (g *Guard) save() error {
+	if g.Limits.StateFilePath == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(g.state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("save(): %w", err)
+	}
+	if err := ioutil.WriteFile(g.Limits.StateFilePath, data, 0644); err != nil {
+		return fmt.Errorf("save(): %w", err)
+	}
+	return nil
+}
+
+// GuardStatus is what Handler reports.
+type GuardStatus struct {
+	Halted     bool    `json:"halted"`
+	Reason     string  `json:"reason,omitempty"`
+	Trades     int     `json:"trades"`
+	Realized   float64 `json:"realized"`
+	Unrealized float64 `json:"unrealized"`
+}
+
+// Handler serves the guard's status on GET and turns the kill switch on
+// with the reason form value on POST.
+func (g *Guard) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			reason := r.FormValue("reason")
+			if reason == "" {
+				reason = "killed over http"
+			}
+			if err := g.Kill(reason); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		now := time.Now()
+		var status GuardStatus
+		status.Halted, status.Reason = g.Halted(now)
+		status.Realized, status.Unrealized = g.PnL(now)
+		g.mu.Lock()
+		status.Trades = g.state.Trades
+		g.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(status)
+	})
+}
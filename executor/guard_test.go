@@ -0,0 +1,268 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGuardDailyLoss(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "guard.json")
+	limits := GuardLimits{MaxDailyLoss: 5000, MaxTradesPerDay: 2, StateFilePath: statePath}
+	guard, err := NewGuard(limits, time.UTC)
+	if err != nil {
+		t.Fatalf("NewGuard() failed: %v", err)
+	}
+	morning := time.Date(2023, 5, 16, 4, 0, 0, 0, time.UTC)
+
+	guard.RecordEntry("nifty", morning)
+	guard.RecordExit("nifty", -3000, morning)
+	guard.RecordEntry("banknifty", morning)
+	if ok, reason := guard.CanEnter(morning); ok || !strings.Contains(reason, "daily limit of 2") {
+		t.Fatalf("expected the trade limit to stop entries, got %v %q", ok, reason)
+	}
+	if halted, _ := guard.Halted(morning); halted {
+		t.Fatal("the trade limit should not halt open trades")
+	}
+
+	guard.UpdatePnL("banknifty", -1500, morning)
+	if halted, _ := guard.Halted(morning); halted {
+		t.Fatal("a loss of 4500 should not breach the limit")
+	}
+	guard.UpdatePnL("banknifty", -2500, morning)
+	if halted, reason := guard.Halted(morning); !halted || !strings.Contains(reason, "daily loss of 5500.00") {
+		t.Fatalf("expected the loss limit to halt trading, got %v %q", halted, reason)
+	}
+
+	// a restart on the same day stays halted
+	restarted, err := NewGuard(limits, time.UTC)
+	if err != nil {
+		t.Fatalf("NewGuard() failed: %v", err)
+	}
+	if halted, _ := restarted.Halted(morning.Add(time.Hour)); !halted {
+		t.Fatal("expected the breach to be persisted")
+	}
+	if realized, _ := restarted.PnL(morning); realized != -3000 {
+		t.Fatalf("expected the realized P&L to be persisted, got %v", realized)
+	}
+	if ok, _ := restarted.CanEnter(morning.Add(24 * time.Hour)); !ok {
+		t.Fatal("expected trading to resume the next day")
+	}
+}
+
+func TestGuardKillSwitch(t *testing.T) {
+	killPath := filepath.Join(t.TempDir(), "kill")
+	guard, err := NewGuard(GuardLimits{KillSwitchFilePath: killPath}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(guard.Handler())
+	defer server.Close()
+
+	response, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var status GuardStatus
+	json.NewDecoder(response.Body).Decode(&status)
+	response.Body.Close()
+	if status.Halted {
+		t.Fatal("expected trading before the kill switch")
+	}
+
+	response, err = http.PostForm(server.URL, map[string][]string{"reason": {"manual"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	json.NewDecoder(response.Body).Decode(&status)
+	response.Body.Close()
+	if !status.Halted || status.Reason != "kill switch is on" {
+		t.Fatalf("expected the kill switch to halt trading, got %+v", status)
+	}
+
+	if err := os.Remove(killPath); err != nil {
+		t.Fatal(err)
+	}
+	if halted, _ := guard.Halted(time.Now()); halted {
+		t.Fatal("expected trading to resume once the kill switch file is removed")
+	}
+}
+
+type guardedExecutor struct {
+	*fakeExecutor
+	mu     *sync.Mutex
+	pnl    float64
+	reason ExitReason
+	detail string
+}
+
+func (e *guardedExecutor) GetPnL() (float64, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.pnl, nil
+}
+
+func (e *guardedExecutor) SetExitReason(reason ExitReason, detail string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.reason = reason
+	e.detail = detail
+}
+
+func TestRunFlattensOnBreach(t *testing.T) {
+	guard, err := NewGuard(GuardLimits{MaxDailyLoss: 1000, FlattenOnBreach: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exec := &guardedExecutor{fakeExecutor: newFakeExecutor(), mu: &sync.Mutex{}, pnl: -1200}
+	exec.stopLoss = 0
+	ticks := make(chan float64)
+	trader := Trader{ID: "test", Executor: exec, Ticks: ticks, Guard: guard}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go trader.Run(ctx)
+
+	deadline := time.After(time.Second)
+	for !exec.InTrade() {
+		select {
+		case <-deadline:
+			t.Fatal("timeout waiting for entry")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	ticks <- 18000
+	for exec.InTrade() {
+		select {
+		case <-deadline:
+			t.Fatal("timeout waiting for the breach to flatten the trade")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	exec.mu.Lock()
+	reason := exec.reason
+	exec.mu.Unlock()
+	if reason != ExitRiskLimit {
+		t.Fatalf("expected a risk limit exit, got %q", reason)
+	}
+
+	// entries stay stopped while halted
+	time.Sleep(50 * time.Millisecond)
+	if paper, _, _ := exec.counts(); paper != 1 {
+		t.Fatalf("expected no entry after the breach, got %v", paper)
+	}
+	if realized, _ := guard.PnL(time.Now()); realized != -1200 {
+		t.Fatalf("expected the exit to be booked, got %v", realized)
+	}
+}
+
+// silentExitExecutor exits on ticks without sending the exit event.
+type silentExitExecutor struct {
+	*guardedExecutor
+}
+
+func (e *silentExitExecutor) ExitOnTick(price float64) {
+	if e.InTrade() && price <= e.stopLoss {
+		e.ExitPaper()
+	}
+}
+
+func TestRunBooksTickExitOnce(t *testing.T) {
+	for _, silent := range []bool{false, true} {
+		guard, err := NewGuard(GuardLimits{MaxDailyLoss: 10000}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		guarded := &guardedExecutor{fakeExecutor: newFakeExecutor(), mu: &sync.Mutex{}, pnl: -300}
+		var exec ExecutorLike = guarded
+		if silent {
+			exec = &silentExitExecutor{guarded}
+		}
+		ticks := make(chan float64)
+		trader := Trader{ID: "test", Executor: exec, Ticks: ticks, Guard: guard}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go trader.Run(ctx)
+
+		deadline := time.After(time.Second)
+		for !guarded.InTrade() {
+			select {
+			case <-deadline:
+				t.Fatal("timeout waiting for entry")
+			case <-time.After(time.Millisecond):
+			}
+		}
+		ticks <- 90
+		// the next step enters again, before any exit event
+		for paper, _, _ := guarded.counts(); paper < 2; paper, _, _ = guarded.counts() {
+			select {
+			case <-deadline:
+				t.Fatal("timeout waiting for the second entry")
+			case <-time.After(time.Millisecond):
+			}
+		}
+		time.Sleep(30 * time.Millisecond)
+		cancel()
+		if realized, _ := guard.PnL(time.Now()); realized != -300 {
+			t.Errorf("silent %v: expected the exit to be booked once, got %v", silent, realized)
+		}
+	}
+}
+
+// clockedExecutor keeps its own time, as a replay does.
+type clockedExecutor struct {
+	*guardedExecutor
+	now time.Time
+}
+
+func (e *clockedExecutor) Now() time.Time { return e.now }
+
+func TestRunGuardsOnExecutorClock(t *testing.T) {
+	guard, err := NewGuard(GuardLimits{MaxDailyLoss: 10000}, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed := time.Date(2023, 5, 16, 10, 0, 0, 0, time.UTC)
+	guarded := &guardedExecutor{fakeExecutor: newFakeExecutor(), mu: &sync.Mutex{}, pnl: -300}
+	exec := &clockedExecutor{guardedExecutor: guarded, now: replayed}
+	ticks := make(chan float64)
+	trader := Trader{ID: "test", Executor: exec, Ticks: ticks, Guard: guard}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go trader.Run(ctx)
+
+	deadline := time.After(time.Second)
+	for !guarded.InTrade() {
+		select {
+		case <-deadline:
+			t.Fatal("timeout waiting for entry")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	guarded.setEntry(false)
+	ticks <- 90
+	for guarded.InTrade() {
+		select {
+		case <-deadline:
+			t.Fatal("timeout waiting for the exit")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	time.Sleep(30 * time.Millisecond)
+	cancel()
+
+	guard.mu.Lock()
+	trades := guard.state.Trades
+	guard.mu.Unlock()
+	if realized, _ := guard.PnL(replayed); realized != -300 || trades != 1 {
+		t.Fatalf("expected the trade booked on the replayed day, got %v realized over %v trades", realized, trades)
+	}
+}
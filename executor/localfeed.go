@@ -0,0 +1,108 @@
+package executor
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// LocalFeed is an in-process StreamingBrokerLike for tests and
+// simulations. Prices are pushed with Push and connection trouble is
+// simulated with Disconnect and Reconnect.
+type LocalFeed struct {
+	mu         sync.Mutex
+	connected  bool
+	closed     bool
+	subscribed map[string]bool
+	ticks      chan Tick
+	events     chan FeedEvent
+}
+
+// NewLocalFeed returns a connected feed whose channels hold up to buffer
+// ticks and events.
+func NewLocalFeed(buffer int) *LocalFeed {
+	return &LocalFeed{
+		connected:  true,
+		subscribed: make(map[string]bool),
+		ticks:      make(chan Tick, buffer),
+		events:     make(chan FeedEvent, buffer),
+	}
+}
+
+func (f *LocalFeed) Subscribe(symbols ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.connected {
+		return errors.New("Subscribe(): feed is disconnected")
+	}
+	for _, symbol := range symbols {
+		f.subscribed[symbol] = true
+	}
+	return nil
+}
+
+func (f *LocalFeed) Unsubscribe(symbols ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, symbol := range symbols {
+		delete(f.subscribed, symbol)
+	}
+	return nil
+}
+
+func (f *LocalFeed) Ticks() <-chan Tick       { return f.ticks }
+func (f *LocalFeed) Events() <-chan FeedEvent { return f.events }
+
+func (f *LocalFeed) IsSubscribed(symbol string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.subscribed[symbol]
+}
+
+// Push sends tick if the feed is connected and subscribed to its symbol,
+// blocking while the tick buffer is full. It reports whether tick was sent.
+func (f *LocalFeed) Push(tick Tick) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed || !f.connected || !f.subscribed[tick.Symbol] {
+		return false
+	}
+	f.ticks <- tick
+	return true
+}
+
+// Disconnect stops ticks until Reconnect.
+func (f *LocalFeed) Disconnect(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed || !f.connected {
+		return
+	}
+	f.connected = false
+	f.events <- FeedEvent{Type: FeedDisconnected, Err: err, Time: time.Now()}
+}
+
+// Reconnect restores the connection with no subscriptions, as a broker
+// would after a dropped connection.
+func (f *LocalFeed) Reconnect() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed || f.connected {
+		return
+	}
+	f.connected = true
+	f.subscribed = make(map[string]bool)
+	f.events <- FeedEvent{Type: FeedReconnected, Time: time.Now()}
+}
+
+// Close shuts the feed down, closing its channels.
+func (f *LocalFeed) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return
+	}
+	f.closed = true
+	close(f.ticks)
+	close(f.events)
+}
@@ -0,0 +1,47 @@
+package executor
+
+import "time"
+
+type OrderType string
+
+const (
+	MarketOrder OrderType = "MARKET"
+	LimitOrder  OrderType = "LIMIT"
+)
+
+type OrderStatus string
+
+const (
+	OrderPending         OrderStatus = "PENDING"
+	OrderPartiallyFilled OrderStatus = "PARTIALLY_FILLED"
+	OrderFilled          OrderStatus = "FILLED"
+	OrderRejected        OrderStatus = "REJECTED"
+	OrderCancelled       OrderStatus = "CANCELLED"
+)
+
+// IsTerminal reports whether no further fills can happen on the order.
+func (s OrderStatus) IsTerminal() bool {
+	return s == OrderFilled || s == OrderRejected || s == OrderCancelled
+}
+
+// OrderRequest describes an option order in the same terms used by
+// GetMarketDepthOption.
+type OrderRequest struct {
+	Strike     float64
+	Expiry     time.Time
+	OptionType OptionType
+	TradeType  TradeType
+	OrderType  OrderType
+	Quantity   int64
+	LimitPrice float64
+}
+
+// OrderUpdate is the broker's view of an order at UpdatedAt.
+type OrderUpdate struct {
+	OrderID        string
+	Status         OrderStatus
+	FilledQuantity int64
+	AveragePrice   float64
+	UpdatedAt      time.Time
+	Message        string
+}
@@ -0,0 +1,154 @@
+package executor
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Exposure is the risk of open positions on one underlying. Delta and
+// Gamma are in units of the underlying, Theta in rupees per day and Vega
+// in rupees per point of volatility.
+type Exposure struct {
+	Underlying string
+	Delta      float64
+	Gamma      float64
+	Theta      float64
+	Vega       float64
+	// Quantity is the number of options held, bought and sold alike.
+	Quantity int64
+	// Notional is the quantity of the positions valued at the underlying.
+	Notional float64
+	// MarginAtRisk is the premium paid on bought positions and the margin
+	// blocked by sold ones.
+	MarginAtRisk float64
+}
+
+func (e Exposure) Add(other Exposure) Exposure {
+	e.Delta += other.Delta
+	e.Gamma += other.Gamma
+	e.Theta += other.Theta
+	e.Vega += other.Vega
+	e.Quantity += other.Quantity
+	e.Notional += other.Notional
+	e.MarginAtRisk += other.MarginAtRisk
+	return e
+}
+
+// ExposureLike is implemented by executors that can value their open
+// positions. GetExposures returns nothing when out of a trade.
+type ExposureLike interface {
+	GetExposures() ([]Exposure, error)
+}
+
+// Portfolio aggregates the exposures of several traders so risk can be
+// seen across them. It is safe for concurrent use.
+type Portfolio struct {
+	mu        sync.Mutex
+	exposures map[string][]Exposure
+	updated   map[string]time.Time
+	stale     map[string]bool
+}
+
+func NewPortfolio() *Portfolio {
+	return &Portfolio{
+		exposures: make(map[string][]Exposure),
+		updated:   make(map[string]time.Time),
+		stale:     make(map[string]bool),
+	}
+}
+
+// Update replaces the exposures of trader id.
+func (p *Portfolio) Update(id string, exposures []Exposure, at time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(exposures) == 0 {
+		delete(p.exposures, id)
+	} else {
+		p.exposures[id] = exposures
+	}
+	p.updated[id] = at
+	delete(p.stale, id)
+}
+
+// MarkStale records that the exposures of trader id could not be valued.
+// They are kept, as the positions are still held, until the next Update.
+func (p *Portfolio) MarkStale(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.updated[id]; ok {
+		p.stale[id] = true
+	}
+}
+
+// Remove drops trader id from the portfolio.
+func (p *Portfolio) Remove(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.exposures, id)
+	delete(p.updated, id)
+	delete(p.stale, id)
+}
+
+// PortfolioSnapshot is the net exposure of a Portfolio at one point.
+type PortfolioSnapshot struct {
+	// Underlyings are sorted by Underlying.
+	Underlyings []Exposure
+	Total       Exposure
+	// Traders are the IDs holding an exposure.
+	Traders []string
+	// Updated is the last time any trader was refreshed.
+	Updated time.Time
+	// TraderUpdated is when each trader was last refreshed, by ID.
+	TraderUpdated map[string]time.Time
+	// Stale are the IDs whose last refresh failed, holding the exposure of
+	// their TraderUpdated.
+	Stale []string
+}
+
+// Exposure returns the net exposure on underlying.
+func (s PortfolioSnapshot) Exposure(underlying string) Exposure {
+	for _, exposure := range s.Underlyings {
+		if exposure.Underlying == underlying {
+			return exposure
+		}
+	}
+	return Exposure{Underlying: underlying}
+}
+
+// Snapshot nets the exposures of every trader per underlying and in
+// total.
+func (p *Portfolio) Snapshot() PortfolioSnapshot {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var snapshot PortfolioSnapshot
+	byUnderlying := make(map[string]Exposure)
+	for id, exposures := range p.exposures {
+		snapshot.Traders = append(snapshot.Traders, id)
+		for _, exposure := range exposures {
+			net := byUnderlying[exposure.Underlying].Add(exposure)
+			net.Underlying = exposure.Underlying
+			byUnderlying[exposure.Underlying] = net
+			snapshot.Total = snapshot.Total.Add(exposure)
+		}
+	}
+	for _, exposure := range byUnderlying {
+		snapshot.Underlyings = append(snapshot.Underlyings, exposure)
+	}
+	sort.Slice(snapshot.Underlyings, func(i, j int) bool {
+		return snapshot.Underlyings[i].Underlying < snapshot.Underlyings[j].Underlying
+	})
+	sort.Strings(snapshot.Traders)
+	snapshot.TraderUpdated = make(map[string]time.Time, len(p.updated))
+	for id, updated := range p.updated {
+		snapshot.TraderUpdated[id] = updated
+		if updated.After(snapshot.Updated) {
+			snapshot.Updated = updated
+		}
+	}
+	for id := range p.stale {
+		snapshot.Stale = append(snapshot.Stale, id)
+	}
+	sort.Strings(snapshot.Stale)
+	return snapshot
+}
@@ -0,0 +1,159 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPortfolioSnapshot(t *testing.T) {
+	portfolio := NewPortfolio()
+	first := time.Date(2023, 5, 16, 10, 0, 0, 0, time.UTC)
+	portfolio.Update("nifty-calendar", []Exposure{
+		{Underlying: "NIFTY", Delta: 25, Theta: 300, Vega: -120, Notional: 2750000, MarginAtRisk: 200000},
+	}, first)
+	portfolio.Update("banknifty", []Exposure{
+		{Underlying: "BANKNIFTY", Delta: -10, Vega: 50, Notional: 1300000, MarginAtRisk: 90000},
+	}, first.Add(time.Minute))
+	hedge := []Exposure{
+		{Underlying: "NIFTY", Delta: -25, Gamma: 0.5, Theta: -100, Vega: 80, Notional: 1375000, MarginAtRisk: 10000},
+	}
+	portfolio.Update("nifty-hedge", hedge, first)
+	portfolio.Update("flat", nil, first)
+
+	snapshot := portfolio.Snapshot()
+	if len(snapshot.Underlyings) != 2 || snapshot.Underlyings[0].Underlying != "BANKNIFTY" {
+		t.Fatalf("expected BANKNIFTY and NIFTY, got %+v", snapshot.Underlyings)
+	}
+	nifty := snapshot.Underlyings[1]
+	want := Exposure{Underlying: "NIFTY", Gamma: 0.5, Theta: 200, Vega: -40, Notional: 4125000, MarginAtRisk: 210000}
+	if nifty != want {
+		t.Errorf("expected %+v, got %+v", want, nifty)
+	}
+	if snapshot.Exposure("NIFTY") != want || snapshot.Exposure("FINNIFTY") != (Exposure{Underlying: "FINNIFTY"}) {
+		t.Errorf("unexpected exposure lookup")
+	}
+	if snapshot.Total.Delta != -10 || snapshot.Total.Vega != 10 || snapshot.Total.MarginAtRisk != 300000 {
+		t.Errorf("unexpected total %+v", snapshot.Total)
+	}
+	if len(snapshot.Traders) != 3 {
+		t.Errorf("expected the flat trader to be left out, got %v", snapshot.Traders)
+	}
+	if !snapshot.Updated.Equal(first.Add(time.Minute)) {
+		t.Errorf("expected the last update, got %v", snapshot.Updated)
+	}
+	if updated := snapshot.TraderUpdated["nifty-hedge"]; !updated.Equal(first) {
+		t.Errorf("expected the update of nifty-hedge, got %v", updated)
+	}
+
+	portfolio.MarkStale("nifty-hedge")
+	portfolio.MarkStale("unknown")
+	snapshot = portfolio.Snapshot()
+	if len(snapshot.Stale) != 1 || snapshot.Stale[0] != "nifty-hedge" || snapshot.Exposure("NIFTY") != want {
+		t.Errorf("expected nifty-hedge to be stale with its exposure kept, got %v", snapshot.Stale)
+	}
+	portfolio.Update("nifty-hedge", hedge, first.Add(2*time.Minute))
+	if stale := portfolio.Snapshot().Stale; len(stale) != 0 {
+		t.Errorf("expected an update to clear stale, got %v", stale)
+	}
+
+	portfolio.Remove("banknifty")
+	if total := portfolio.Snapshot().Total; total.Delta != 0 {
+		t.Errorf("expected the NIFTY deltas to net out, got %v", total.Delta)
+	}
+}
+
+type exposureExecutor struct {
+	*fakeExecutor
+}
+
+func (e exposureExecutor) GetExposures() ([]Exposure, error) {
+	if !e.InTrade() {
+		return nil, nil
+	}
+	return []Exposure{{Underlying: "NIFTY", Delta: 50}}, nil
+}
+
+func TestRunRefreshesPortfolio(t *testing.T) {
+	exec := exposureExecutor{fakeExecutor: newFakeExecutor()}
+	ticks := make(chan float64)
+	portfolio := NewPortfolio()
+	trader := Trader{ID: "test", Executor: exec, Ticks: ticks, Portfolio: portfolio}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- trader.Run(ctx) }()
+
+	deadline := time.After(time.Second)
+	for portfolio.Snapshot().Total.Delta != 50 {
+		select {
+		case <-deadline:
+			t.Fatal("timeout waiting for the entry exposure")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	exec.setEntry(false)
+	ticks <- 90
+	for portfolio.Snapshot().Total.Delta != 0 {
+		select {
+		case <-deadline:
+			t.Fatal("timeout waiting for the exposure to clear on exit")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	cancel()
+	<-done
+	if traders := portfolio.Snapshot().Traders; len(traders) != 0 {
+		t.Fatalf("expected the stopped trader to be removed, got %v", traders)
+	}
+}
+
+// failingExposureExecutor fails to value its positions once fail is
+// closed.
+type failingExposureExecutor struct {
+	exposureExecutor
+	fail chan struct{}
+}
+
+func (e failingExposureExecutor) GetExposures() ([]Exposure, error) {
+	select {
+	case <-e.fail:
+		return nil, errors.New("no quote")
+	default:
+		return e.exposureExecutor.GetExposures()
+	}
+}
+
+func TestRunMarksFailedValuationStale(t *testing.T) {
+	exec := failingExposureExecutor{exposureExecutor{newFakeExecutor()}, make(chan struct{})}
+	ticks := make(chan float64)
+	portfolio := NewPortfolio()
+	trader := Trader{ID: "test", Executor: exec, Ticks: ticks, Portfolio: portfolio}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go trader.Run(ctx)
+
+	deadline := time.After(time.Second)
+	for portfolio.Snapshot().Total.Delta != 50 {
+		select {
+		case <-deadline:
+			t.Fatal("timeout waiting for the entry exposure")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	close(exec.fail)
+	ticks <- 200
+	for len(portfolio.Snapshot().Stale) == 0 {
+		select {
+		case <-deadline:
+			t.Fatal("timeout waiting for the exposure to be marked stale")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	snapshot := portfolio.Snapshot()
+	if snapshot.Stale[0] != "test" || snapshot.Total.Delta != 50 {
+		t.Fatalf("expected the last exposure of test to be kept as stale, got %+v", snapshot)
+	}
+}
@@ -0,0 +1,192 @@
+// Package pricing values European options and their Greeks, on an index
+// or stock with Black-Scholes or on a future with Black-76.
+package pricing
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/dragonzurfer/trader/executor"
+)
+
+type Model string
+
+const (
+	// BlackScholes prices off the spot. It is the default.
+	BlackScholes Model = "black_scholes"
+	// Black76 prices off the futures price of the same expiry.
+	Black76 Model = "black76"
+)
+
+func (m Model) Validate() error {
+	switch m {
+	case "", BlackScholes, Black76:
+		return nil
+	}
+	return fmt.Errorf("unknown pricing model %q", m)
+}
+
+// carry is the cost of carry of the underlying: the rate for a spot
+// underlying, nothing for a future.
+func (m Model) carry(rate float64) float64 {
+	if m == Black76 {
+		return 0
+	}
+	return rate
+}
+
+// Contract is what an option is valued on.
+type Contract struct {
+	Type   executor.OptionType
+	Strike float64
+	// Underlying is the spot price for BlackScholes and the futures price
+	// for Black76.
+	Underlying float64
+	Years      float64
+	Rate       float64
+}
+
+// Greeks are per unit of the option.
+type Greeks struct {
+	Delta float64
+	Gamma float64
+	// Theta is the change in value over one calendar day.
+	Theta float64
+	// Vega is the change in value for one point of volatility.
+	Vega float64
+}
+
+func (g Greeks) Add(other Greeks) Greeks {
+	return Greeks{
+		Delta: g.Delta + other.Delta,
+		Gamma: g.Gamma + other.Gamma,
+		Theta: g.Theta + other.Theta,
+		Vega:  g.Vega + other.Vega,
+	}
+}
+
+func (g Greeks) Scale(factor float64) Greeks {
+	return Greeks{
+		Delta: g.Delta * factor,
+		Gamma: g.Gamma * factor,
+		Theta: g.Theta * factor,
+		Vega:  g.Vega * factor,
+	}
+}
+
+const yearDuration = 365 * 24 * time.Hour
+
+// YearsToExpiry is the time left until the 15:30 close of expiry's day,
+// unless expiry already carries a time of day.
+func YearsToExpiry(now, expiry time.Time) float64 {
+	if expiry.Hour() == 0 && expiry.Minute() == 0 {
+		expiry = expiry.Add(15*time.Hour + 30*time.Minute)
+	}
+	return float64(expiry.Sub(now)) / float64(yearDuration)
+}
+
+func normCDF(x float64) float64 {
+	return 0.5 * math.Erfc(-x/math.Sqrt2)
+}
+
+func normPDF(x float64) float64 {
+	return math.Exp(-x*x/2) / math.Sqrt(2*math.Pi)
+}
+
+// isPriceable reports whether there is time value left to model.
+func (c Contract) isPriceable(vol float64) bool {
+	return c.Years > 0 && vol > 0 && c.Underlying > 0 && c.Strike > 0
+}
+
+func (c Contract) intrinsic() float64 {
+	if c.Type == executor.PutOption {
+		return math.Max(c.Strike-c.Underlying, 0)
+	}
+	return math.Max(c.Underlying-c.Strike, 0)
+}
+
+func (m Model) d1d2(c Contract, vol float64) (float64, float64) {
+	volSqrtYears := vol * math.Sqrt(c.Years)
+	d1 := (math.Log(c.Underlying/c.Strike) + (m.carry(c.Rate)+vol*vol/2)*c.Years) / volSqrtYears
+	return d1, d1 - volSqrtYears
+}
+
+// Price is the value of the option at volatility vol, its intrinsic value
+// once expired.
+func (m Model) Price(c Contract, vol float64) float64 {
+	if !c.isPriceable(vol) {
+		return c.intrinsic()
+	}
+	d1, d2 := m.d1d2(c, vol)
+	underlying := c.Underlying * math.Exp((m.carry(c.Rate)-c.Rate)*c.Years)
+	strike := c.Strike * math.Exp(-c.Rate*c.Years)
+	if c.Type == executor.PutOption {
+		return strike*normCDF(-d2) - underlying*normCDF(-d1)
+	}
+	return underlying*normCDF(d1) - strike*normCDF(d2)
+}
+
+// Greeks of the option at volatility vol. An expired option only has the
+// delta of its intrinsic value.
+func (m Model) Greeks(c Contract, vol float64) Greeks {
+	if !c.isPriceable(vol) {
+		switch {
+		case c.Type == executor.PutOption && c.Underlying < c.Strike:
+			return Greeks{Delta: -1}
+		case c.Type == executor.CallOption && c.Underlying > c.Strike:
+			return Greeks{Delta: 1}
+		}
+		return Greeks{}
+	}
+	d1, d2 := m.d1d2(c, vol)
+	carry := m.carry(c.Rate)
+	carryDiscount := math.Exp((carry - c.Rate) * c.Years)
+	strikeDiscount := math.Exp(-c.Rate * c.Years)
+	sqrtYears := math.Sqrt(c.Years)
+
+	greeks := Greeks{
+		Gamma: carryDiscount * normPDF(d1) / (c.Underlying * vol * sqrtYears),
+		Vega:  c.Underlying * carryDiscount * normPDF(d1) * sqrtYears / 100,
+	}
+	decay := -c.Underlying * carryDiscount * normPDF(d1) * vol / (2 * sqrtYears)
+	var theta float64
+	if c.Type == executor.PutOption {
+		greeks.Delta = carryDiscount * (normCDF(d1) - 1)
+		theta = decay + (carry-c.Rate)*c.Underlying*carryDiscount*normCDF(-d1) + c.Rate*c.Strike*strikeDiscount*normCDF(-d2)
+	} else {
+		greeks.Delta = carryDiscount * normCDF(d1)
+		theta = decay - (carry-c.Rate)*c.Underlying*carryDiscount*normCDF(d1) - c.Rate*c.Strike*strikeDiscount*normCDF(d2)
+	}
+	greeks.Theta = theta / 365
+	return greeks
+}
+
+// ImpliedVolatility finds the volatility at which Price matches price by
+// bisection.
+func (m Model) ImpliedVolatility(c Contract, price float64) (float64, error) {
+	if c.Years <= 0 {
+		return 0, errors.New("option has expired")
+	}
+	low, high := 1e-4, 5.0
+	if price < m.Price(c, low) || price > m.Price(c, high) {
+		return 0, fmt.Errorf("price %v is outside the range of %v prices", price, m.name())
+	}
+	for i := 0; i < 100 && high-low > 1e-6; i++ {
+		mid := (low + high) / 2
+		if m.Price(c, mid) < price {
+			low = mid
+		} else {
+			high = mid
+		}
+	}
+	return (low + high) / 2, nil
+}
+
+func (m Model) name() Model {
+	if m == "" {
+		return BlackScholes
+	}
+	return m
+}
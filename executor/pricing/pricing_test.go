@@ -0,0 +1,103 @@
+package pricing
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/dragonzurfer/trader/executor"
+)
+
+func near(t *testing.T, name string, want, got, tolerance float64) {
+	t.Helper()
+	if math.Abs(want-got) > tolerance {
+		t.Errorf("%v: expected %v, got %v", name, want, got)
+	}
+}
+
+func TestPrice(t *testing.T) {
+	call := Contract{Type: executor.CallOption, Strike: 100, Underlying: 100, Years: 1, Rate: 0.05}
+	put := call
+	put.Type = executor.PutOption
+
+	near(t, "black-scholes call", 10.4506, BlackScholes.Price(call, 0.2), 1e-4)
+	near(t, "black-scholes put", 5.5735, BlackScholes.Price(put, 0.2), 1e-4)
+	// an at the money forward prices calls and puts alike
+	near(t, "black-76 call", 7.5771, Black76.Price(call, 0.2), 1e-4)
+	near(t, "black-76 put", 7.5771, Black76.Price(put, 0.2), 1e-4)
+	near(t, "default model", BlackScholes.Price(call, 0.2), Model("").Price(call, 0.2), 0)
+
+	expired := call
+	expired.Years, expired.Underlying = 0, 104
+	near(t, "expired call", 4, BlackScholes.Price(expired, 0.2), 0)
+	if greeks := BlackScholes.Greeks(expired, 0.2); greeks != (Greeks{Delta: 1}) {
+		t.Errorf("expected an expired in the money call to have a delta of 1, got %+v", greeks)
+	}
+}
+
+// TestGreeks checks the Greeks against finite differences of Price.
+func TestGreeks(t *testing.T) {
+	const vol, h = 0.18, 1e-3
+	for _, model := range []Model{BlackScholes, Black76} {
+		for _, optionType := range []executor.OptionType{executor.CallOption, executor.PutOption} {
+			c := Contract{Type: optionType, Strike: 18300, Underlying: 18337, Years: 20.0 / 365, Rate: 0.07}
+			greeks := model.Greeks(c, vol)
+			name := string(model) + " " + string(optionType)
+
+			up, down := c, c
+			up.Underlying += h
+			down.Underlying -= h
+			near(t, name+" delta", (model.Price(up, vol)-model.Price(down, vol))/(2*h), greeks.Delta, 1e-6)
+
+			up.Underlying, down.Underlying = c.Underlying+1, c.Underlying-1
+			near(t, name+" gamma", model.Price(up, vol)-2*model.Price(c, vol)+model.Price(down, vol), greeks.Gamma, 1e-6)
+
+			near(t, name+" vega", (model.Price(c, vol+h)-model.Price(c, vol-h))/(2*h)/100, greeks.Vega, 1e-4)
+
+			later := c
+			later.Years -= h / 365
+			near(t, name+" theta", (model.Price(later, vol)-model.Price(c, vol))/h, greeks.Theta, 1e-2)
+		}
+	}
+}
+
+func TestImpliedVolatility(t *testing.T) {
+	for _, model := range []Model{BlackScholes, Black76} {
+		c := Contract{Type: executor.PutOption, Strike: 18200, Underlying: 18337, Years: 9.0 / 365, Rate: 0.07}
+		vol, err := model.ImpliedVolatility(c, model.Price(c, 0.135))
+		if err != nil {
+			t.Fatalf("%v: ImpliedVolatility() failed: %v", model, err)
+		}
+		near(t, string(model), 0.135, vol, 1e-5)
+	}
+
+	c := Contract{Type: executor.CallOption, Strike: 100, Underlying: 120, Years: 0.1}
+	if _, err := BlackScholes.ImpliedVolatility(c, 10); err == nil {
+		t.Error("expected a price below intrinsic value to fail")
+	}
+	c.Years = 0
+	if _, err := BlackScholes.ImpliedVolatility(c, 20); err == nil {
+		t.Error("expected an expired option to fail")
+	}
+}
+
+func TestYearsToExpiry(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2023, 5, 25, 9, 30, 0, 0, loc)
+	near(t, "expiry date", 6.0/24/365, YearsToExpiry(now, time.Date(2023, 5, 25, 0, 0, 0, 0, loc)), 1e-12)
+	near(t, "expiry time", 5.0/24/365, YearsToExpiry(now, time.Date(2023, 5, 25, 14, 30, 0, 0, loc)), 1e-12)
+}
+
+func TestModelValidate(t *testing.T) {
+	for _, model := range []Model{"", BlackScholes, Black76} {
+		if err := model.Validate(); err != nil {
+			t.Errorf("%q: %v", model, err)
+		}
+	}
+	if err := Model("binomial").Validate(); err == nil {
+		t.Error("expected an unknown model to fail")
+	}
+}
@@ -0,0 +1,330 @@
+// Package replay serves recorded market data through executor.BrokerLike as
+// of a simulated clock, so strategies can be run offline against a past
+// session.
+//
+// A replay directory is laid out as follows, where every file may be stored
+// as .json, .jsonl (one record per line, as Recorder appends them) or .csv
+// and symbols are made file system safe by SymbolFileName:
+//
+//	candles/<symbol>/<timeframe>  candles, timestamped at candle open
+//	ltp/<symbol>                  ticks (time, price)
+//	market_depth/<symbol>         underlying depth snapshots
+//	expiries/<symbol>             option expiries, optionally per time
+//	depth                         option depth snapshots
+//	option_candles                option candles per strike, expiry and type
+//
+// Only files needed by the calls made have to exist.
+package replay
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/dragonzurfer/trader/executor"
+)
+
+// ltpTimeFrames are the candle time frames GetLTP falls back to, finest
+// first, when no ticks were recorded for a symbol.
+var ltpTimeFrames = []executor.TimeFrame{
+	executor.Minute,
+	executor.Minute5,
+	executor.Minute15,
+	executor.Minute30,
+	executor.Hour,
+}
+
+type ReplayBroker struct {
+	Dir string
+	now func() time.Time
+
+	mu            sync.Mutex
+	candles       map[string][]Candle
+	ticks         map[string][]Tick
+	marketDepths  map[string][]DepthSnapshot
+	expiries      map[string][]ExpirySnapshot
+	depths        []DepthSnapshot
+	depthsLoaded  bool
+	optionCandles []OptionCandles
+	optionLoaded  bool
+	orders        map[string]*replayOrder
+	nextOrderID   int
+}
+
+// NewReplayBroker serves the recordings in dir as of now(). Files are read
+// lazily on first use and kept in memory.
+func NewReplayBroker(dir string, now func() time.Time) *ReplayBroker {
+	return &ReplayBroker{
+		Dir:          dir,
+		now:          now,
+		candles:      make(map[string][]Candle),
+		ticks:        make(map[string][]Tick),
+		marketDepths: make(map[string][]DepthSnapshot),
+		expiries:     make(map[string][]ExpirySnapshot),
+		orders:       make(map[string]*replayOrder),
+	}
+}
+
+func (b *ReplayBroker) SetCredentialsFilePath(string) {}
+
+// GetLTP returns the last tick recorded at or before the clock, or failing
+// that the close of the last completed candle of the finest recorded time
+// frame.
+func (b *ReplayBroker) GetLTP(symbol string) (float64, error) {
+	now := b.now()
+	ticks, err := b.loadTicks(symbol)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return 0, err
+	}
+	if i := lastAtOrBefore(len(ticks), func(i int) time.Time { return ticks[i].Time }, now); i >= 0 {
+		return ticks[i].Price, nil
+	}
+
+	for _, tf := range ltpTimeFrames {
+		candles, err := b.loadCandles(symbol, tf)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		completed := completedCandles(candles, tf, time.Time{}, now)
+		if len(completed) > 0 {
+			return completed[len(completed)-1].GetClose(), nil
+		}
+	}
+	return 0, fmt.Errorf("no price recorded for %v by %v", symbol, now)
+}
+
+func (b *ReplayBroker) GetMarketDepth(symbol string) (executor.BidAskLike, error) {
+	now := b.now()
+	depths, err := b.loadMarketDepths(symbol)
+	if err != nil {
+		return nil, fmt.Errorf("GetMarketDepth(): %w", err)
+	}
+	i := lastAtOrBefore(len(depths), func(i int) time.Time { return depths[i].Time }, now)
+	if i < 0 {
+		return nil, fmt.Errorf("no depth recorded for %v by %v", symbol, now)
+	}
+	return depths[i].BidAsk, nil
+}
+
+// GetCandles returns the candles opened at or after from that had completed
+// by to, never looking past the clock. Daily candles are matched by date.
+func (b *ReplayBroker) GetCandles(symbol string, from, to time.Time, tf executor.TimeFrame) ([]executor.CandleLike, error) {
+	candles, err := b.loadCandles(symbol, tf)
+	if err != nil {
+		return nil, fmt.Errorf("GetCandles(): %w", err)
+	}
+	if tf == executor.Day {
+		from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location())
+		to = time.Date(to.Year(), to.Month(), to.Day()+1, 0, 0, 0, 0, to.Location())
+	}
+	if now := b.now(); to.After(now) {
+		to = now
+	}
+	return completedCandles(candles, tf, from, to), nil
+}
+
+// GetOptionExpiries returns the latest expiry list recorded at or before
+// the clock.
+func (b *ReplayBroker) GetOptionExpiries(symbol string) ([]executor.Expiry, error) {
+	now := b.now()
+	snapshots, err := b.loadExpiries(symbol)
+	if err != nil {
+		return nil, fmt.Errorf("GetOptionExpiries(): %w", err)
+	}
+	i := lastAtOrBefore(len(snapshots), func(i int) time.Time { return snapshots[i].Time }, now)
+	if i < 0 {
+		return nil, fmt.Errorf("no expiries recorded for %v by %v", symbol, now)
+	}
+	return snapshots[i].Expiries, nil
+}
+
+// GetMarketDepthOption returns the latest snapshot recorded for the option
+// at or before the clock.
+func (b *ReplayBroker) GetMarketDepthOption(strike float64, expiry time.Time, optionType executor.OptionType) (executor.BidAskLike, error) {
+	now := b.now()
+	depth, err := b.optionDepth(strike, expiry, optionType, now)
+	if err != nil {
+		return nil, err
+	}
+	return depth, nil
+}
+
+func (b *ReplayBroker) optionDepth(strike float64, expiry time.Time, optionType executor.OptionType, now time.Time) (BidAsk, error) {
+	depths, err := b.loadDepths()
+	if err != nil {
+		return BidAsk{}, fmt.Errorf("GetMarketDepthOption(): %w", err)
+	}
+	found := -1
+	for i, snapshot := range depths {
+		if snapshot.Time.After(now) {
+			break
+		}
+		if !snapshot.Expiry.Equal(expiry) {
+			continue
+		}
+		if snapshot.Strike != 0 && snapshot.Strike != strike {
+			continue
+		}
+		if snapshot.Type != "" && snapshot.Type != optionType {
+			continue
+		}
+		found = i
+	}
+	if found < 0 {
+		return BidAsk{}, fmt.Errorf("no depth recorded for %v %v %v by %v", strike, optionType, expiry, now)
+	}
+	return depths[found].BidAsk, nil
+}
+
+func (b *ReplayBroker) GetCandlesOption(strike float64, expiry time.Time, optionType executor.OptionType, from, to time.Time) ([]executor.CandleLike, error) {
+	all, err := b.loadOptionCandles()
+	if err != nil {
+		return nil, fmt.Errorf("GetCandlesOption(): %w", err)
+	}
+	if now := b.now(); to.After(now) {
+		to = now
+	}
+	for _, option := range all {
+		if option.Strike == strike && option.Expiry.Equal(expiry) && option.Type == optionType {
+			return completedCandles(option.Candles, optionCandleTimeFrame(option.Candles), from, to), nil
+		}
+	}
+	return nil, fmt.Errorf("no candles recorded for %v %v %v", strike, optionType, expiry)
+}
+
+// RecordedCandles returns every candle recorded for symbol regardless of
+// the clock, for drivers that step the simulation through them.
+func (b *ReplayBroker) RecordedCandles(symbol string, tf executor.TimeFrame) ([]Candle, error) {
+	return b.loadCandles(symbol, tf)
+}
+
+// optionCandleTimeFrame infers the time frame of recorded option candles
+// from their spacing, assuming 5 minutes when it cannot tell.
+func optionCandleTimeFrame(candles []Candle) executor.TimeFrame {
+	if len(candles) > 1 {
+		gap := candles[1].Time.Sub(candles[0].Time)
+		for _, tf := range ltpTimeFrames {
+			if tf.Duration() == gap {
+				return tf
+			}
+		}
+	}
+	return executor.Minute5
+}
+
+// completedCandles returns the candles opened at or after from that had
+// closed by to.
+func completedCandles(candles []Candle, tf executor.TimeFrame, from, to time.Time) []executor.CandleLike {
+	var completed []executor.CandleLike
+	for _, candle := range candles {
+		if candle.Time.Before(from) {
+			continue
+		}
+		if candle.Time.Add(tf.Duration()).After(to) {
+			break
+		}
+		completed = append(completed, candle)
+	}
+	return completed
+}
+
+// lastAtOrBefore returns the index of the last of n time ordered records at
+// or before now, or -1.
+func lastAtOrBefore(n int, at func(int) time.Time, now time.Time) int {
+	found := -1
+	for i := 0; i < n; i++ {
+		if at(i).After(now) {
+			break
+		}
+		found = i
+	}
+	return found
+}
+
+func (b *ReplayBroker) loadCandles(symbol string, tf executor.TimeFrame) ([]Candle, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	key := symbol + "|" + string(tf)
+	if candles, ok := b.candles[key]; ok {
+		return candles, nil
+	}
+	candles, err := loadCandles(CandlesPath(b.Dir, symbol, tf))
+	if err != nil {
+		return nil, err
+	}
+	b.candles[key] = candles
+	return candles, nil
+}
+
+func (b *ReplayBroker) loadTicks(symbol string) ([]Tick, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ticks, ok := b.ticks[symbol]; ok {
+		return ticks, nil
+	}
+	ticks, err := loadTicks(TicksPath(b.Dir, symbol))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	b.ticks[symbol] = ticks
+	return ticks, nil
+}
+
+func (b *ReplayBroker) loadMarketDepths(symbol string) ([]DepthSnapshot, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if depths, ok := b.marketDepths[symbol]; ok {
+		return depths, nil
+	}
+	depths, err := loadDepths(MarketDepthPath(b.Dir, symbol))
+	if err != nil {
+		return nil, err
+	}
+	b.marketDepths[symbol] = depths
+	return depths, nil
+}
+
+func (b *ReplayBroker) loadExpiries(symbol string) ([]ExpirySnapshot, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if expiries, ok := b.expiries[symbol]; ok {
+		return expiries, nil
+	}
+	expiries, err := loadExpiries(ExpiriesPath(b.Dir, symbol))
+	if err != nil {
+		return nil, err
+	}
+	b.expiries[symbol] = expiries
+	return expiries, nil
+}
+
+func (b *ReplayBroker) loadDepths() ([]DepthSnapshot, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.depthsLoaded {
+		depths, err := loadDepths(DepthPath(b.Dir))
+		if err != nil {
+			return nil, err
+		}
+		b.depths, b.depthsLoaded = depths, true
+	}
+	return b.depths, nil
+}
+
+func (b *ReplayBroker) loadOptionCandles() ([]OptionCandles, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.optionLoaded {
+		optionCandles, err := loadOptionCandles(OptionCandlesPath(b.Dir))
+		if err != nil {
+			return nil, err
+		}
+		b.optionCandles, b.optionLoaded = optionCandles, true
+	}
+	return b.optionCandles, nil
+}
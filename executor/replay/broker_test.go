@@ -0,0 +1,166 @@
+package replay
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dragonzurfer/trader/executor"
+)
+
+const symbol = "NSE:NIFTY50-INDEX"
+
+var ist = time.FixedZone("IST", 5*60*60+30*60)
+
+func at(hour, minute int) time.Time {
+	return time.Date(2023, 5, 16, hour, minute, 0, 0, ist)
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestBroker(t *testing.T) (*ReplayBroker, *Clock) {
+	dir := t.TempDir()
+	writeFile(t, CandlesPath(dir, symbol, executor.Minute5)+".csv", `time,open,high,low,close,volume,oi
+2023-05-16T09:15:00+05:30,18300,18320,18290,18310,0,0
+2023-05-16T09:20:00+05:30,18310,18330,18305,18325,0,0
+2023-05-16T09:25:00+05:30,18325,18340,18320,18335,0,0
+`)
+	writeFile(t, CandlesPath(dir, symbol, executor.Day)+".json", `[
+ {"time": "2023-05-15T00:00:00+05:30", "open": 18200, "high": 18400, "low": 18150, "close": 18300},
+ {"time": "2023-05-16T00:00:00+05:30", "open": 18300, "high": 18500, "low": 18250, "close": 18450}
+]`)
+	writeFile(t, ExpiriesPath(dir, symbol)+".json", `[
+ {"ExpiryDate": "2023-05-25T00:00:00+05:30"},
+ {"ExpiryDate": "2023-06-01T00:00:00+05:30"}
+]`)
+	writeFile(t, DepthPath(dir)+".csv", `time,strike,expiry,type,side,price,quantity,num_of_orders
+2023-05-16T09:15:00+05:30,18300,2023-05-25T00:00:00+05:30,CE,bid,100,50,1
+2023-05-16T09:15:00+05:30,18300,2023-05-25T00:00:00+05:30,CE,ask,101,50,1
+2023-05-16T09:30:00+05:30,18300,2023-05-25T00:00:00+05:30,CE,bid,110,50,1
+2023-05-16T09:30:00+05:30,18300,2023-05-25T00:00:00+05:30,CE,ask,111,50,1
+`)
+	clock := NewClock(at(9, 15))
+	return NewReplayBroker(dir, clock.Now), clock
+}
+
+func TestGetCandlesFollowsClock(t *testing.T) {
+	broker, clock := newTestBroker(t)
+	clock.Set(at(9, 27))
+
+	candles, err := broker.GetCandles(symbol, at(9, 15), at(15, 30), executor.Minute5)
+	if err != nil {
+		t.Fatalf("GetCandles() failed: %v", err)
+	}
+	if len(candles) != 2 {
+		t.Fatalf("expected 2 completed candles, got %v", len(candles))
+	}
+
+	daily, err := broker.GetCandles(symbol, at(0, 0).AddDate(0, 0, -1), at(9, 27), executor.Day)
+	if err != nil {
+		t.Fatalf("GetCandles() failed: %v", err)
+	}
+	if len(daily) != 1 || daily[0].GetClose() != 18300 {
+		t.Errorf("expected only the previous day's candle, got %v", daily)
+	}
+
+	clock.Advance(5 * time.Minute)
+	candles, _ = broker.GetCandles(symbol, at(9, 15), at(15, 30), executor.Minute5)
+	if len(candles) != 3 {
+		t.Errorf("expected 3 completed candles after advancing, got %v", len(candles))
+	}
+}
+
+func TestGetLTPFallsBackToCandles(t *testing.T) {
+	broker, clock := newTestBroker(t)
+	if _, err := broker.GetLTP(symbol); err == nil {
+		t.Errorf("expected an error before the first candle completes")
+	}
+
+	clock.Set(at(9, 26))
+	ltp, err := broker.GetLTP(symbol)
+	if err != nil || ltp != 18325 {
+		t.Errorf("expected 18325, got %v, %v", ltp, err)
+	}
+
+	writeFile(t, TicksPath(broker.Dir, "NSE:BANKNIFTY-INDEX")+".csv", "time,price\n2023-05-16T09:20:30+05:30,43500.5\n")
+	ltp, err = broker.GetLTP("NSE:BANKNIFTY-INDEX")
+	if err != nil || ltp != 43500.5 {
+		t.Errorf("expected 43500.5, got %v, %v", ltp, err)
+	}
+}
+
+func TestGetMarketDepthOptionFollowsClock(t *testing.T) {
+	broker, clock := newTestBroker(t)
+	expiries, err := broker.GetOptionExpiries(symbol)
+	if err != nil || len(expiries) != 2 {
+		t.Fatalf("expected 2 expiries, got %v, %v", expiries, err)
+	}
+	expiry := expiries[0].ExpiryDate
+
+	depth, err := broker.GetMarketDepthOption(18300, expiry, executor.CallOption)
+	if err != nil {
+		t.Fatalf("GetMarketDepthOption() failed: %v", err)
+	}
+	if bid := depth.GetBids()[0].GetPrice(); bid != 100 {
+		t.Errorf("expected bid 100, got %v", bid)
+	}
+
+	clock.Set(at(9, 45))
+	depth, _ = broker.GetMarketDepthOption(18300, expiry, executor.CallOption)
+	if ask := depth.GetAsks()[0].GetPrice(); ask != 111 {
+		t.Errorf("expected ask 111, got %v", ask)
+	}
+
+	if _, err := broker.GetMarketDepthOption(18300, expiry, executor.PutOption); err == nil {
+		t.Errorf("expected an error for an unrecorded option")
+	}
+}
+
+func TestOrdersMatchAgainstDepth(t *testing.T) {
+	broker, clock := newTestBroker(t)
+	expiry := time.Date(2023, 5, 25, 0, 0, 0, 0, ist)
+	request := executor.OrderRequest{
+		Strike:     18300,
+		Expiry:     expiry,
+		OptionType: executor.CallOption,
+		TradeType:  executor.Sell,
+		OrderType:  executor.LimitOrder,
+		Quantity:   50,
+		LimitPrice: 105,
+	}
+
+	id, err := broker.PlaceOrder(request)
+	if err != nil {
+		t.Fatalf("PlaceOrder() failed: %v", err)
+	}
+	update, _ := broker.GetOrderStatus(id)
+	if update.Status != executor.OrderPending {
+		t.Fatalf("expected a pending order below the limit, got %v", update.Status)
+	}
+
+	clock.Set(at(9, 30))
+	update, _ = broker.GetOrderStatus(id)
+	if update.Status != executor.OrderFilled || update.AveragePrice != 110 || update.FilledQuantity != 50 {
+		t.Errorf("expected a fill of 50 at 110, got %+v", update)
+	}
+	if err := broker.CancelOrder(id); err == nil {
+		t.Errorf("expected cancelling a filled order to fail")
+	}
+
+	request.TradeType, request.OrderType = executor.Buy, executor.MarketOrder
+	id, _ = broker.PlaceOrder(request)
+	update, _ = broker.GetOrderStatus(id)
+	if update.Status != executor.OrderFilled || update.AveragePrice != 111 {
+		t.Errorf("expected a market buy at 111, got %+v", update)
+	}
+}
@@ -0,0 +1,36 @@
+package replay
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is a simulated clock that only moves when it is Set or Advanced.
+// Its Now method can be handed to anything that takes a func() time.Time.
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewClock(start time.Time) *Clock {
+	return &Clock{now: start}
+}
+
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *Clock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+}
+
+func (c *Clock) Advance(d time.Duration) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	return c.now
+}
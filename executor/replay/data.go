@@ -0,0 +1,476 @@
+package replay
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dragonzurfer/trader/executor"
+)
+
+type Candle struct {
+	Time   time.Time `json:"time"`
+	Open   float64   `json:"open"`
+	High   float64   `json:"high"`
+	Low    float64   `json:"low"`
+	Close  float64   `json:"close"`
+	Volume float64   `json:"volume"`
+	OI     float64   `json:"oi"`
+}
+
+func (c Candle) GetTime() time.Time { return c.Time }
+func (c Candle) GetOpen() float64   { return c.Open }
+func (c Candle) GetHigh() float64   { return c.High }
+func (c Candle) GetLow() float64    { return c.Low }
+func (c Candle) GetClose() float64  { return c.Close }
+func (c Candle) GetVolume() float64 { return c.Volume }
+func (c Candle) GetOI() float64     { return c.OI }
+
+func NewCandle(t time.Time, candle executor.CandleLike) Candle {
+	return Candle{
+		Time:   t,
+		Open:   candle.GetOpen(),
+		High:   candle.GetHigh(),
+		Low:    candle.GetLow(),
+		Close:  candle.GetClose(),
+		Volume: candle.GetVolume(),
+		OI:     candle.GetOI(),
+	}
+}
+
+type Tick struct {
+	Time  time.Time `json:"time"`
+	Price float64   `json:"price"`
+}
+
+type MarketDepth struct {
+	Price       float64 `json:"price"`
+	Quantity    int64   `json:"quantity"`
+	NumOfOrders int64   `json:"num_of_orders"`
+}
+
+func (md MarketDepth) GetPrice() float64     { return md.Price }
+func (md MarketDepth) GetQuantity() int64    { return md.Quantity }
+func (md MarketDepth) GetNumOfOrders() int64 { return md.NumOfOrders }
+
+type BidAsk struct {
+	Bids []MarketDepth `json:"bids"`
+	Asks []MarketDepth `json:"asks"`
+}
+
+func (ba BidAsk) GetBids() []executor.MarketDepthLike { return toMarketDepthLike(ba.Bids) }
+func (ba BidAsk) GetAsks() []executor.MarketDepthLike { return toMarketDepthLike(ba.Asks) }
+
+func NewBidAsk(bidAsk executor.BidAskLike) BidAsk {
+	return BidAsk{
+		Bids: fromMarketDepthLike(bidAsk.GetBids()),
+		Asks: fromMarketDepthLike(bidAsk.GetAsks()),
+	}
+}
+
+func toMarketDepthLike(depths []MarketDepth) []executor.MarketDepthLike {
+	depthLikes := make([]executor.MarketDepthLike, len(depths))
+	for i, depth := range depths {
+		depthLikes[i] = depth
+	}
+	return depthLikes
+}
+
+func fromMarketDepthLike(depthLikes []executor.MarketDepthLike) []MarketDepth {
+	depths := make([]MarketDepth, len(depthLikes))
+	for i, depth := range depthLikes {
+		depths[i] = MarketDepth{
+			Price:       depth.GetPrice(),
+			Quantity:    depth.GetQuantity(),
+			NumOfOrders: depth.GetNumOfOrders(),
+		}
+	}
+	return depths
+}
+
+// DepthSnapshot is the option market depth seen at Time. A zero Strike or
+// empty Type matches any strike or option type of the expiry.
+type DepthSnapshot struct {
+	Time   time.Time           `json:"time"`
+	Strike float64             `json:"strike"`
+	Expiry time.Time           `json:"expiry"`
+	Type   executor.OptionType `json:"type"`
+	BidAsk
+}
+
+// OptionCandles are the recorded candles of one option contract.
+type OptionCandles struct {
+	Strike  float64             `json:"strike"`
+	Expiry  time.Time           `json:"expiry"`
+	Type    executor.OptionType `json:"type"`
+	Candles []Candle            `json:"candles"`
+}
+
+// ExpirySnapshot is the expiry list returned at Time.
+type ExpirySnapshot struct {
+	Time     time.Time         `json:"time"`
+	Expiries []executor.Expiry `json:"expiries"`
+}
+
+// SymbolFileName turns a broker symbol such as NSE:NIFTY50-INDEX into a
+// name that is valid on every file system.
+func SymbolFileName(symbol string) string {
+	return strings.NewReplacer(":", "_", "/", "_", "\\", "_").Replace(symbol)
+}
+
+// Paths of the recorded files below a replay directory, without extension.
+// Every file may be stored as .json, .jsonl or .csv.
+func CandlesPath(dir, symbol string, tf executor.TimeFrame) string {
+	return filepath.Join(dir, "candles", SymbolFileName(symbol), string(tf))
+}
+
+func TicksPath(dir, symbol string) string {
+	return filepath.Join(dir, "ltp", SymbolFileName(symbol))
+}
+
+func MarketDepthPath(dir, symbol string) string {
+	return filepath.Join(dir, "market_depth", SymbolFileName(symbol))
+}
+
+func ExpiriesPath(dir, symbol string) string {
+	return filepath.Join(dir, "expiries", SymbolFileName(symbol))
+}
+
+func DepthPath(dir string) string {
+	return filepath.Join(dir, "depth")
+}
+
+func OptionCandlesPath(dir string) string {
+	return filepath.Join(dir, "option_candles")
+}
+
+// readRecords loads the records of path.json followed by those of
+// path.jsonl, or failing both path.csv. parseCSV is called with the header
+// and rows of the CSV file. os.ErrNotExist is returned when no file exists.
+func readRecords(path string, v interface{}, parseCSV func(header map[string]int, rows [][]string) error) error {
+	var records []json.RawMessage
+	found := false
+	data, err := ioutil.ReadFile(path + ".json")
+	if err == nil {
+		if err := json.Unmarshal(data, &records); err != nil {
+			return fmt.Errorf("failed to unmarshal %v.json: %w", path, err)
+		}
+		found = true
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	lines, err := readJSONLines(path + ".jsonl")
+	if err == nil {
+		records = append(records, lines...)
+		found = true
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if found {
+		data, err := json.Marshal(records)
+		if err == nil {
+			err = json.Unmarshal(data, v)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal %v: %w", path, err)
+		}
+		return nil
+	}
+
+	file, err := os.Open(path + ".csv")
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return fmt.Errorf("failed to read %v.csv: %w", path, err)
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	header := make(map[string]int)
+	for i, name := range rows[0] {
+		header[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if err := parseCSV(header, rows[1:]); err != nil {
+		return fmt.Errorf("failed to parse %v.csv: %w", path, err)
+	}
+	return nil
+}
+
+// readJSONLines reads one JSON record per line of path. Lines cut short by
+// an interrupted recording are left out.
+func readJSONLines(path string) ([]json.RawMessage, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var records []json.RawMessage
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 || !json.Valid(line) {
+			continue
+		}
+		records = append(records, json.RawMessage(line))
+	}
+	return records, nil
+}
+
+type csvRow struct {
+	header map[string]int
+	row    []string
+	err    error
+}
+
+func (r *csvRow) str(column string) string {
+	i, ok := r.header[column]
+	if !ok || i >= len(r.row) {
+		return ""
+	}
+	return strings.TrimSpace(r.row[i])
+}
+
+func (r *csvRow) float(column string) float64 {
+	value := r.str(column)
+	if value == "" || r.err != nil {
+		return 0
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		r.err = fmt.Errorf("column %v: %w", column, err)
+	}
+	return f
+}
+
+func (r *csvRow) int(column string) int64 {
+	return int64(r.float(column))
+}
+
+func (r *csvRow) time(column string) time.Time {
+	value := r.str(column)
+	if value == "" || r.err != nil {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		r.err = fmt.Errorf("column %v: %w", column, err)
+	}
+	return t
+}
+
+func loadCandles(path string) ([]Candle, error) {
+	var candles []Candle
+	err := readRecords(path, &candles, func(header map[string]int, rows [][]string) error {
+		for _, row := range rows {
+			r := csvRow{header: header, row: row}
+			candle := Candle{
+				Time:   r.time("time"),
+				Open:   r.float("open"),
+				High:   r.float("high"),
+				Low:    r.float("low"),
+				Close:  r.float("close"),
+				Volume: r.float("volume"),
+				OI:     r.float("oi"),
+			}
+			if r.err != nil {
+				return r.err
+			}
+			candles = append(candles, candle)
+		}
+		return nil
+	})
+	return mergeCandles(nil, candles), err
+}
+
+func loadTicks(path string) ([]Tick, error) {
+	var ticks []Tick
+	err := readRecords(path, &ticks, func(header map[string]int, rows [][]string) error {
+		for _, row := range rows {
+			r := csvRow{header: header, row: row}
+			tick := Tick{Time: r.time("time"), Price: r.float("price")}
+			if r.err != nil {
+				return r.err
+			}
+			ticks = append(ticks, tick)
+		}
+		return nil
+	})
+	sort.SliceStable(ticks, func(i, j int) bool { return ticks[i].Time.Before(ticks[j].Time) })
+	return ticks, err
+}
+
+// loadExpiries accepts either a plain expiry list, which applies at all
+// times, or a list of timestamped snapshots.
+func loadExpiries(path string) ([]ExpirySnapshot, error) {
+	var raw json.RawMessage
+	var snapshots []ExpirySnapshot
+	err := readRecords(path, &raw, func(header map[string]int, rows [][]string) error {
+		byTime := make(map[int64]int)
+		for _, row := range rows {
+			r := csvRow{header: header, row: row}
+			at := r.time("time")
+			expiry := executor.Expiry{ExpiryDate: r.time("expiry")}
+			if r.err != nil {
+				return r.err
+			}
+			i, ok := byTime[at.UnixNano()]
+			if !ok {
+				i = len(snapshots)
+				byTime[at.UnixNano()] = i
+				snapshots = append(snapshots, ExpirySnapshot{Time: at})
+			}
+			snapshots[i].Expiries = append(snapshots[i].Expiries, expiry)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if raw != nil {
+		if snapshots, err = unmarshalExpiries(raw); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal %v.json: %w", path, err)
+		}
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool { return snapshots[i].Time.Before(snapshots[j].Time) })
+	return snapshots, nil
+}
+
+func unmarshalExpiries(raw json.RawMessage) ([]ExpirySnapshot, error) {
+	var probe []map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &probe); err != nil {
+		return nil, err
+	}
+	if len(probe) > 0 {
+		if _, ok := probe[0]["expiries"]; ok {
+			var snapshots []ExpirySnapshot
+			err := json.Unmarshal(raw, &snapshots)
+			return snapshots, err
+		}
+	}
+	var expiries []executor.Expiry
+	if err := json.Unmarshal(raw, &expiries); err != nil {
+		return nil, err
+	}
+	return []ExpirySnapshot{{Expiries: expiries}}, nil
+}
+
+// loadDepths reads depth snapshots. In CSV form every row is one price
+// level with a side column of bid or ask; rows sharing time, strike, expiry
+// and type make up one snapshot.
+func loadDepths(path string) ([]DepthSnapshot, error) {
+	var depths []DepthSnapshot
+	err := readRecords(path, &depths, func(header map[string]int, rows [][]string) error {
+		// Times are keyed as Unix nanoseconds, since each parse of an
+		// offset yields a distinct Location.
+		type key struct {
+			at, expiry int64
+			strike     float64
+			optionType executor.OptionType
+		}
+		index := make(map[key]int)
+		for _, row := range rows {
+			r := csvRow{header: header, row: row}
+			at, expiry := r.time("time"), r.time("expiry")
+			k := key{
+				at:         at.UnixNano(),
+				expiry:     expiry.UnixNano(),
+				strike:     r.float("strike"),
+				optionType: executor.OptionType(r.str("type")),
+			}
+			level := MarketDepth{
+				Price:       r.float("price"),
+				Quantity:    r.int("quantity"),
+				NumOfOrders: r.int("num_of_orders"),
+			}
+			if r.err != nil {
+				return r.err
+			}
+			i, ok := index[k]
+			if !ok {
+				i = len(depths)
+				index[k] = i
+				depths = append(depths, DepthSnapshot{Time: at, Strike: k.strike, Expiry: expiry, Type: k.optionType})
+			}
+			switch strings.ToLower(r.str("side")) {
+			case "bid":
+				depths[i].Bids = append(depths[i].Bids, level)
+			case "ask":
+				depths[i].Asks = append(depths[i].Asks, level)
+			default:
+				return fmt.Errorf("unknown side %q", r.str("side"))
+			}
+		}
+		return nil
+	})
+	sort.SliceStable(depths, func(i, j int) bool { return depths[i].Time.Before(depths[j].Time) })
+	return depths, err
+}
+
+func loadOptionCandles(path string) ([]OptionCandles, error) {
+	var optionCandles []OptionCandles
+	err := readRecords(path, &optionCandles, func(header map[string]int, rows [][]string) error {
+		type key struct {
+			expiry     int64
+			strike     float64
+			optionType executor.OptionType
+		}
+		index := make(map[key]int)
+		for _, row := range rows {
+			r := csvRow{header: header, row: row}
+			expiry := r.time("expiry")
+			k := key{expiry: expiry.UnixNano(), strike: r.float("strike"), optionType: executor.OptionType(r.str("type"))}
+			candle := Candle{
+				Time:   r.time("time"),
+				Open:   r.float("open"),
+				High:   r.float("high"),
+				Low:    r.float("low"),
+				Close:  r.float("close"),
+				Volume: r.float("volume"),
+				OI:     r.float("oi"),
+			}
+			if r.err != nil {
+				return r.err
+			}
+			i, ok := index[k]
+			if !ok {
+				i = len(optionCandles)
+				index[k] = i
+				optionCandles = append(optionCandles, OptionCandles{Strike: k.strike, Expiry: expiry, Type: k.optionType})
+			}
+			optionCandles[i].Candles = append(optionCandles[i].Candles, candle)
+		}
+		return nil
+	})
+	return mergeOptionCandles(optionCandles), err
+}
+
+// mergeOptionCandles merges the records of the same option, as appended
+// by Recorder, with later candles replacing earlier ones of the same open
+// time.
+func mergeOptionCandles(records []OptionCandles) []OptionCandles {
+	var merged []OptionCandles
+	for _, record := range records {
+		found := false
+		for i, option := range merged {
+			if option.Strike == record.Strike && option.Expiry.Equal(record.Expiry) && option.Type == record.Type {
+				merged[i].Candles = mergeCandles(option.Candles, record.Candles)
+				found = true
+				break
+			}
+		}
+		if !found {
+			record.Candles = mergeCandles(nil, record.Candles)
+			merged = append(merged, record)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,147 @@
+package replay
+
+import (
+	"fmt"
+
+	"github.com/dragonzurfer/trader/executor"
+)
+
+// replayOrder is an order matched against the recorded option depth. It
+// fills in full once the touch price satisfies it: market orders at once
+// and limit orders when the opposite side trades at or through the limit.
+type replayOrder struct {
+	request executor.OrderRequest
+	update  executor.OrderUpdate
+}
+
+func (b *ReplayBroker) PlaceOrder(request executor.OrderRequest) (string, error) {
+	if request.Quantity <= 0 {
+		return "", fmt.Errorf("PlaceOrder(): invalid quantity %v", request.Quantity)
+	}
+	if request.OrderType == executor.LimitOrder && request.LimitPrice <= 0 {
+		return "", fmt.Errorf("PlaceOrder(): invalid limit price %v", request.LimitPrice)
+	}
+
+	b.mu.Lock()
+	b.nextOrderID++
+	order := &replayOrder{request: request}
+	order.update = executor.OrderUpdate{
+		OrderID:   fmt.Sprintf("replay-%d", b.nextOrderID),
+		Status:    executor.OrderPending,
+		UpdatedAt: b.now(),
+	}
+	b.orders[order.update.OrderID] = order
+	b.mu.Unlock()
+
+	b.match(order)
+	return order.update.OrderID, nil
+}
+
+func (b *ReplayBroker) ModifyOrder(orderID string, request executor.OrderRequest) error {
+	order, err := b.openOrder(orderID)
+	if err != nil {
+		return fmt.Errorf("ModifyOrder(): %w", err)
+	}
+	b.mu.Lock()
+	order.request.OrderType = request.OrderType
+	order.request.LimitPrice = request.LimitPrice
+	if request.Quantity > 0 {
+		order.request.Quantity = request.Quantity
+	}
+	order.update.UpdatedAt = b.now()
+	b.mu.Unlock()
+
+	b.match(order)
+	return nil
+}
+
+func (b *ReplayBroker) CancelOrder(orderID string) error {
+	order, err := b.openOrder(orderID)
+	if err != nil {
+		return fmt.Errorf("CancelOrder(): %w", err)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	order.update.Status = executor.OrderCancelled
+	order.update.UpdatedAt = b.now()
+	return nil
+}
+
+// GetOrderStatus matches a pending order against the depth recorded by the
+// clock before reporting it.
+func (b *ReplayBroker) GetOrderStatus(orderID string) (executor.OrderUpdate, error) {
+	b.mu.Lock()
+	order, ok := b.orders[orderID]
+	b.mu.Unlock()
+	if !ok {
+		return executor.OrderUpdate{}, fmt.Errorf("GetOrderStatus(): unknown order %v", orderID)
+	}
+	b.match(order)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return order.update, nil
+}
+
+func (b *ReplayBroker) openOrder(orderID string) (*replayOrder, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	order, ok := b.orders[orderID]
+	if !ok {
+		return nil, fmt.Errorf("unknown order %v", orderID)
+	}
+	if order.update.Status.IsTerminal() {
+		return nil, fmt.Errorf("order %v is %v", orderID, order.update.Status)
+	}
+	return order, nil
+}
+
+func (b *ReplayBroker) match(order *replayOrder) {
+	b.mu.Lock()
+	request, status := order.request, order.update.Status
+	b.mu.Unlock()
+	if status.IsTerminal() {
+		return
+	}
+
+	now := b.now()
+	depth, err := b.optionDepth(request.Strike, request.Expiry, request.OptionType, now)
+	if err != nil {
+		return
+	}
+	price, ok := touchPrice(depth, request.TradeType)
+	if !ok {
+		return
+	}
+	if request.OrderType == executor.LimitOrder {
+		if request.TradeType == executor.Buy && price > request.LimitPrice {
+			return
+		}
+		if request.TradeType == executor.Sell && price < request.LimitPrice {
+			return
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if order.update.Status.IsTerminal() {
+		return
+	}
+	order.update.Status = executor.OrderFilled
+	order.update.FilledQuantity = request.Quantity
+	order.update.AveragePrice = price
+	order.update.UpdatedAt = now
+}
+
+// touchPrice is the best price a trade of tradeType can execute at: the
+// best ask for buys and the best bid for sells.
+func touchPrice(depth BidAsk, tradeType executor.TradeType) (float64, bool) {
+	levels := depth.Bids
+	if tradeType == executor.Buy {
+		levels = depth.Asks
+	}
+	if len(levels) == 0 {
+		return 0, false
+	}
+	return levels[0].Price, true
+}
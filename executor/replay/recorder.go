@@ -0,0 +1,341 @@
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dragonzurfer/trader/executor"
+)
+
+// Recorder wraps a broker and saves every market data response it returns
+// under Dir, in the layout ReplayBroker reads, stamped with now(). Orders
+// pass straight through. Recording never fails a call; problems are logged.
+//
+// Responses are appended to .jsonl files one record per line, so a call
+// costs the same however long the session has been recorded. Candles are
+// only appended when new or changed since last recorded.
+//
+// Replay needs the open time of candles. Candles that are not
+// executor.TimedCandleLike are given the open times of consecutive candles
+// from the start of the range asked for, when they fill it; see
+// timedCandles.
+type Recorder struct {
+	executor.BrokerLike
+	Dir string
+	now func() time.Time
+
+	mu sync.Mutex
+	// candles are the candles recorded by file and option, keyed by open
+	// time.
+	candles map[string]map[int64]Candle
+	// expiries are the expiries last recorded by symbol.
+	expiries map[string][]executor.Expiry
+}
+
+// NewRecorder records the responses of broker under dir. Recordings
+// already in dir are kept and added to, so a session can be restarted.
+func NewRecorder(broker executor.BrokerLike, dir string, now func() time.Time) *Recorder {
+	return &Recorder{
+		BrokerLike: broker,
+		Dir:        dir,
+		now:        now,
+		candles:    make(map[string]map[int64]Candle),
+		expiries:   make(map[string][]executor.Expiry),
+	}
+}
+
+func (r *Recorder) GetLTP(symbol string) (float64, error) {
+	ltp, err := r.BrokerLike.GetLTP(symbol)
+	if err != nil {
+		return ltp, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.append(TicksPath(r.Dir, symbol), Tick{Time: r.now(), Price: ltp})
+	return ltp, nil
+}
+
+func (r *Recorder) GetMarketDepth(symbol string) (executor.BidAskLike, error) {
+	depth, err := r.BrokerLike.GetMarketDepth(symbol)
+	if err != nil || depth == nil {
+		return depth, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.append(MarketDepthPath(r.Dir, symbol), DepthSnapshot{Time: r.now(), BidAsk: NewBidAsk(depth)})
+	return depth, nil
+}
+
+func (r *Recorder) GetCandles(symbol string, from, to time.Time, tf executor.TimeFrame) ([]executor.CandleLike, error) {
+	candles, err := r.BrokerLike.GetCandles(symbol, from, to, tf)
+	if err != nil {
+		return candles, err
+	}
+	timed, ok := timedCandles(candles, from, r.clamp(to), tf)
+	if !ok {
+		log.Println("Recorder: cannot tell the open times of candles to record for", symbol, tf)
+		return candles, nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	path := CandlesPath(r.Dir, symbol, tf)
+	recorded, ok := r.candles[path]
+	if !ok {
+		loaded, err := loadCandles(path)
+		r.logUnreadable(path, err)
+		recorded = candlesByTime(loaded)
+		r.candles[path] = recorded
+	}
+	var records []interface{}
+	for _, candle := range changedCandles(recorded, timed) {
+		records = append(records, candle)
+	}
+	r.append(path, records...)
+	return candles, nil
+}
+
+func (r *Recorder) GetOptionExpiries(symbol string) ([]executor.Expiry, error) {
+	expiries, err := r.BrokerLike.GetOptionExpiries(symbol)
+	if err != nil {
+		return expiries, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	path := ExpiriesPath(r.Dir, symbol)
+	last, ok := r.expiries[symbol]
+	if !ok {
+		snapshots, err := loadExpiries(path)
+		r.logUnreadable(path, err)
+		if len(snapshots) > 0 {
+			last, ok = snapshots[len(snapshots)-1].Expiries, true
+		}
+	}
+	r.expiries[symbol] = expiries
+	if ok && sameExpiries(last, expiries) {
+		return expiries, nil
+	}
+	r.append(path, ExpirySnapshot{Time: r.now(), Expiries: expiries})
+	return expiries, nil
+}
+
+func (r *Recorder) GetMarketDepthOption(strike float64, expiry time.Time, optionType executor.OptionType) (executor.BidAskLike, error) {
+	depth, err := r.BrokerLike.GetMarketDepthOption(strike, expiry, optionType)
+	if err != nil || depth == nil {
+		return depth, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.append(DepthPath(r.Dir), DepthSnapshot{
+		Time:   r.now(),
+		Strike: strike,
+		Expiry: expiry,
+		Type:   optionType,
+		BidAsk: NewBidAsk(depth),
+	})
+	return depth, nil
+}
+
+func (r *Recorder) GetCandlesOption(strike float64, expiry time.Time, optionType executor.OptionType, from, to time.Time) ([]executor.CandleLike, error) {
+	candles, err := r.BrokerLike.GetCandlesOption(strike, expiry, optionType, from, to)
+	if err != nil {
+		return candles, err
+	}
+	timed, ok := timedCandles(candles, from, r.clamp(to), ltpTimeFrames...)
+	if !ok {
+		log.Println("Recorder: cannot tell the open times of candles to record for", strike, optionType, expiry)
+		return candles, nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	path := OptionCandlesPath(r.Dir)
+	key := fmt.Sprintf("%v|%v|%v|%v", path, strike, expiry.UnixNano(), optionType)
+	if _, ok := r.candles[key]; !ok {
+		loaded, err := loadOptionCandles(path)
+		r.logUnreadable(path, err)
+		for _, option := range loaded {
+			optionKey := fmt.Sprintf("%v|%v|%v|%v", path, option.Strike, option.Expiry.UnixNano(), option.Type)
+			if _, ok := r.candles[optionKey]; !ok {
+				r.candles[optionKey] = candlesByTime(option.Candles)
+			}
+		}
+		if _, ok := r.candles[key]; !ok {
+			r.candles[key] = make(map[int64]Candle)
+		}
+	}
+	changed := changedCandles(r.candles[key], timed)
+	if len(changed) > 0 {
+		r.append(path, OptionCandles{Strike: strike, Expiry: expiry, Type: optionType, Candles: changed})
+	}
+	return candles, nil
+}
+
+// logUnreadable reports an existing recording that could not be loaded.
+// New records are still appended to it.
+func (r *Recorder) logUnreadable(path string, err error) {
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Println("Recorder: ignoring unreadable recording", path, err)
+	}
+}
+
+// append adds records to path.jsonl, one per line.
+func (r *Recorder) append(path string, records ...interface{}) {
+	if len(records) == 0 {
+		return
+	}
+	if err := appendJSONLines(path+".jsonl", records); err != nil {
+		log.Println("Recorder:", err)
+	}
+}
+
+// appendJSONLines writes records to the end of path in a single write. A
+// last line cut short by an interrupted session is ended first, so that
+// only it is lost.
+func appendJSONLines(path string, records []interface{}) error {
+	var buf bytes.Buffer
+	for _, record := range records {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("failed to marshal %v: %w", path, err)
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %v: %w", filepath.Dir(path), err)
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open %v: %w", path, err)
+	}
+	defer file.Close()
+	data := buf.Bytes()
+	if info, err := file.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := file.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			data = append([]byte("\n"), data...)
+		}
+	}
+	if _, err := file.Write(data); err != nil {
+		return fmt.Errorf("failed to write %v: %w", path, err)
+	}
+	return nil
+}
+
+func candlesByTime(candles []Candle) map[int64]Candle {
+	byTime := make(map[int64]Candle, len(candles))
+	for _, candle := range candles {
+		byTime[candle.Time.UnixNano()] = candle
+	}
+	return byTime
+}
+
+// changedCandles returns the candles that are not in recorded or differ
+// from it, as a candle still forming when first seen does, and adds them
+// to recorded.
+func changedCandles(recorded map[int64]Candle, candles []Candle) []Candle {
+	var changed []Candle
+	for _, candle := range candles {
+		key := candle.Time.UnixNano()
+		if previous, ok := recorded[key]; ok && sameCandle(previous, candle) {
+			continue
+		}
+		recorded[key] = candle
+		changed = append(changed, candle)
+	}
+	return changed
+}
+
+func sameCandle(a, b Candle) bool {
+	return a.Open == b.Open && a.High == b.High && a.Low == b.Low && a.Close == b.Close && a.Volume == b.Volume && a.OI == b.OI
+}
+
+// clamp limits to, the end of a range asked for, to now since no candle
+// after it can be returned.
+func (r *Recorder) clamp(to time.Time) time.Time {
+	if now := r.now(); to.After(now) {
+		return now
+	}
+	return to
+}
+
+// timedCandles returns candles with their open times. Candles that do not
+// know theirs are taken to be consecutive candles of one of tfs, opening on
+// multiples of it from midnight, from the first at or after from, with or
+// without the one still forming at to. ok is false when they fill the
+// range in none of tfs, as when the range spans a market close.
+func timedCandles(candles []executor.CandleLike, from, to time.Time, tfs ...executor.TimeFrame) ([]Candle, bool) {
+	timed := make([]Candle, len(candles))
+	untimed := false
+	for i, candle := range candles {
+		withTime, ok := candle.(executor.TimedCandleLike)
+		if !ok {
+			untimed = true
+			break
+		}
+		timed[i] = NewCandle(withTime.GetTime(), candle)
+	}
+	if !untimed || len(candles) == 0 {
+		return timed, true
+	}
+	for _, tf := range tfs {
+		d := tf.Duration()
+		if d == 0 {
+			continue
+		}
+		midnight := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location())
+		first := midnight.Add((from.Sub(midnight) + d - 1) / d * d)
+		if !to.After(first) {
+			continue
+		}
+		completed := int(to.Sub(first) / d)
+		opened := int((to.Sub(first) + d - 1) / d)
+		if len(candles) != completed && len(candles) != opened {
+			continue
+		}
+		for i, candle := range candles {
+			timed[i] = NewCandle(first.Add(time.Duration(i)*d), candle)
+		}
+		return timed, true
+	}
+	return nil, false
+}
+
+// mergeCandles adds candles to recorded, replacing any recorded candle with
+// the same open time so a candle still forming when first seen ends up
+// with its final values.
+func mergeCandles(recorded, candles []Candle) []Candle {
+	byTime := make(map[int64]int, len(recorded))
+	for i, candle := range recorded {
+		byTime[candle.Time.UnixNano()] = i
+	}
+	for _, candle := range candles {
+		if i, ok := byTime[candle.Time.UnixNano()]; ok {
+			recorded[i] = candle
+			continue
+		}
+		byTime[candle.Time.UnixNano()] = len(recorded)
+		recorded = append(recorded, candle)
+	}
+	sort.SliceStable(recorded, func(i, j int) bool { return recorded[i].Time.Before(recorded[j].Time) })
+	return recorded
+}
+
+func sameExpiries(a, b []executor.Expiry) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].ExpiryDate.Equal(b[i].ExpiryDate) {
+			return false
+		}
+	}
+	return true
+}
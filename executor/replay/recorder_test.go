@@ -0,0 +1,138 @@
+package replay
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dragonzurfer/trader/executor"
+)
+
+type untimedCandle struct{ executor.CandleLike }
+
+type untimedBroker struct{ executor.BrokerLike }
+
+func (b untimedBroker) GetCandles(symbol string, from, to time.Time, tf executor.TimeFrame) ([]executor.CandleLike, error) {
+	candles, err := b.BrokerLike.GetCandles(symbol, from, to, tf)
+	for i, candle := range candles {
+		candles[i] = untimedCandle{candle}
+	}
+	return candles, err
+}
+
+func TestRecordingReplaysIdentically(t *testing.T) {
+	source, clock := newTestBroker(t)
+	dir := t.TempDir()
+	recorder := NewRecorder(source, dir, clock.Now)
+	expiry := time.Date(2023, 5, 25, 0, 0, 0, 0, ist)
+
+	clock.Set(at(9, 26))
+	ltp, _ := recorder.GetLTP(symbol)
+	if _, err := recorder.GetCandles(symbol, at(9, 15), at(9, 26), executor.Minute5); err != nil {
+		t.Fatalf("GetCandles() failed: %v", err)
+	}
+	recorder.GetOptionExpiries(symbol)
+	recorder.GetMarketDepthOption(18300, expiry, executor.CallOption)
+	clock.Set(at(9, 31))
+	recorder.GetOptionExpiries(symbol)
+	recorder.GetMarketDepthOption(18300, expiry, executor.CallOption)
+	recorder.GetCandles(symbol, at(9, 15), at(9, 31), executor.Minute5)
+
+	replayed := NewReplayBroker(dir, clock.Now)
+	clock.Set(at(9, 27))
+	if got, err := replayed.GetLTP(symbol); err != nil || got != ltp {
+		t.Errorf("expected recorded LTP %v, got %v, %v", ltp, got, err)
+	}
+	depth, err := replayed.GetMarketDepthOption(18300, expiry, executor.CallOption)
+	if err != nil || depth.GetBids()[0].GetPrice() != 100 {
+		t.Errorf("expected the 9:26 depth, got %v, %v", depth, err)
+	}
+	clock.Set(at(9, 31))
+	depth, _ = replayed.GetMarketDepthOption(18300, expiry, executor.CallOption)
+	if depth.GetBids()[0].GetPrice() != 110 {
+		t.Errorf("expected the 9:31 depth, got %v", depth.GetBids()[0].GetPrice())
+	}
+	candles, err := replayed.GetCandles(symbol, at(9, 15), at(9, 31), executor.Minute5)
+	if err != nil || len(candles) != 3 {
+		t.Errorf("expected 3 merged candles, got %v, %v", len(candles), err)
+	}
+	expiries, err := replayed.GetOptionExpiries(symbol)
+	if err != nil || len(expiries) != 2 {
+		t.Errorf("expected 2 expiries, got %v, %v", expiries, err)
+	}
+	if recorded, _ := loadExpiries(ExpiriesPath(dir, symbol)); len(recorded) != 1 {
+		t.Errorf("expected unchanged expiries to be recorded once, got %v", len(recorded))
+	}
+
+	// A restarted recorder adds to the existing recording.
+	restarted := NewRecorder(source, dir, clock.Now)
+	clock.Set(at(9, 40))
+	restarted.GetLTP(symbol)
+	if ticks, _ := loadTicks(TicksPath(dir, symbol)); len(ticks) != 2 {
+		t.Errorf("expected 2 recorded ticks, got %v", len(ticks))
+	}
+}
+
+func TestRecorderTimesUntimedCandles(t *testing.T) {
+	source, clock := newTestBroker(t)
+	dir := t.TempDir()
+	recorder := NewRecorder(untimedBroker{source}, dir, clock.Now)
+	clock.Set(at(9, 30))
+
+	candles, err := recorder.GetCandles(symbol, at(9, 15), at(9, 45), executor.Minute5)
+	if err != nil || len(candles) != 3 {
+		t.Fatalf("expected the broker's 3 candles, got %v, %v", len(candles), err)
+	}
+	replayed, err := NewReplayBroker(dir, clock.Now).GetCandles(symbol, at(9, 15), at(9, 30), executor.Minute5)
+	if err != nil || len(replayed) != 3 {
+		t.Fatalf("expected 3 recorded candles, got %v, %v", len(replayed), err)
+	}
+	for i, candle := range replayed {
+		if open := candle.(Candle).Time; !open.Equal(at(9, 15+5*i)) {
+			t.Errorf("expected candle %v to open at %v, got %v", i, at(9, 15+5*i), open)
+		}
+		if candle.GetClose() != candles[i].GetClose() {
+			t.Errorf("expected candle %v to close at %v, got %v", i, candles[i].GetClose(), candle.GetClose())
+		}
+	}
+
+	// Day candles across a weekend leave the open times unknown.
+	from := time.Date(2023, 5, 13, 0, 0, 0, 0, ist)
+	if days, err := recorder.GetCandles(symbol, from, at(9, 30), executor.Day); err != nil || len(days) == 0 {
+		t.Fatalf("expected the broker's day candles, got %v, %v", len(days), err)
+	}
+	if _, err := loadCandles(CandlesPath(dir, symbol, executor.Day)); err == nil {
+		t.Errorf("expected the day candles not to be recorded")
+	}
+}
+
+func TestRecorderAppendsRecords(t *testing.T) {
+	source, clock := newTestBroker(t)
+	dir := t.TempDir()
+	recorder := NewRecorder(source, dir, clock.Now)
+
+	clock.Set(at(9, 26))
+	recorder.GetLTP(symbol)
+	candles, _ := recorder.GetCandles(symbol, at(9, 15), at(9, 26), executor.Minute5)
+	recorder.GetCandles(symbol, at(9, 15), at(9, 26), executor.Minute5)
+	lines, err := readJSONLines(CandlesPath(dir, symbol, executor.Minute5) + ".jsonl")
+	if err != nil || len(lines) != len(candles) {
+		t.Errorf("expected unchanged candles to be appended once, got %v lines, %v", len(lines), err)
+	}
+
+	// A tick cut short by an interrupted session is skipped, and later
+	// ticks still read back.
+	path := TicksPath(dir, symbol) + ".jsonl"
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"time":"2023-05-16T09:2`)
+	file.Close()
+	clock.Set(at(9, 31))
+	recorder.GetLTP(symbol)
+	ticks, err := loadTicks(TicksPath(dir, symbol))
+	if err != nil || len(ticks) != 2 {
+		t.Errorf("expected 2 recorded ticks, got %v, %v", ticks, err)
+	}
+}
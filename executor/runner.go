@@ -0,0 +1,308 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// TimeExitLike is implemented by executors with exits that depend on the
+// clock rather than the price. It is checked every step while in a trade,
+// and the runner exits when it is satisfied.
+type TimeExitLike interface {
+	IsTimeExitSatisfied() bool
+}
+
+// ClockLike is implemented by executors that keep their own time, such as
+// on a replay or simulated clock. Guard dates entries, exits and P&L by it
+// instead of the wall clock.
+type ClockLike interface {
+	Now() time.Time
+}
+
+// Run drives the executor until ctx is cancelled. Entry checks run every
+// GetSleepDuration() while inside the trading window, prices received on
+// Ticks are forwarded to ExitOnTick, and every change of trade state is
+// persisted with LogTrade.
+func (t *Trader) Run(ctx context.Context) error {
+	if t.Executor == nil {
+		return fmt.Errorf("trader %v: executor is nil", t.ID)
+	}
+	if path := t.tradeFilePath(); path != "" {
+		t.Executor.SetTradeFilePath(path)
+	}
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			t.shutdown()
+			return ctx.Err()
+		case <-timer.C:
+			wasInTrade := t.Executor.InTrade()
+			t.step()
+			if t.Executor.InTrade() != wasInTrade {
+				t.refreshValuation(true)
+			}
+			timer.Reset(t.sleepDuration())
+		case price, ok := <-t.Ticks:
+			if !ok {
+				t.Ticks = nil
+				continue
+			}
+			wasInTrade := t.Executor.InTrade()
+			t.Executor.ExitOnTick(price)
+			// The exit is booked here rather than on its event, which
+			// may only arrive after the next step has entered again.
+			exited := wasInTrade && !t.Executor.InTrade()
+			if exited {
+				t.recordExit()
+			}
+			t.refreshValuation(exited)
+			t.enforceGuard()
+		case event := <-t.Executor.GetEventChan():
+			if !event.IsExit() {
+				t.logTrade()
+				log.Printf("trader %v: stop loss trailed\n", t.ID)
+				continue
+			}
+			t.onExit(event.String())
+			t.refreshValuation(true)
+		}
+	}
+}
+
+func (t *Trader) step() {
+	defer t.flushErrors()
+
+	if t.enforceGuard() {
+		return
+	}
+
+	if checker, ok := t.Executor.(TimeExitLike); ok && t.Executor.InTrade() && checker.IsTimeExitSatisfied() {
+		t.exit()
+		if !t.Executor.InTrade() {
+			t.onExit("time exit")
+		}
+		return
+	}
+
+	if !t.Executor.InTradingWindow() {
+		if t.ExitOutsideWindow && t.Executor.InTrade() && t.exitFor(ExitTime, "trading window closed") {
+			t.onExit("trading window closed")
+		}
+		return
+	}
+	if t.Executor.InTrade() {
+		return
+	}
+	if !t.Executor.IsEntrySatisfied() {
+		return
+	}
+	if t.Guard != nil {
+		if ok, reason := t.Guard.CanEnter(t.now()); !ok {
+			t.logHalt(reason)
+			return
+		}
+	}
+	tradeType := t.Executor.GetTradeType()
+	if t.IsLive {
+		t.Executor.AccountTrade(tradeType)
+	} else {
+		t.Executor.PaperTrade(tradeType)
+	}
+	if !t.Executor.InTrade() {
+		log.Printf("trader %v: entry satisfied but no position was taken\n", t.ID)
+		return
+	}
+	t.exitBooked = false
+	t.logTrade()
+	log.Printf("trader %v: entered\n%v\n", t.ID, t.Executor.GetEntryMessage())
+	if t.Guard != nil {
+		if err := t.Guard.RecordEntry(t.ID, t.now()); err != nil {
+			log.Printf("trader %v: RecordEntry() failed: %v\n", t.ID, err)
+		}
+	}
+}
+
+// enforceGuard reports whether Guard has halted trading, exiting the open
+// trade first when it flattens on a breach.
+func (t *Trader) enforceGuard() bool {
+	if t.Guard == nil {
+		return false
+	}
+	halted, reason := t.Guard.Halted(t.now())
+	if !halted {
+		t.haltReason = ""
+		return false
+	}
+	t.logHalt(reason)
+	if t.Guard.Limits.FlattenOnBreach && t.Executor.InTrade() {
+		if !t.exitFor(ExitRiskLimit, reason) {
+			return true
+		}
+		t.onExit("risk limit: " + reason)
+		t.refreshValuation(true)
+	}
+	return true
+}
+
+func (t *Trader) logHalt(reason string) {
+	if reason == t.haltReason {
+		return
+	}
+	t.haltReason = reason
+	log.Printf("trader %v: entries stopped: %v\n", t.ID, reason)
+}
+
+// exitFor exits the open trade, recording reason and detail with executors
+// that implement ExitReasonLike, and reports whether the trade closed. The
+// reason is cleared again when it did not.
+func (t *Trader) exitFor(reason ExitReason, detail string) bool {
+	setter, hasReason := t.Executor.(ExitReasonLike)
+	if hasReason {
+		setter.SetExitReason(reason, detail)
+	}
+	t.exit()
+	if t.Executor.InTrade() {
+		if hasReason {
+			setter.SetExitReason("", "")
+		}
+		return false
+	}
+	return true
+}
+
+func (t *Trader) exit() {
+	if t.IsLive {
+		t.Executor.ExitAccount()
+	} else {
+		t.Executor.ExitPaper()
+	}
+}
+
+func (t *Trader) onExit(reason string) {
+	t.logTrade()
+	t.recordExit()
+	log.Printf("trader %v: exited on %v\n%v\n", t.ID, reason, t.Executor.GetExitMessage())
+}
+
+func (t *Trader) shutdown() {
+	t.logTrade()
+	if t.Portfolio != nil {
+		t.Portfolio.Remove(t.ID)
+	}
+	t.flushErrors()
+	log.Printf("trader %v: stopped\n", t.ID)
+}
+
+// refreshValuation updates Portfolio with the executor's exposures and
+// Guard with the P&L of its open trade. Unless forced, as on entries and
+// exits, it waits ValuationInterval between updates.
+func (t *Trader) refreshValuation(force bool) {
+	if t.Portfolio == nil && t.Guard == nil {
+		return
+	}
+	now := time.Now()
+	if !force && now.Sub(t.lastValuation) < t.ValuationInterval {
+		return
+	}
+	t.lastValuation = now
+	if exposer, ok := t.Executor.(ExposureLike); ok && t.Portfolio != nil {
+		exposures, err := exposer.GetExposures()
+		if err != nil {
+			log.Printf("trader %v: GetExposures() failed: %v\n", t.ID, err)
+			t.Portfolio.MarkStale(t.ID)
+		} else {
+			t.Portfolio.Update(t.ID, exposures, now)
+		}
+	}
+	if valuer, ok := t.Executor.(PnLLike); ok && t.Guard != nil && t.Executor.InTrade() {
+		pnl, err := valuer.GetPnL()
+		if err != nil {
+			log.Printf("trader %v: GetPnL() failed: %v\n", t.ID, err)
+			return
+		}
+		if err := t.Guard.UpdatePnL(t.ID, pnl, t.now()); err != nil {
+			log.Printf("trader %v: UpdatePnL() failed: %v\n", t.ID, err)
+		}
+	}
+}
+
+// recordExit books the realized P&L of the trade just exited with Guard,
+// once per trade.
+func (t *Trader) recordExit() {
+	valuer, ok := t.Executor.(PnLLike)
+	if t.Guard == nil || !ok || t.Executor.InTrade() || t.exitBooked {
+		return
+	}
+	t.exitBooked = true
+	pnl, err := valuer.GetPnL()
+	if err != nil {
+		log.Printf("trader %v: GetPnL() failed: %v\n", t.ID, err)
+		return
+	}
+	if err := t.Guard.RecordExit(t.ID, pnl, t.now()); err != nil {
+		log.Printf("trader %v: RecordExit() failed: %v\n", t.ID, err)
+	}
+}
+
+// now is the executor's time when it keeps its own, the wall clock
+// otherwise.
+func (t *Trader) now() time.Time {
+	if clock, ok := t.Executor.(ClockLike); ok {
+		return clock.Now()
+	}
+	return time.Now()
+}
+
+func (t *Trader) logTrade() {
+	if err := t.Executor.LogTrade(); err != nil {
+		log.Printf("trader %v: LogTrade() failed: %v\n", t.ID, err)
+	}
+}
+
+// flushErrors appends any errors reported by the executor to
+// ExecutorErrorFilePath, or to the log when no file is configured.
+func (t *Trader) flushErrors() {
+	if !t.Executor.IsError() {
+		return
+	}
+	errs := t.Executor.ReadErrors()
+	if len(errs) == 0 {
+		return
+	}
+	if t.ExecutorErrorFilePath == "" {
+		log.Printf("trader %v: executor errors: %v\n", t.ID, strings.Join(errs, "; "))
+		return
+	}
+	file, err := os.OpenFile(t.ExecutorErrorFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Printf("trader %v: failed to open error file: %v\n", t.ID, err)
+		return
+	}
+	defer file.Close()
+	for _, e := range errs {
+		fmt.Fprintf(file, "%v %v %v\n", time.Now().Format(time.RFC3339), t.ID, e)
+	}
+}
+
+func (t *Trader) tradeFilePath() string {
+	if t.IsLive {
+		return t.AccountTradeFilePath
+	}
+	return t.PaperTradeFilePath
+}
+
+func (t *Trader) sleepDuration() time.Duration {
+	d := t.Executor.GetSleepDuration()
+	if d <= 0 {
+		return time.Minute
+	}
+	return d
+}
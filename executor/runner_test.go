@@ -0,0 +1,253 @@
+package executor
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeExecutor struct {
+	mu            sync.Mutex
+	inTrade       bool
+	inWindow      bool
+	entry         bool
+	paperTrades   int
+	accountTrades int
+	logged        int
+	stopLoss      float64
+	events        chan Event
+}
+
+func newFakeExecutor() *fakeExecutor {
+	return &fakeExecutor{
+		inWindow: true,
+		entry:    true,
+		stopLoss: 100,
+		events:   make(chan Event),
+	}
+}
+
+func (f *fakeExecutor) SetBroker(BrokerLike)            {}
+func (f *fakeExecutor) SetTradeFilePath(string)         {}
+func (f *fakeExecutor) SetSettingsFilesPath(string)     {}
+func (f *fakeExecutor) InTradingWindow() bool           { return f.inWindow }
+func (f *fakeExecutor) GetEventChan() <-chan Event      { return f.events }
+func (f *fakeExecutor) GetEntryMessage() string         { return "entry" }
+func (f *fakeExecutor) GetExitMessage() string          { return "exit" }
+func (f *fakeExecutor) IsError() bool                   { return false }
+func (f *fakeExecutor) ReadErrors() []string            { return nil }
+func (f *fakeExecutor) GetSleepDuration() time.Duration { return 10 * time.Millisecond }
+func (f *fakeExecutor) GetTradeType() TradeType         { return Buy }
+func (f *fakeExecutor) LoadFromJSON() error             { return nil }
+
+func (f *fakeExecutor) InTrade() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.inTrade
+}
+
+func (f *fakeExecutor) IsEntrySatisfied() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.entry
+}
+
+func (f *fakeExecutor) setEntry(entry bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.entry = entry
+}
+
+func (f *fakeExecutor) PaperTrade(TradeType) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.inTrade = true
+	f.paperTrades++
+}
+
+func (f *fakeExecutor) AccountTrade(TradeType) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.inTrade = true
+	f.accountTrades++
+}
+
+func (f *fakeExecutor) ExitPaper() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.inTrade = false
+}
+
+func (f *fakeExecutor) ExitAccount() { f.ExitPaper() }
+
+func (f *fakeExecutor) LogTrade() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.logged++
+	return nil
+}
+
+func (f *fakeExecutor) ExitOnTick(price float64) {
+	if f.InTrade() && price <= f.stopLoss {
+		f.ExitPaper()
+		go func() { f.events <- Event{Reason: ExitStopLoss} }()
+	}
+}
+
+func (f *fakeExecutor) counts() (int, int, int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.paperTrades, f.accountTrades, f.logged
+}
+
+func TestRunEntersAndExitsOnTick(t *testing.T) {
+	exec := newFakeExecutor()
+	ticks := make(chan float64)
+	trader := Trader{ID: "test", Executor: exec, Ticks: ticks}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- trader.Run(ctx) }()
+
+	deadline := time.After(time.Second)
+	for !exec.InTrade() {
+		select {
+		case <-deadline:
+			t.Fatal("timeout waiting for entry")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	exec.setEntry(false)
+	ticks <- 90
+
+	for exec.InTrade() {
+		select {
+		case <-deadline:
+			t.Fatal("timeout waiting for exit")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	paper, account, logged := exec.counts()
+	if paper != 1 || account != 0 {
+		t.Fatalf("expected one paper trade, got paper:%v account:%v", paper, account)
+	}
+	// entry, stop loss exit and shutdown
+	if logged < 3 {
+		t.Fatalf("expected at least 3 LogTrade calls, got %v", logged)
+	}
+}
+
+func TestRunExitOutsideWindow(t *testing.T) {
+	exec := newFakeExecutor()
+	exec.inWindow = false
+	exec.inTrade = true
+	trader := Trader{ID: "test", Executor: exec, IsLive: true, ExitOutsideWindow: true}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	trader.Run(ctx)
+
+	if exec.InTrade() {
+		t.Fatal("expected trade to be squared off outside the trading window")
+	}
+	if _, account, _ := exec.counts(); account != 0 {
+		t.Fatalf("expected no new entries outside the window, got %v", account)
+	}
+}
+
+func TestRunExitOutsideWindowRecordsReason(t *testing.T) {
+	exec := &guardedExecutor{fakeExecutor: newFakeExecutor(), mu: &sync.Mutex{}}
+	exec.inWindow = false
+	exec.inTrade = true
+	trader := Trader{ID: "test", Executor: exec, ExitOutsideWindow: true}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	trader.Run(ctx)
+
+	if exec.InTrade() {
+		t.Fatal("expected trade to be squared off outside the trading window")
+	}
+	exec.mu.Lock()
+	defer exec.mu.Unlock()
+	if exec.reason != ExitTime || exec.detail != "trading window closed" {
+		t.Fatalf("expected a time exit on the window closing, got %q (%v)", exec.reason, exec.detail)
+	}
+}
+
+type timeExitExecutor struct {
+	*fakeExecutor
+	due chan bool
+}
+
+func (e timeExitExecutor) IsTimeExitSatisfied() bool {
+	select {
+	case <-e.due:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestRunExitsOnTime(t *testing.T) {
+	exec := timeExitExecutor{fakeExecutor: newFakeExecutor(), due: make(chan bool, 1)}
+	trader := Trader{ID: "test", Executor: exec}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go trader.Run(ctx)
+
+	deadline := time.After(time.Second)
+	for !exec.InTrade() {
+		select {
+		case <-deadline:
+			t.Fatal("timeout waiting for entry")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	exec.setEntry(false)
+	exec.due <- true
+	for exec.InTrade() {
+		select {
+		case <-deadline:
+			t.Fatal("timeout waiting for the time exit")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	if paper, _, _ := exec.counts(); paper != 1 {
+		t.Fatalf("expected one paper trade, got %v", paper)
+	}
+}
+
+func TestRunPersistsTrail(t *testing.T) {
+	exec := newFakeExecutor()
+	exec.inTrade = true
+	exec.entry = false
+	trader := Trader{ID: "test", Executor: exec}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go trader.Run(ctx)
+
+	exec.events <- Event{Reason: TrailAdjusted}
+	deadline := time.After(time.Second)
+	for {
+		if _, _, logged := exec.counts(); logged > 0 {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatal("timeout waiting for the trail to be logged")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	if !exec.InTrade() {
+		t.Fatal("a trail event should not exit the trade")
+	}
+}
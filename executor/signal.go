@@ -0,0 +1,38 @@
+package executor
+
+import "time"
+
+// SignalInput is the market data a signal is computed from.
+type SignalInput struct {
+	// CurrentDayCandles are the completed intraday candles of the day so
+	// far, oldest first.
+	CurrentDayCandles []CandleLike
+	PreviousDayCandle CandleLike
+	Time              time.Time
+}
+
+// Signal is a trade idea on the underlying. Direction is Nuetral when there
+// is nothing to do, in which case the prices are unset.
+type Signal struct {
+	Direction     TradeType
+	EntryPrice    float64
+	StopLossPrice float64
+	TargetPrice   float64
+	Message       string
+	// Metadata carries provider specific detail, such as the levels a CPR
+	// signal crossed, for logging and inspection.
+	Metadata map[string]interface{}
+}
+
+func (s Signal) IsNeutral() bool {
+	return s.Direction != Buy && s.Direction != Sell
+}
+
+// NeutralSignal is returned by providers when there is nothing to do.
+func NeutralSignal(message string) Signal {
+	return Signal{Direction: Nuetral, Message: message}
+}
+
+type SignalProviderLike interface {
+	GetSignal(SignalInput) (Signal, error)
+}
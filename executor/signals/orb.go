@@ -0,0 +1,72 @@
+// Package signals holds executor.SignalProviderLike implementations that
+// only need candles.
+package signals
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dragonzurfer/trader/executor"
+)
+
+// OpeningRange signals when a candle closes beyond the high or low of the
+// first Candles candles of the day. The stop is the other side of the
+// range and the target RiskReward times the risk, 1 when unset.
+type OpeningRange struct {
+	Candles    int
+	RiskReward float64
+}
+
+func (o OpeningRange) GetSignal(input executor.SignalInput) (executor.Signal, error) {
+	if o.Candles <= 0 {
+		return executor.Signal{}, errors.New("GetSignal(): opening range needs at least one candle")
+	}
+	candles := input.CurrentDayCandles
+	if len(candles) <= o.Candles {
+		return executor.NeutralSignal("opening range not complete"), nil
+	}
+
+	high, low := candles[0].GetHigh(), candles[0].GetLow()
+	for _, candle := range candles[1:o.Candles] {
+		if candle.GetHigh() > high {
+			high = candle.GetHigh()
+		}
+		if candle.GetLow() < low {
+			low = candle.GetLow()
+		}
+	}
+
+	// Only the candle that breaks out signals, not every one after it.
+	last := candles[len(candles)-1].GetClose()
+	previous := candles[len(candles)-2].GetClose()
+	if len(candles)-1 == o.Candles {
+		previous = (high + low) / 2
+	}
+	riskReward := o.RiskReward
+	if riskReward <= 0 {
+		riskReward = 1
+	}
+	metadata := map[string]interface{}{"range_high": high, "range_low": low}
+
+	switch {
+	case last > high && previous <= high:
+		return executor.Signal{
+			Direction:     executor.Buy,
+			EntryPrice:    last,
+			StopLossPrice: low,
+			TargetPrice:   last + riskReward*(last-low),
+			Message:       fmt.Sprintf("closed %v above opening range high %v", last, high),
+			Metadata:      metadata,
+		}, nil
+	case last < low && previous >= low:
+		return executor.Signal{
+			Direction:     executor.Sell,
+			EntryPrice:    last,
+			StopLossPrice: high,
+			TargetPrice:   last - riskReward*(high-last),
+			Message:       fmt.Sprintf("closed %v below opening range low %v", last, low),
+			Metadata:      metadata,
+		}, nil
+	}
+	return executor.NeutralSignal("no opening range breakout"), nil
+}
@@ -0,0 +1,55 @@
+package signals
+
+import (
+	"testing"
+
+	"github.com/dragonzurfer/trader/executor"
+)
+
+type candle struct{ open, high, low, close float64 }
+
+func (c candle) GetOpen() float64   { return c.open }
+func (c candle) GetHigh() float64   { return c.high }
+func (c candle) GetLow() float64    { return c.low }
+func (c candle) GetClose() float64  { return c.close }
+func (c candle) GetVolume() float64 { return 0 }
+func (c candle) GetOI() float64     { return 0 }
+
+func input(candles ...candle) executor.SignalInput {
+	var candleLikes []executor.CandleLike
+	for _, c := range candles {
+		candleLikes = append(candleLikes, c)
+	}
+	return executor.SignalInput{CurrentDayCandles: candleLikes}
+}
+
+func TestOpeningRangeBreakout(t *testing.T) {
+	orb := OpeningRange{Candles: 2, RiskReward: 2}
+	opening := []candle{{100, 110, 95, 105}, {105, 112, 100, 108}}
+
+	signal, err := orb.GetSignal(input(opening...))
+	if err != nil || !signal.IsNeutral() {
+		t.Fatalf("expected neutral before the range completes, got %+v, %v", signal, err)
+	}
+
+	signal, _ = orb.GetSignal(input(append(opening, candle{108, 116, 107, 115})...))
+	if signal.Direction != executor.Buy || signal.StopLossPrice != 95 || signal.TargetPrice != 155 {
+		t.Errorf("expected a buy at 115 with SL 95 and target 155, got %+v", signal)
+	}
+
+	signal, _ = orb.GetSignal(input(append(opening, candle{108, 116, 107, 115}, candle{115, 118, 114, 117})...))
+	if !signal.IsNeutral() {
+		t.Errorf("expected only the breakout candle to signal, got %+v", signal)
+	}
+
+	signal, _ = orb.GetSignal(input(append(opening, candle{100, 101, 90, 92})...))
+	if signal.Direction != executor.Sell || signal.StopLossPrice != 112 || signal.TargetPrice != 52 {
+		t.Errorf("expected a sell at 92 with SL 112 and target 52, got %+v", signal)
+	}
+}
+
+func TestOpeningRangeNeedsCandles(t *testing.T) {
+	if _, err := (OpeningRange{}).GetSignal(input()); err == nil {
+		t.Errorf("expected an error for an empty opening range")
+	}
+}
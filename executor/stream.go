@@ -0,0 +1,40 @@
+package executor
+
+import "time"
+
+// Tick is a trade pushed by a streaming feed.
+type Tick struct {
+	Symbol string
+	Price  float64
+	// Volume is the quantity traded at Price, 0 when the feed does not
+	// report it.
+	Volume float64
+	Time   time.Time
+}
+
+type FeedEventType string
+
+const (
+	FeedConnected    FeedEventType = "connected"
+	FeedDisconnected FeedEventType = "disconnected"
+	// FeedReconnected is sent once the feed is back after a disconnect.
+	// Subscriptions do not survive a reconnect and have to be made again.
+	FeedReconnected FeedEventType = "reconnected"
+)
+
+type FeedEvent struct {
+	Type FeedEventType
+	// Err is the cause of a disconnect, if known.
+	Err  error
+	Time time.Time
+}
+
+// StreamingBrokerLike is implemented by brokers that push prices instead
+// of having them polled with GetLTP. Ticks and Events are closed when the
+// feed shuts down for good.
+type StreamingBrokerLike interface {
+	Subscribe(symbols ...string) error
+	Unsubscribe(symbols ...string) error
+	Ticks() <-chan Tick
+	Events() <-chan FeedEvent
+}
@@ -9,7 +9,6 @@ type ExecutorLike interface {
 	InTradingWindow() bool
 	InTrade() bool
 	IsEntrySatisfied() bool
-	GetStopLossHitChan() <-chan bool
 	GetEntryMessage() string
 	GetExitMessage() string
 	IsError() bool
@@ -22,7 +21,9 @@ type ExecutorLike interface {
 	ExitAccount()
 	LogTrade() error
 	LoadFromJSON() error
-	GetTargetHitChan() <-chan bool
+	// GetEventChan carries the exits, and stop loss trails, that the
+	// executor makes on its own from ExitOnTick.
+	GetEventChan() <-chan Event
 	ExitOnTick(float64)
 }
 
@@ -35,4 +36,28 @@ type Trader struct {
 	SettingsFilePath          string
 	BrokerCredentialsFilePath string
 	Executor                  ExecutorLike
+	// IsLive selects AccountTrade/ExitAccount instead of the paper calls.
+	IsLive bool
+	// ExitOutsideWindow squares off an open trade once the executor
+	// reports it is outside its trading window.
+	ExitOutsideWindow bool
+	// Ticks carries underlying prices that are forwarded to ExitOnTick,
+	// typically from a Dispatcher through AddTrader.
+	Ticks <-chan float64
+	// Portfolio, when set, is kept up to date with the exposures of an
+	// executor implementing ExposureLike on every tick.
+	Portfolio *Portfolio
+	// Guard, when set, holds back entries and flattens trades on a breach
+	// of its limits, valuing an executor implementing PnLLike on every
+	// tick.
+	Guard *Guard
+	// ValuationInterval is the least time between valuations for Portfolio
+	// and Guard, as valuing the positions queries the broker.
+	ValuationInterval time.Duration
+
+	lastValuation time.Time
+	haltReason    string
+	// exitBooked is set once the exit of the current trade was booked
+	// with Guard, which may be seen both on the tick and on its event.
+	exitBooked bool
 }